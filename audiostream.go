@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// defaultAudioChunkSize 是音频分块的默认大小（字节），低于多数 broker 的消息大小限制
+const defaultAudioChunkSize = 64 << 10
+
+// audioHeader 是每段音频的头消息，发布到 <AudioTopic>/header。
+// 随后的 Chunks 个二进制分块依次发布到 <AudioTopic>/chunk/<ID>/<序号>（从 0 开始），
+// 接收端订阅 <AudioTopic>/#，按 ID 收齐分块后拼接并校验 SHA256 即得到完整的 .wav 文件
+type audioHeader struct {
+	ID        string `json:"id"`
+	Text      string `json:"text"`
+	Format    string `json:"format"` // 目前总是 "wav"
+	Size      int    `json:"size"`
+	ChunkSize int    `json:"chunk_size"`
+	Chunks    int    `json:"chunks"`
+	SHA256    string `json:"sha256"`
+	Timestamp string `json:"timestamp"`
+}
+
+// MQTTAudioSpeaker 由 Inner 合成 .wav 后不在本机播放，而是分块发布到 MQTT，
+// 供没有 TTS 的瘦客户端（如树莓派）订阅播放。client 在 MQTT 客户端创建后设置。
+// 请求本身要求保存为文件（OutputFile 非空）时直接交给 Inner
+type MQTTAudioSpeaker struct {
+	Inner     Speaker
+	Topic     string
+	ChunkSize int
+
+	client mqtt.Client
+}
+
+func (s *MQTTAudioSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	if opts.OutputFile != "" {
+		return s.Inner.Speak(ctx, text, opts)
+	}
+	if s.client == nil || !s.client.IsConnected() {
+		return fmt.Errorf("MQTT 未连接，无法发布音频")
+	}
+
+	f, err := os.CreateTemp("", "tts-stream-*.wav")
+	if err != nil {
+		return fmt.Errorf("无法创建临时音频文件: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	opts.OutputFile = f.Name()
+	if err := s.Inner.Speak(ctx, text, opts); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return fmt.Errorf("无法读取合成结果: %w", err)
+	}
+	return s.publish(ctx, text, data)
+}
+
+// publish 先发布头消息，再按顺序发布各分块，每条消息都等待 broker 确认
+func (s *MQTTAudioSpeaker) publish(ctx context.Context, text string, data []byte) error {
+	size := s.ChunkSize
+	if size <= 0 {
+		size = defaultAudioChunkSize
+	}
+	sum := sha256.Sum256(data)
+	h := audioHeader{
+		ID:        newAudioID(),
+		Text:      text,
+		Format:    "wav",
+		Size:      len(data),
+		ChunkSize: size,
+		Chunks:    (len(data) + size - 1) / size,
+		SHA256:    hex.EncodeToString(sum[:]),
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, s.Topic+"/header", header); err != nil {
+		return err
+	}
+	for i := 0; i < h.Chunks; i++ {
+		chunk := data[i*size : min((i+1)*size, len(data))]
+		if err := s.send(ctx, s.Topic+"/chunk/"+h.ID+"/"+strconv.Itoa(i), chunk); err != nil {
+			return err
+		}
+	}
+	log.Printf("📡 已发布音频 %s（%d 字节，%d 块）到 %s", h.ID, h.Size, h.Chunks, s.Topic)
+	return nil
+}
+
+func (s *MQTTAudioSpeaker) send(ctx context.Context, topic string, payload []byte) error {
+	token := s.client.Publish(topic, 1, false, payload)
+	select {
+	case <-token.Done():
+		if err := token.Error(); err != nil {
+			return fmt.Errorf("发布音频失败 %s: %w", topic, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *MQTTAudioSpeaker) Voices(ctx context.Context) ([]voiceInfo, error) {
+	if l, ok := s.Inner.(voiceLister); ok {
+		return l.Voices(ctx)
+	}
+	return nil, errVoicesUnsupported
+}
+
+// newAudioID 生成 16 位十六进制的音频 ID，用作分块主题的一级
+func newAudioID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
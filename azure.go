@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AzureSpeaker 调用 Azure Speech REST API 合成神经网络语音并播放。
+// 合成失败（网络错误、鉴权失败等）时回退到 Fallback 本地朗读。
+type AzureSpeaker struct {
+	Key          string
+	Region       string
+	DefaultVoice string  // 消息未指定 voice 时使用，如 zh-CN-XiaoxiaoNeural
+	Fallback     Speaker // 可为 nil
+
+	client *http.Client
+}
+
+func (s *AzureSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	log.Printf("🔊 尝试使用 Azure 朗读文本 (长度=%d): %.50q", len(text), text)
+
+	err := s.speak(ctx, text, opts)
+	if err == nil || ctx.Err() != nil || s.Fallback == nil {
+		return err
+	}
+	log.Printf("⚠️ Azure TTS 失败，回退到本地朗读: %v", err)
+	return s.Fallback.Speak(ctx, text, opts)
+}
+
+func (s *AzureSpeaker) speak(ctx context.Context, text string, opts speakOptions) error {
+	audio, err := s.synthesize(ctx, s.buildSSML(text, opts))
+	if err != nil {
+		return err
+	}
+
+	if opts.OutputFile != "" {
+		return os.WriteFile(opts.OutputFile, audio, 0644)
+	}
+
+	f, err := os.CreateTemp("", "tts-azure-*.wav")
+	if err != nil {
+		return fmt.Errorf("无法创建临时音频文件: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(audio)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("无法写入临时音频文件: %w", err)
+	}
+	return playWav(ctx, f.Name())
+}
+
+// buildSSML 将 voice/rate/volume 转换为 Azure 所需的 SSML；
+// 消息本身是 SSML 时原样发送
+func (s *AzureSpeaker) buildSSML(text string, opts speakOptions) string {
+	if opts.SSML {
+		return text
+	}
+	voice := opts.Voice
+	if voice == "" {
+		voice = s.DefaultVoice
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(text))
+
+	return fmt.Sprintf(
+		`<speak version="1.0" xmlns="http://www.w3.org/2001/10/synthesis" xml:lang="%s">`+
+			`<voice name="%s"><prosody rate="%+d%%" volume="%d">%s</prosody></voice></speak>`,
+		voiceLang(voice), xmlAttr(voice), opts.Rate*10, opts.Volume, escaped.String())
+}
+
+// synthesize 请求 Azure 合成语音，返回 RIFF/WAV 音频
+func (s *AzureSpeaker) synthesize(ctx context.Context, ssml string) ([]byte, error) {
+	endpoint := fmt.Sprintf("https://%s.tts.speech.microsoft.com/cognitiveservices/v1", s.Region)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(ssml))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Ocp-Apim-Subscription-Key", s.Key)
+	req.Header.Set("Content-Type", "application/ssml+xml")
+	req.Header.Set("X-Microsoft-OutputFormat", "riff-24khz-16bit-mono-pcm")
+	req.Header.Set("User-Agent", "win-tts-api")
+
+	client := s.client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrSpeakTimeout
+		}
+		return nil, fmt.Errorf("请求 Azure TTS 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Azure TTS 响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Azure TTS 返回 %s: %.200s", resp.Status, body)
+	}
+	if len(body) == 0 {
+		return nil, errors.New("Azure TTS 返回了空音频")
+	}
+	return body, nil
+}
+
+// voiceLang 从 Azure 语音名（如 zh-CN-XiaoxiaoNeural）中取出语言代码 zh-CN
+func voiceLang(voice string) string {
+	parts := strings.SplitN(voice, "-", 3)
+	if len(parts) < 3 {
+		return "en-US"
+	}
+	return parts[0] + "-" + parts[1]
+}
+
+func xmlAttr(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 屏蔽词的处理方式
+const (
+	blocklistSkip    = "skip"    // 命中时整条消息不朗读（默认）
+	blocklistReplace = "replace" // 将命中的部分替换为 BlocklistToken 后朗读
+)
+
+// blocklistCache 缓存已编译的屏蔽规则，热加载修改规则后按新的规则重新编译
+var blocklistCache sync.Map // string -> []*regexp.Regexp
+
+// compileBlocklist 编译屏蔽规则，任一正则无效时返回错误并指出是第几条
+func compileBlocklist(patterns []string) ([]*regexp.Regexp, error) {
+	key := strings.Join(patterns, "\x00")
+	if res, ok := blocklistCache.Load(key); ok {
+		return res.([]*regexp.Regexp), nil
+	}
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d 条规则 %q 无效: %w", i+1, p, err)
+		}
+		res[i] = re
+	}
+	blocklistCache.Store(key, res)
+	return res, nil
+}
+
+// applyBlocklist 检查 text 是否命中 rules，返回处理后的文本和命中的规则序号（从 0 开始，
+// 未命中为 -1）。replace 为 true 时把所有规则命中的部分替换为 token，否则原样返回 text。
+// 各规则都在原文上匹配，重叠或相接的命中合并后只替换为一个 token，
+// 前面的规则替换后不会让后面的规则漏掉本应命中的内容。只匹配到空串的规则不算命中
+func applyBlocklist(text string, rules []*regexp.Regexp, replace bool, token string) (string, int) {
+	hit := -1
+	var spans [][]int
+	for i, re := range rules {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			if !replace {
+				return text, i
+			}
+			if hit < 0 {
+				hit = i
+			}
+			spans = append(spans, m)
+		}
+	}
+	if len(spans) == 0 {
+		return text, hit
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var b strings.Builder
+	last, end := 0, -1
+	for _, m := range spans {
+		if m[0] <= end {
+			end = max(end, m[1])
+			continue
+		}
+		if end >= 0 {
+			b.WriteString(token)
+			last = end
+		}
+		b.WriteString(text[last:m[0]])
+		end = m[1]
+	}
+	b.WriteString(token)
+	b.WriteString(text[end:])
+	return b.String(), hit
+}
+
+// blocklistRules 返回 cfg 中已编译的屏蔽规则；规则已在 validateConfig 中校验，
+// 这里的错误只会来自未经校验的配置，此时不屏蔽
+func blocklistRules(cfg *Config) []*regexp.Regexp {
+	if len(cfg.Blocklist) == 0 {
+		return nil
+	}
+	rules, err := compileBlocklist(cfg.Blocklist)
+	if err != nil {
+		return nil
+	}
+	return rules
+}
+
+// dropBlocked 处理 skip 模式下命中屏蔽规则的消息：计入丢弃并发布丢弃事件，带 reply_to 时回复。
+// 丢弃事件和回复都不含文本，屏蔽的内容不会再发布出去
+func (b *bridge) dropBlocked(topic string, p ttsPayload) {
+	req := speakRequest{Topic: topic, Priority: p.Priority, CorrelationID: p.CorrelationID}
+	if validReplyTopic(p.ReplyTo) {
+		req.ReplyTo = p.ReplyTo
+	}
+	b.queue.dropped([]speakRequest{req}, dropBlocklisted)
+	// 可能在 MQTT 回调中调用，不等待回复的发布确认
+	go b.publishReply(req, errBlocked, 0)
+}
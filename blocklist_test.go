@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+)
+
+func TestApplyBlocklist(t *testing.T) {
+	rules := []*regexp.Regexp{
+		regexp.MustCompile(`密码`),
+		regexp.MustCompile(`密码是\d+`),
+		regexp.MustCompile(`(?i)secret`),
+		regexp.MustCompile(`\d{4}`),
+		regexp.MustCompile(`x*`),
+	}
+	tests := []struct {
+		name    string
+		in      string
+		replace bool
+		want    string
+		hit     int
+	}{
+		{"skip no hit", "门铃响了", false, "门铃响了", -1},
+		{"skip first rule", "密码是1234", false, "密码是1234", 0},
+		{"skip later rule", "Top SECRET", false, "Top SECRET", 2},
+		{"replace no hit", "门铃响了", true, "门铃响了", -1},
+		{"replace single", "这是 secret 文件", true, "这是 *** 文件", 2},
+		{"replace every match", "secret 和 Secret", true, "*** 和 ***", 2},
+		// 前一条规则替换后，后一条规则仍在原文上匹配，数字不会漏读
+		{"replace overlapping", "你的密码是1234。", true, "你的***。", 0},
+		{"replace nested", "secret密码是5678secret", true, "***", 0},
+		{"replace adjacent merged", "密码密码", true, "***", 0},
+		{"replace separate", "密码：0000，备用 secret", true, "***：***，备用 ***", 0},
+		{"replace later rule only", "编号 1234 和 5678", true, "编号 *** 和 ***", 3},
+		{"empty matches ignored", "没有 x 以外的内容", true, "没有 *** 以外的内容", 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hit := applyBlocklist(tt.in, rules, tt.replace, "***")
+			if got != tt.want || hit != tt.hit {
+				t.Errorf("applyBlocklist(%q) = %q, %d，期望 %q, %d", tt.in, got, hit, tt.want, tt.hit)
+			}
+		})
+	}
+}
+
+func TestCompileBlocklist(t *testing.T) {
+	rules, err := compileBlocklist([]string{`密码`, `\d+`})
+	if err != nil || len(rules) != 2 {
+		t.Fatalf("compileBlocklist 返回 %v, %v", rules, err)
+	}
+	if _, err := compileBlocklist([]string{`密码`, `(`}); err == nil {
+		t.Error("无效的正则应返回错误")
+	}
+}
+
+// skip 模式与 replace 模式在同一段文本上匹配：规范化后的原文，
+// 不含数字展开、读音替换的结果，也不含前后缀
+func TestNewSpeakRequestBlocklistSkip(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(cfg *Config)
+		p       ttsPayload
+		blocked bool
+	}{
+		{"no hit", nil, ttsPayload{Text: "门铃响了"}, false},
+		{"hit", nil, ttsPayload{Text: "你的密码是1234"}, true},
+		{"before number expansion", func(cfg *Config) {
+			cfg.Blocklist = []string{`\$\d`}
+			cfg.ExpandNumbers = true
+		}, ttsPayload{Text: "余额 $5,000"}, true},
+		{"before pronunciation", func(cfg *Config) {
+			cfg.Pronunciations = map[string]pronunciation{"密码": {Say: "口令"}}
+		}, ttsPayload{Text: "密码已更新"}, true},
+		{"prefix not matched", func(cfg *Config) {
+			cfg.Blocklist = []string{"注意"}
+			cfg.SpeakPrefix = "注意："
+		}, ttsPayload{Text: "门铃响了"}, false},
+		{"any text in array", nil, ttsPayload{Texts: []string{"门铃响了", "密码是0000"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.Blocklist = []string{"密码"}
+			if tt.setup != nil {
+				tt.setup(cfg)
+			}
+			_, err := newSpeakRequests(cfg, tt.p)
+			if blocked := errors.Is(err, errBlocked); blocked != tt.blocked {
+				t.Errorf("newSpeakRequests 返回 %v，期望屏蔽 %v", err, tt.blocked)
+			}
+		})
+	}
+}
+
+// 被屏蔽的消息计入一次丢弃，丢弃事件不含文本
+func TestDropBlockedCountsMessage(t *testing.T) {
+	b := newTestBridge(defaultConfig())
+	var events []dropEvent
+	b.queue.onDrop = func(req speakRequest, reason string) { events = append(events, newDropEvent(req, reason)) }
+
+	before := metrics.dropped.Load()
+	b.dropBlocked("home/tts", ttsPayload{Text: "密码是0000", CorrelationID: "42"})
+	if got := metrics.dropped.Load() - before; got != 1 || len(events) != 1 {
+		t.Fatalf("dropped 增加 %d，丢弃事件 %d 条，期望各 1", got, len(events))
+	}
+	if ev := events[0]; ev.Reason != dropBlocklisted || ev.Topic != "home/tts" || ev.CorrelationID != "42" || ev.Text != "" {
+		t.Errorf("丢弃事件为 %+v", ev)
+	}
+}
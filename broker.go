@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// brokerSchemes 是 paho 客户端支持、本程序允许使用的 broker 地址协议
+var brokerSchemes = map[string]bool{
+	"tcp": true, "mqtt": true, "ws": true,
+	"ssl": true, "tls": true, "mqtts": true, "mqtt+ssl": true, "tcps": true, "wss": true,
+}
+
+// validateBroker 校验并规范化 broker 地址：
+//   - 不带协议的 host 或 host:port 补全为 tcp://host:port（默认端口 1883）
+//   - 协议统一转为小写（paho 按小写匹配协议）
+//   - tcp/mqtt 缺少端口时补 1883，TLS 协议补 8883；paho 不会自动补全端口
+//   - ws/wss 缺少路径时补 /mqtt：EMQX、HiveMQ 等要求该路径，Mosquitto 接受任意路径；
+//     反向代理使用其他路径时需在地址中写明，如 wss://example.com/broker/mqtt
+//
+// 协议不受支持、缺少主机名或端口超出范围时返回可读的错误，而不是交给 paho 报出难懂的错误
+func validateBroker(broker string) (string, error) {
+	broker = strings.TrimSpace(broker)
+	if broker == "" {
+		return "", fmt.Errorf("broker 地址为空")
+	}
+	if !strings.Contains(broker, "://") {
+		host := broker
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), "1883")
+		}
+		broker = "tcp://" + host
+	}
+
+	u, err := url.Parse(broker)
+	if err != nil {
+		return "", fmt.Errorf("broker 地址 %q 格式无效: %w", broker, err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if !brokerSchemes[scheme] {
+		return "", fmt.Errorf("broker 地址 %q 的协议 %q 不受支持，应为 tcp://、ssl://、ws://、wss://、mqtt:// 或 mqtts://", broker, u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", fmt.Errorf("broker 地址 %q 缺少主机名，示例: tcp://localhost:1883", broker)
+	}
+	u.Scheme = scheme
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return "", fmt.Errorf("broker 地址 %q 的端口 %s 无效，应为 1-65535", broker, port)
+		}
+	}
+
+	switch scheme {
+	case "ws", "wss":
+		// 端口缺省时由 WebSocket 按 80/443 连接
+		if u.Path == "" {
+			u.Path = "/mqtt"
+		}
+	default:
+		if u.Port() == "" {
+			port := "1883"
+			if isTLSBroker(u.String()) {
+				port = "8883"
+			}
+			u.Host = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+	return u.String(), nil
+}
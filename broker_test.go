@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+func TestValidateBroker(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// 缺少协议时补全为 tcp://，缺少端口时补 1883
+		{"192.168.1.100", "tcp://192.168.1.100:1883"},
+		{"broker.local:1884", "tcp://broker.local:1884"},
+		{"[::1]", "tcp://[::1]:1883"},
+		{"  localhost  ", "tcp://localhost:1883"},
+		// 协议转为小写，缺少端口时按协议补全
+		{"TCP://localhost", "tcp://localhost:1883"},
+		{"mqtt://localhost:1883", "mqtt://localhost:1883"},
+		{"ssl://broker.example.com", "ssl://broker.example.com:8883"},
+		{"mqtts://broker.example.com", "mqtts://broker.example.com:8883"},
+		{"tcp://localhost:", "tcp://localhost:1883"},
+		// ws/wss 缺少路径时补 /mqtt，端口由 WebSocket 按 80/443 缺省
+		{"ws://localhost:8080", "ws://localhost:8080/mqtt"},
+		{"ws://localhost:8080/", "ws://localhost:8080/"},
+		{"wss://broker.example.com", "wss://broker.example.com/mqtt"},
+		{"wss://example.com/broker/mqtt", "wss://example.com/broker/mqtt"},
+		{"WS://localhost:9001/ws", "ws://localhost:9001/ws"},
+	}
+	for _, tt := range tests {
+		got, err := validateBroker(tt.in)
+		if err != nil {
+			t.Errorf("validateBroker(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("validateBroker(%q) = %q，期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateBrokerRejects(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"http://localhost:1883",  // 不支持的协议
+		"quic://localhost:14567", // 不支持的协议
+		"tcp://:1883",            // 缺少主机名
+		"ws:///mqtt",             // 缺少主机名
+		"tcp://localhost:abc",    // 端口不是数字
+		"localhost:abc",
+		"tcp://localhost:0",     // 端口超出范围
+		"tcp://localhost:65536", // 端口超出范围
+		"wss://localhost:99999/mqtt",
+		"tcp://local host:1883", // 格式无效
+	} {
+		if got, err := validateBroker(in); err == nil {
+			t.Errorf("validateBroker(%q) = %q，期望报错", in, got)
+		}
+	}
+}
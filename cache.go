@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// audioCache 是磁盘上的合成音频缓存，每条文本对应 dir 下一个 <hash>.wav 文件。
+// 文件的修改时间记录最近一次使用，总大小超过 maxBytes 时按最久未使用淘汰
+type audioCache struct {
+	dir      string
+	maxBytes int64
+	mu       sync.Mutex // 串行化写入与淘汰
+}
+
+// cacheKey 由影响合成结果的参数计算缓存文件名
+func cacheKey(text string, opts speakOptions) string {
+	h := sha256.New()
+	for _, s := range []string{text, opts.Voice, opts.Lang, strconv.Itoa(opts.Rate), strconv.Itoa(opts.Volume), strconv.FormatBool(opts.SSML)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)) + ".wav"
+}
+
+// lookup 返回缓存中可用的文件路径，并刷新其使用时间
+func (c *audioCache) lookup(key string) (string, bool) {
+	path := filepath.Join(c.dir, key)
+	fi, err := os.Stat(path)
+	// 只有 44 字节 WAV 头的文件视为无效
+	if err != nil || fi.Size() <= 44 {
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// store 调用 synth 合成到临时文件，成功后移动为缓存文件并按容量淘汰旧文件
+func (c *audioCache) store(key string, synth func(path string) error) (string, error) {
+	f, err := os.CreateTemp(c.dir, "tmp-*.wav")
+	if err != nil {
+		return "", fmt.Errorf("无法创建缓存文件: %w", err)
+	}
+	f.Close()
+	tmp := f.Name()
+	defer os.Remove(tmp)
+
+	if err := synth(tmp); err != nil {
+		return "", err
+	}
+	if fi, err := os.Stat(tmp); err != nil || fi.Size() <= 44 {
+		return "", fmt.Errorf("合成结果为空，未写入缓存")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	path := filepath.Join(c.dir, key)
+	if err := os.Rename(tmp, path); err != nil {
+		return "", fmt.Errorf("无法写入缓存文件: %w", err)
+	}
+	c.evictLocked(path)
+	return path, nil
+}
+
+// evictLocked 删除最久未使用的缓存文件直到总大小不超过 maxBytes，keep 不会被删除
+func (c *audioCache) evictLocked(keep string) {
+	if c.maxBytes <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type cached struct {
+		path  string
+		size  int64
+		mtime time.Time
+	}
+	var files []cached
+	var total int64
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".wav" {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cached{filepath.Join(c.dir, e.Name()), fi.Size(), fi.ModTime()})
+		total += fi.Size()
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].mtime.Before(files[j].mtime) })
+	for _, f := range files {
+		if total <= c.maxBytes {
+			break
+		}
+		if f.path == keep {
+			continue
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+			debugf("🗑️ 淘汰缓存音频: %s", filepath.Base(f.path))
+		}
+	}
+}
+
+// CachingSpeaker 将 Inner 合成的音频缓存到磁盘，重复的文本直接播放缓存文件，
+// 不再调用合成器；播放使用 Player 的播放器命令（为空时使用系统默认播放方式）。
+// 请求本身要求保存为文件（OutputFile 非空）时直接交给 Inner
+type CachingSpeaker struct {
+	Inner  Speaker
+	Player PlayerSpeaker
+	cache  *audioCache
+}
+
+func (s CachingSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	if opts.OutputFile != "" {
+		return s.Inner.Speak(ctx, text, opts)
+	}
+	key := cacheKey(text, opts)
+	path, ok := s.cache.lookup(key)
+	if ok {
+		debugf("💾 命中音频缓存: %s", key)
+	} else {
+		var err error
+		path, err = s.cache.store(key, func(tmp string) error {
+			o := opts
+			o.OutputFile = tmp
+			return s.Inner.Speak(ctx, text, o)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return s.Player.play(ctx, path)
+}
+
+func (s CachingSpeaker) Voices(ctx context.Context) ([]voiceInfo, error) {
+	if l, ok := s.Inner.(voiceLister); ok {
+		return l.Voices(ctx)
+	}
+	return nil, errVoicesUnsupported
+}
+
+// newCachingSpeaker 在配置了 CacheDir 时用 CachingSpeaker 包装 inner；
+// dry-run 不产生音频，不启用缓存
+func newCachingSpeaker(cfg *Config, inner Speaker) (Speaker, error) {
+	if cfg.CacheDir == "" {
+		return inner, nil
+	}
+	if err := os.MkdirAll(cfg.CacheDir, 0o755); err != nil {
+		return nil, fmt.Errorf("无法创建缓存目录 %q: %w", cfg.CacheDir, err)
+	}
+	log.Printf("💾 音频缓存目录: %s（上限 %d MB）", cfg.CacheDir, cfg.CacheMaxMB)
+	return CachingSpeaker{
+		Inner:  inner,
+		Player: PlayerSpeaker{Command: cfg.PlayerCommand, Device: cfg.AudioDevice},
+		cache:  &audioCache{dir: cfg.CacheDir, maxBytes: int64(cfg.CacheMaxMB) << 20},
+	}, nil
+}
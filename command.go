@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// errVoicesUnsupported 表示当前 TTS 后端无法列出已安装的语音
+var errVoicesUnsupported = errors.New("当前 TTS 后端不支持列出语音")
+
+// commandPayload 是命令主题上的消息，如 {"cmd":"list_voices"}
+type commandPayload struct {
+	Cmd     string `json:"cmd"`
+	Seconds int    `json:"seconds"` // mute 的时长，<= 0 表示直到 unmute
+	Clear   bool   `json:"clear"`   // stop 时同时清空排队中的消息
+}
+
+// commandResult 是命令的执行结果，发布到状态主题
+type commandResult struct {
+	Cmd    string      `json:"cmd"`
+	Voices []voiceInfo `json:"voices,omitempty"`
+	// MutedUntil 为 mute 的截止时间（RFC3339），未指定时长时省略
+	MutedUntil string `json:"muted_until,omitempty"`
+	// Stopped、Cleared 为 stop 停止的朗读数和清除的排队消息数
+	Stopped int `json:"stopped,omitempty"`
+	Cleared int `json:"cleared,omitempty"`
+	// Applied 表示 reload 已应用新配置，Changed 为已生效的配置，
+	// RestartRequired 为已修改但需要重启才能生效的配置
+	Applied         bool     `json:"applied,omitempty"`
+	Changed         []string `json:"changed,omitempty"`
+	RestartRequired []string `json:"restart_required,omitempty"`
+	// Status 为 status 命令返回的完整状态
+	Status *bridgeStatus `json:"status,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// onCommand 是命令主题的消息回调，结果在后台发布到 StatusTopic
+func (b *bridge) onCommand(client mqtt.Client, msg mqtt.Message) {
+	defer msg.Ack()
+	var p commandPayload
+	if err := json.Unmarshal(msg.Payload(), &p); err != nil || p.Cmd == "" {
+		log.Printf("⚠️ 无效的命令 [主题: %s]: %.100q", msg.Topic(), msg.Payload())
+		return
+	}
+	log.Printf("🛠️ 收到命令 [主题: %s]: %s", msg.Topic(), p.Cmd)
+
+	res := commandResult{Cmd: p.Cmd}
+	switch p.Cmd {
+	case "list_voices":
+		// 在单独的 goroutine 中执行，避免枚举语音期间阻塞 MQTT 回调
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			voices, err := listVoices(ctx, b.queue.speaker)
+			if err != nil {
+				log.Printf("❌ 列出语音失败: %v", err)
+				res.Error = err.Error()
+			} else {
+				log.Printf("🗣️ 已安装 %d 个语音", len(voices))
+				res.Voices = voices
+				installedVoices.Set(voices)
+				b.retryMissingVoices()
+			}
+			b.publishCommandResult(client, res)
+		}()
+		return
+	case "mute":
+		until := b.queue.Mute(time.Duration(p.Seconds) * time.Second)
+		if p.Seconds > 0 {
+			res.MutedUntil = until.Format(time.RFC3339)
+			log.Printf("🔇 已静音 %v，至 %s 自动恢复（期间的消息%s）", time.Duration(p.Seconds)*time.Second, until.Format("15:04:05"), b.muteModeText())
+		} else {
+			log.Printf("🔇 已静音，直到收到 unmute（期间的消息%s）", b.muteModeText())
+		}
+	case "stop":
+		res.Stopped, res.Cleared = b.queue.Stop(p.Clear)
+		log.Printf("🛑 已停止 %d 条正在进行的朗读，清除 %d 条排队消息", res.Stopped, res.Cleared)
+	case "reload":
+		// 配置文件可能位于网络共享上，读取较慢，不阻塞 MQTT 回调
+		go func() {
+			b.reloadCommand(client, &res)
+			b.publishCommandResult(client, res)
+		}()
+		return
+	case "status":
+		st := b.status()
+		res.Status = &st
+		log.Printf("📋 状态: 已连接=%v 队列=%d 已朗读=%d", st.Connected, st.QueueDepth, st.Counters.Spoken)
+	case "unmute":
+		if b.queue.Unmute() {
+			log.Println("🔔 已解除静音")
+		}
+	default:
+		log.Printf("⚠️ 未知的命令: %q", p.Cmd)
+		res.Error = "未知的命令"
+	}
+	// 发布时等待 broker 确认，放到 goroutine 中以免阻塞 MQTT 回调
+	go b.publishCommandResult(client, res)
+}
+
+// reloadCommand 立即重新读取配置文件并应用，校验失败时保留旧配置
+func (b *bridge) reloadCommand(client mqtt.Client, res *commandResult) {
+	if b.reload == nil {
+		res.Error = "未使用配置文件启动，无法重新加载"
+		log.Printf("⚠️ %s", res.Error)
+		return
+	}
+	next, err := b.reload()
+	if err != nil {
+		log.Printf("❌ 配置重新加载失败，继续使用旧配置: %v", err)
+		res.Error = err.Error()
+		return
+	}
+	res.Applied = true
+	res.Changed, res.RestartRequired = b.reloadConfig(client, next)
+}
+
+func (b *bridge) publishCommandResult(client mqtt.Client, res commandResult) {
+	topic := b.config().StatusTopic
+	if topic == "" {
+		log.Println("⚠️ 未配置 status_topic，命令结果不会发布")
+		return
+	}
+	publishStatus(client, topic, res)
+}
+
+func (b *bridge) muteModeText() string {
+	if b.config().MuteMode == muteQueue {
+		return "排队，解除后朗读"
+	}
+	return "直接丢弃"
+}
+
+// listVoices 在 speaker 支持时列出已安装的语音
+func listVoices(ctx context.Context, speaker Speaker) ([]voiceInfo, error) {
+	l, ok := speaker.(voiceLister)
+	if !ok {
+		return nil, errVoicesUnsupported
+	}
+	return l.Voices(ctx)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+// 未修改配置文件时，reload 命令的回执不应报告任何变化；未配置 client_id 时
+// 启动时补全的默认值也不能被当作需要重启的修改
+func TestReloadCommandReportsOnlyChangedFields(t *testing.T) {
+	broker := startBroker(t)
+	content := func(timeout int) string {
+		return fmt.Sprintf(`{
+			"broker": %q,
+			"topic": "test/tts",
+			"availability_topic": "test/tts/availability",
+			"status_topic": "test/tts/status",
+			"command_topic": "test/tts/cmd",
+			"voice_refresh_minutes": 0,
+			"tts_timeout_seconds": %d
+		}`, broker.tcp, timeout)
+	}
+	path := writeConfigFile(t, content(10))
+	load := func() (*Config, error) { return resolveConfig(defaultConfig(), path, nil) }
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := broker.subscribe(t, "test/tts/status", 1)
+	startBridgeWith(t, broker, cfg, newRecordingSpeaker(), runDeps{ConfigPath: path, Reload: load})
+
+	reload := func() commandResult {
+		t.Helper()
+		broker.publish(t, "test/tts/cmd", `{"cmd":"reload"}`)
+		for {
+			select {
+			case pk := <-status:
+				var res commandResult
+				if json.Unmarshal(pk.Payload, &res) == nil && res.Cmd == "reload" {
+					return res
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("等待 reload 回执超时")
+			}
+		}
+	}
+
+	res := reload()
+	if !res.Applied || len(res.Changed) != 0 || len(res.RestartRequired) != 0 {
+		t.Errorf("配置未修改，回执为 %+v", res)
+	}
+
+	if err := os.WriteFile(path, []byte(content(20)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	res = reload()
+	if !res.Applied || len(res.Changed) != 1 || res.Changed[0] != "tts_timeout_seconds" || len(res.RestartRequired) != 0 {
+		t.Errorf("只修改了 tts_timeout_seconds，回执为 %+v", res)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "unicode"
+
+// detectCulture 按文字推测朗读语言，用于消息既未指定 voice 也未指定 lang 时：
+// 含假名为 ja-JP，含谚文为 ko-KR，含汉字为 zh-CN，只有拉丁字母为 en-US，无法判断时为空。
+// 中英混排时按中文处理：中文语音能读出其中的英文单词，英文语音却会跳过汉字
+func detectCulture(text string) string {
+	var han, kana, hangul, latin bool
+	for _, r := range text {
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana = true
+		case unicode.Is(unicode.Hangul, r):
+			hangul = true
+		case unicode.Is(unicode.Han, r):
+			han = true
+		case unicode.Is(unicode.Latin, r):
+			latin = true
+		}
+	}
+	switch {
+	case kana:
+		return "ja-JP"
+	case hangul:
+		return "ko-KR"
+	case han:
+		return "zh-CN"
+	case latin:
+		return "en-US"
+	}
+	return ""
+}
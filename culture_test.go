@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestDetectCulture(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"こんにちは", "ja-JP"},
+		{"カタカナ", "ja-JP"},
+		{"東京駅に着きました", "ja-JP"}, // 假名加汉字
+		{"会議は3時からです", "ja-JP"},
+		{"안녕하세요", "ko-KR"},
+		{"서울 Station", "ko-KR"},
+		{"韓國어", "ko-KR"}, // 汉字加谚文
+		{"你好", "zh-CN"},
+		{"会议 3 点开始", "zh-CN"},
+		{"请打开 Living Room 的灯", "zh-CN"}, // 中英混排按中文
+		{"Hello world", "en-US"},
+		{"Café déjà vu", "en-US"},
+		{"12:30", ""},
+		{"!!! ??? ...", ""},
+		{"   ", ""},
+		{"", ""},
+		{"🔔🔔", ""},
+	}
+	for _, tt := range tests {
+		if got := detectCulture(tt.text); got != tt.want {
+			t.Errorf("detectCulture(%q) = %q，期望 %q", tt.text, got, tt.want)
+		}
+	}
+}
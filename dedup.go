@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// dedupFilter 抑制在 window 内重复出现的相同文本（只与上一条比较）
+type dedupFilter struct {
+	mu     sync.Mutex
+	window time.Duration
+	last   string
+	lastAt time.Time
+}
+
+// SetWindow 修改去重时间窗口，<= 0 表示不去重
+func (d *dedupFilter) SetWindow(window time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.window = window
+}
+
+// Allow 返回 text 是否应朗读；允许时记录为最近一条
+func (d *dedupFilter) Allow(text string, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.window <= 0 {
+		return true
+	}
+	if text == d.last && now.Sub(d.lastAt) < d.window {
+		return false
+	}
+	d.last = text
+	d.lastAt = now
+	return true
+}
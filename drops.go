@@ -0,0 +1,55 @@
+package main
+
+import (
+	"time"
+)
+
+// 消息被丢弃的原因，即 dropEvent.Reason
+const (
+	dropQueueFull    = "queue_full"
+	dropRateLimit    = "rate_limit"
+	dropStale        = "stale"
+	dropMuted        = "muted"
+	dropQuietHours   = "quiet_hours"
+	dropGated        = "gated"
+	dropBlocklisted  = "blocklist"
+	dropMissingVoice = "missing_voice"
+)
+
+// dropEvent 是消息未被朗读即丢弃时发布到 DropTopic 的 JSON 事件，
+// 便于上游自动化重试或告警
+type dropEvent struct {
+	Reason        string `json:"reason"`
+	Topic         string `json:"topic,omitempty"` // 消息实际到达的主题，HTTP 请求为空
+	CorrelationID string `json:"correlation_id,omitempty"`
+	// Text 为被丢弃的文本；命中屏蔽规则时省略，避免把屏蔽的内容再发布出去
+	Text      string `json:"text,omitempty"`
+	Priority  int    `json:"priority,omitempty"`
+	Timestamp string `json:"timestamp"` // RFC3339，丢弃时间
+}
+
+func newDropEvent(req speakRequest, reason string) dropEvent {
+	ev := dropEvent{
+		Reason:        reason,
+		Topic:         req.Topic,
+		CorrelationID: req.CorrelationID,
+		Text:          req.Text,
+		Priority:      req.Priority,
+		Timestamp:     time.Now().Format(time.RFC3339),
+	}
+	if reason == dropBlocklisted {
+		ev.Text = ""
+	}
+	return ev
+}
+
+// dropped 为 reqs 中的每条请求计入 tts_dropped_total 并发布丢弃事件
+func (q *speakQueue) dropped(reqs []speakRequest, reason string) {
+	metrics.dropped.Add(uint64(len(reqs)))
+	if q.onDrop == nil {
+		return
+	}
+	for _, req := range reqs {
+		q.onDrop(req, reason)
+	}
+}
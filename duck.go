@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// duckTimeout 为降低或恢复音量的最长等待时间，超时后照常朗读
+const duckTimeout = 10 * time.Second
+
+// DuckingSpeaker 在朗读期间把其他程序的音量降低到原来的 Percent%，读完后恢复，
+// 便于在播放音乐时听清播报。降低音量失败时照常朗读。多个 worker 同时朗读时只在
+// 第一条开始前降低、最后一条结束后恢复；输出到 .wav 文件时不降低音量
+type DuckingSpeaker struct {
+	Inner   Speaker
+	Percent int
+	// DuckCommand 和 RestoreCommand 非空时用这两个命令降低和恢复音量（按空白分割参数，
+	// 不经过 shell，{percent} 为占位符），否则在 Windows 上通过 PowerShell 调整音频会话
+	DuckCommand    string
+	RestoreCommand string
+
+	mu      sync.Mutex
+	active  int
+	restore func() // 恢复音量，未能降低音量时为 nil
+}
+
+func (s *DuckingSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	if opts.OutputFile != "" {
+		return s.Inner.Speak(ctx, text, opts)
+	}
+	s.acquire(ctx)
+	defer s.release()
+	return s.Inner.Speak(ctx, text, opts)
+}
+
+// Voices 列出被包装的 Speaker 的语音
+func (s *DuckingSpeaker) Voices(ctx context.Context) ([]voiceInfo, error) {
+	if l, ok := s.Inner.(voiceLister); ok {
+		return l.Voices(ctx)
+	}
+	return nil, errVoicesUnsupported
+}
+
+func (s *DuckingSpeaker) acquire(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active++
+	if s.active > 1 {
+		return
+	}
+	var err error
+	if s.DuckCommand != "" {
+		err = runDuckCommand(ctx, s.DuckCommand, s.Percent)
+		if err == nil {
+			s.restore = func() {
+				if err := runDuckCommand(context.Background(), s.RestoreCommand, s.Percent); err != nil {
+					log.Printf("⚠️ 恢复音量失败: %v", err)
+				}
+			}
+		}
+	} else {
+		s.restore, err = duckSessions(s.Percent)
+	}
+	if err != nil {
+		log.Printf("⚠️ 降低其他音频的音量失败，照常朗读: %v", err)
+	}
+}
+
+func (s *DuckingSpeaker) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active--
+	if s.active > 0 || s.restore == nil {
+		return
+	}
+	s.restore()
+	s.restore = nil
+}
+
+// runDuckCommand 执行降低或恢复音量的命令，最长等待 duckTimeout
+func runDuckCommand(ctx context.Context, command string, percent int) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, duckTimeout)
+	defer cancel()
+	for i, f := range fields {
+		fields[i] = strings.ReplaceAll(f, "{percent}", strconv.Itoa(percent))
+	}
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	output := &cmdOutputLogger{name: "音量闪避"}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	output.Flush()
+	return err
+}
+
+// duckSessionsScript 把默认播放设备上现有音频会话的音量降低到原来的 {percent}%，
+// 输出 "ducked N"（N 为调整的会话数）后等待标准输入结束，再恢复这些会话的音量；
+// 等待期间被用户手动调整过的会话保持不变。之后才开始朗读的进程（包括朗读本身）
+// 属于新的会话，不受影响，因此不直接调整主音量。桥接器异常退出时标准输入同样会结束，
+// 音量也会恢复
+const duckSessionsScript = `
+try {
+    Add-Type -TypeDefinition '
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+
+[ComImport, Guid("BCDE0395-E52F-467C-8E3D-C4579291692E")]
+class MMDeviceEnumerator {}
+
+[ComImport, Guid("A95664D2-9614-4F35-A746-DE8DB63617E6"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+interface IMMDeviceEnumerator {
+    void EnumAudioEndpoints();
+    IMMDevice GetDefaultAudioEndpoint(int dataFlow, int role);
+}
+
+[ComImport, Guid("D666063F-1587-4E43-81F1-B948E807363F"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+interface IMMDevice {
+    [return: MarshalAs(UnmanagedType.IUnknown)]
+    object Activate(ref Guid iid, int clsCtx, IntPtr activationParams);
+}
+
+[ComImport, Guid("77AA99A0-1BD6-484F-8BC7-2C654C9A9B6F"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+interface IAudioSessionManager2 {
+    void GetAudioSessionControl();
+    void GetSimpleAudioVolume();
+    IAudioSessionEnumerator GetSessionEnumerator();
+}
+
+[ComImport, Guid("E2F5BB11-0570-40CA-ACDD-3AA01277DEE8"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+interface IAudioSessionEnumerator {
+    int GetCount();
+    [return: MarshalAs(UnmanagedType.IUnknown)]
+    object GetSession(int index);
+}
+
+[ComImport, Guid("87CE5498-68D6-44E5-9215-6DA47EF883D8"), InterfaceType(ComInterfaceType.InterfaceIsIUnknown)]
+interface ISimpleAudioVolume {
+    void SetMasterVolume(float level, ref Guid context);
+    float GetMasterVolume();
+}
+
+public static class MediaDucker {
+    static List<ISimpleAudioVolume> sessions = new List<ISimpleAudioVolume>();
+    static List<float> saved = new List<float>();
+    static List<float> ducked = new List<float>();
+
+    public static int Duck(int percent) {
+        IMMDevice device = ((IMMDeviceEnumerator)new MMDeviceEnumerator()).GetDefaultAudioEndpoint(0, 1);
+        Guid iid = typeof(IAudioSessionManager2).GUID;
+        IAudioSessionEnumerator list = ((IAudioSessionManager2)device.Activate(ref iid, 23, IntPtr.Zero)).GetSessionEnumerator();
+        Guid context = Guid.Empty;
+        for (int i = 0; i < list.GetCount(); i++) {
+            try {
+                ISimpleAudioVolume v = (ISimpleAudioVolume)list.GetSession(i);
+                float level = v.GetMasterVolume();
+                float target = level * percent / 100f;
+                v.SetMasterVolume(target, ref context);
+                sessions.Add(v);
+                saved.Add(level);
+                ducked.Add(target);
+            } catch (Exception) {
+            }
+        }
+        return sessions.Count;
+    }
+
+    public static void Restore() {
+        Guid context = Guid.Empty;
+        for (int i = 0; i < sessions.Count; i++) {
+            try {
+                if (Math.Abs(sessions[i].GetMasterVolume() - ducked[i]) < 0.01f) {
+                    sessions[i].SetMasterVolume(saved[i], ref context);
+                }
+            } catch (Exception) {
+            }
+        }
+    }
+}
+'
+    $count = [MediaDucker]::Duck({percent})
+    Write-Output "ducked $count"
+    [Console]::In.ReadToEnd() | Out-Null
+    [MediaDucker]::Restore()
+} catch {
+    Write-Error "❌ 调整音量失败: $($_.Exception.Message)"
+    exit 1
+}
+`
+
+// duckSessions 启动 PowerShell 降低其他音频会话的音量，返回的函数结束该进程以恢复音量
+func duckSessions(percent int) (func(), error) {
+	script := strings.ReplaceAll(duckSessionsScript, "{percent}", strconv.Itoa(percent))
+	cmd := powerShellCommand(context.Background(), script)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out := &duckOutput{ready: make(chan int, 1), log: cmdOutputLogger{name: "音量闪避"}}
+	errOut := &cmdOutputLogger{name: "音量闪避"}
+	cmd.Stdout = out
+	cmd.Stderr = errOut
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := cmd.Wait()
+		out.log.Flush()
+		errOut.Flush()
+		done <- err
+	}()
+	// finish 关闭标准输入让脚本恢复音量并退出，超时则终止进程
+	finish := func() error {
+		stdin.Close()
+		select {
+		case err := <-done:
+			return err
+		case <-time.After(duckTimeout):
+			cmd.Process.Kill()
+			return fmt.Errorf("恢复音量超时（%v）", duckTimeout)
+		}
+	}
+
+	timer := time.NewTimer(duckTimeout)
+	defer timer.Stop()
+	select {
+	case n := <-out.ready:
+		debugf("🎚️ 已降低 %d 个音频会话的音量到 %d%%", n, percent)
+		return func() {
+			if err := finish(); err != nil {
+				log.Printf("⚠️ 恢复音量失败: %v", err)
+			}
+		}, nil
+	case err := <-done:
+		if err == nil {
+			err = errors.New("PowerShell 未调整音量即退出")
+		}
+		return nil, err
+	case <-timer.C:
+		// 脚本可能稍后才降低音量，标准输入已关闭，它会随即恢复并退出
+		go finish()
+		return nil, fmt.Errorf("降低音量超时（%v）", duckTimeout)
+	}
+}
+
+// duckOutput 接收 duckSessionsScript 的标准输出："ducked N" 行把 N 送入 ready，
+// 其余内容写入日志
+type duckOutput struct {
+	ready chan int
+	log   cmdOutputLogger
+	buf   []byte
+}
+
+func (o *duckOutput) Write(p []byte) (int, error) {
+	o.buf = append(o.buf, p...)
+	for {
+		i := bytes.IndexByte(o.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := strings.TrimSpace(string(o.buf[:i]))
+		o.buf = o.buf[i+1:]
+		if n, ok := strings.CutPrefix(line, "ducked "); ok {
+			if count, err := strconv.Atoi(n); err == nil {
+				select {
+				case o.ready <- count:
+				default:
+				}
+				continue
+			}
+		}
+		o.log.logLine([]byte(line))
+	}
+	return len(p), nil
+}
+
+// newDuckingSpeaker 在配置了 DuckMediaPercent 时用 DuckingSpeaker 包装 inner，否则原样返回
+func newDuckingSpeaker(cfg *Config, inner Speaker) Speaker {
+	if cfg.DuckMediaPercent <= 0 {
+		return inner
+	}
+	log.Printf("🎚️ 朗读时其他音频的音量降低到 %d%%", cfg.DuckMediaPercent)
+	return &DuckingSpeaker{
+		Inner:          inner,
+		Percent:        cfg.DuckMediaPercent,
+		DuckCommand:    cfg.DuckCommand,
+		RestoreCommand: cfg.DuckRestoreCommand,
+	}
+}
+
+// validateDucking 检查音量闪避的配置
+func validateDucking(cfg *Config) error {
+	if cfg.DuckMediaPercent < 0 || cfg.DuckMediaPercent >= 100 {
+		return fmt.Errorf("无效的 duck_media_percent %d，应在 1 到 99 之间（0 表示不降低音量）", cfg.DuckMediaPercent)
+	}
+	if (cfg.DuckCommand == "") != (cfg.DuckRestoreCommand == "") {
+		return errors.New("duck_command 和 duck_restore_command 需要同时配置")
+	}
+	if cfg.DuckMediaPercent > 0 && cfg.DuckCommand == "" && runtime.GOOS != "windows" {
+		return errors.New("duck_media_percent 在非 Windows 系统上需要配置 duck_command 和 duck_restore_command")
+	}
+	return nil
+}
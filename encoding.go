@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// 消息体编码
+const (
+	encodingPlain  = "plain"  // 默认，UTF-8 文本或 JSON
+	encodingBase64 = "base64" // base64 编码的 UTF-8（可省略填充，解码后为 gzip 数据时自动解压）
+	encodingGzip   = "gzip"   // gzip 压缩的 UTF-8
+)
+
+// maxDecodedSize 限制解码或解压后的大小，防止压缩炸弹
+const maxDecodedSize = 1 << 20
+
+// errDecode 表示消息体无法按声明的编码解码
+var errDecode = errors.New("消息解码失败")
+
+// decodePayload 按 encoding 解码消息体，再按 charset（为空时即 UTF-8）转换为 UTF-8，
+// 结果必须是有效的 UTF-8
+func decodePayload(data []byte, encoding, charset string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "", encodingPlain:
+		if isUTF8Charset(charset) {
+			return data, nil
+		}
+	case encodingBase64:
+		decoded, err := decodeBase64(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的 base64: %v", errDecode, err)
+		}
+		data = decoded
+		if !isGzip(data) {
+			break
+		}
+		fallthrough
+	case encodingGzip:
+		decoded, err := gunzip(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 无效的 gzip 数据: %v", errDecode, err)
+		}
+		data = decoded
+	default:
+		return nil, fmt.Errorf("%w: 未知的编码 %q", errDecode, encoding)
+	}
+	data, err := decodeCharset(data, charset)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("%w: 解码结果不是有效的 UTF-8 文本", errDecode)
+	}
+	return data, nil
+}
+
+// isUTF8Charset 判断 charset 是否表示 UTF-8（为空时同样视为 UTF-8）
+func isUTF8Charset(charset string) bool {
+	switch strings.ToLower(strings.TrimSpace(charset)) {
+	case "", "utf-8", "utf8":
+		return true
+	}
+	return false
+}
+
+// validCharset 检查 charset 是否为支持的字符集名称（WHATWG 名称或别名，如 gbk、gb18030、big5、shift_jis）
+func validCharset(charset string) error {
+	if isUTF8Charset(charset) {
+		return nil
+	}
+	_, err := htmlindex.Get(charset)
+	return err
+}
+
+// decodeCharset 将 charset 编码的 data 转换为 UTF-8，charset 为 UTF-8 时原样返回。
+// 无法解码的字节替换为 U+FFFD
+func decodeCharset(data []byte, charset string) ([]byte, error) {
+	if isUTF8Charset(charset) {
+		return data, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 未知的字符集 %q", errDecode, charset)
+	}
+	out, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 无法按 %s 解码: %v", errDecode, charset, err)
+	}
+	return out, nil
+}
+
+// decodeBase64 接受标准和 URL 安全字母表，填充可有可无，忽略其中的空白和换行
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	s = strings.TrimRight(s, "=")
+	if strings.ContainsAny(s, "-_") {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+	return base64.RawStdEncoding.DecodeString(s)
+}
+
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxDecodedSize {
+		return nil, fmt.Errorf("解压后超过 %d 字节", maxDecodedSize)
+	}
+	return out, nil
+}
+
+// decodePayloadText 按消息中的 encoding 字段解码 text 和 texts
+func decodePayloadText(p *ttsPayload) error {
+	if p.Encoding == "" {
+		return nil
+	}
+	decode := func(s string) (string, error) {
+		b, err := decodePayload([]byte(s), p.Encoding, "")
+		return string(b), err
+	}
+	var err error
+	if p.Text, err = decode(p.Text); err != nil {
+		return err
+	}
+	for i, t := range p.Texts {
+		if p.Texts[i], err = decode(t); err != nil {
+			return fmt.Errorf("texts[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
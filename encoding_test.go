@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodePayloadCharset(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		charset string
+		want    string
+	}{
+		{"gbk", "\xc4\xe3\xba\xc3", "gbk", "你好"},
+		{"gbk uppercase", "\xc4\xe3\xba\xc3", "GBK", "你好"},
+		{"gb2312 alias", "\xc4\xe3\xba\xc3", "gb2312", "你好"},
+		{"gb18030", "\xc4\xe3\xba\xc3", "gb18030", "你好"},
+		{"big5", "\xa7\x41\xa6\x6e", "big5", "你好"},
+		{"gbk json", "{\"text\":\"\xc3\xc5\xc1\xe5\"}", "gbk", `{"text":"门铃"}`},
+		{"ascii unchanged", "hello", "gbk", "hello"},
+		{"utf-8", "你好", "utf-8", "你好"},
+		{"default utf-8", "你好", "", "你好"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodePayload([]byte(tt.data), "", tt.charset)
+			if err != nil {
+				t.Fatalf("decodePayload: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("得到 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePayloadUnknownCharset(t *testing.T) {
+	_, err := decodePayload([]byte("\xc4\xe3"), "", "klingon")
+	if !errors.Is(err, errDecode) {
+		t.Errorf("未知字符集返回 %v，期望 errDecode", err)
+	}
+	if err := validCharset("klingon"); err == nil {
+		t.Error("validCharset 接受了未知的字符集")
+	}
+	for _, charset := range []string{"gbk", "GB18030", "big5", "shift_jis", "utf8", ""} {
+		if err := validCharset(charset); err != nil {
+			t.Errorf("validCharset(%q): %v", charset, err)
+		}
+	}
+}
+
+func TestDecodeBase64(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"padded", "5L2g5aW9IQ==", "你好!"},
+		{"unpadded", "5L2g5aW9IQ", "你好!"},
+		{"no padding needed", "5L2g5aW9", "你好"},
+		{"standard alphabet", "6Zeo6ZOD5ZON5LqGPz8+", "门铃响了??>"},
+		{"url-safe", "6Zeo6ZOD5ZON5LqGPz8-", "门铃响了??>"},
+		{"url-safe padded", "5aW9P_8=", "好?\xff"},
+		{"whitespace and newlines", " 5L2g\n5aW9\r\nIQ== ", "你好!"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBase64(tt.in)
+			if err != nil {
+				t.Fatalf("decodeBase64(%q): %v", tt.in, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeBase64(%q) = %q，期望 %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBase64Invalid(t *testing.T) {
+	for _, in := range []string{
+		"5L2g5aW9I",    // 长度不合法
+		"not base64!",  // 非法字符
+		"6Zeo6ZOD+-_/", // 混用两种字母表
+		"5L2g=5aW9",    // 填充在中间
+	} {
+		if got, err := decodeBase64(in); err == nil {
+			t.Errorf("decodeBase64(%q) = %q，期望报错", in, got)
+		}
+	}
+}
+
+func TestDecodePayloadBase64(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte("洗衣机已完成"))
+	w.Close()
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"text", "5L2g5aW9IQ==", "你好!"},
+		{"gzip inside base64", base64.StdEncoding.EncodeToString(gz.Bytes()), "洗衣机已完成"},
+		{"gzip inside unpadded url-safe base64", base64.RawURLEncoding.EncodeToString(gz.Bytes()), "洗衣机已完成"},
+	}
+	for _, tt := range tests {
+		got, err := decodePayload([]byte(tt.data), encodingBase64, "")
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: decodePayload = %q, %v，期望 %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := decodePayload([]byte("not base64!"), encodingBase64, ""); !errors.Is(err, errDecode) {
+		t.Errorf("无效的 base64 返回 %v，期望 errDecode", err)
+	}
+	// 解码结果不是 UTF-8 文本时拒绝
+	if _, err := decodePayload([]byte("//7lpb0="), encodingBase64, ""); !errors.Is(err, errDecode) {
+		t.Errorf("非 UTF-8 的解码结果返回 %v，期望 errDecode", err)
+	}
+}
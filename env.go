@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envPrefix 是配置环境变量的前缀，变量名为 TTS_ 加上配置文件键名的大写形式，
+// 如 broker -> TTS_BROKER、tts_timeout_seconds -> TTS_TTS_TIMEOUT_SECONDS
+const envPrefix = "TTS_"
+
+// loadConfigFromEnv 读取 TTS_* 环境变量，返回以 base 为基础、
+// 仅覆盖已设置（且非空）的变量对应字段后的新配置。
+// 优先级位于配置文件与命令行参数之间，适合 Docker / systemd 部署传入密码等敏感信息
+func loadConfigFromEnv(base *Config) (*Config, error) {
+	cfg := *base
+	var err error
+	var broker string
+	envString("BROKER", &broker)
+	if brokers := splitList(broker); len(brokers) > 0 {
+		cfg.Brokers = brokers
+	}
+	var topic string
+	envString("TOPIC", &topic)
+	if topics := splitList(topic); len(topics) > 0 {
+		cfg.Topics = topics
+	}
+	envInt("QOS", &cfg.QoS, &err)
+	envBool("IGNORE_RETAINED", &cfg.IgnoreRetained, &err)
+	envBool("MANUAL_ACK", &cfg.ManualAck, &err)
+	envString("PAYLOAD_ENCODING", &cfg.PayloadEncoding)
+	envString("PAYLOAD_CHARSET", &cfg.PayloadCharset)
+	envString("TEMPLATE", &cfg.Template)
+	var filter string
+	envString("PAYLOAD_FILTER", &filter)
+	if list := splitList(filter); len(list) > 0 {
+		cfg.PayloadFilter = list
+	}
+	envBool("PAYLOAD_FILTER_REQUIRE_JSON", &cfg.PayloadFilterRequireJSON, &err)
+	envInt("PROTOCOL_VERSION", &cfg.ProtocolVersion, &err)
+	envString("USERNAME", &cfg.Username)
+	envString("PASSWORD", &cfg.Password)
+	envString("CLIENT_ID", &cfg.ClientID)
+	envInt("RATE", &cfg.Rate, &err)
+	envInt("VOLUME", &cfg.Volume, &err)
+	envInt("MAX_TEXT_LENGTH", &cfg.MaxTextLength, &err)
+	envBool("SPLIT_LONG_TEXT", &cfg.SplitLongText, &err)
+	envInt("CHUNK_PAUSE_MS", &cfg.ChunkPauseMs, &err)
+	envBool("KEEP_CHUNKS_TOGETHER", &cfg.KeepChunksTogether, &err)
+	envInt("INTER_UTTERANCE_GAP_MS", &cfg.InterUtteranceGapMs, &err)
+	envString("SPEAK_PREFIX", &cfg.SpeakPrefix)
+	envString("SPEAK_SUFFIX", &cfg.SpeakSuffix)
+	envInt("SPEAK_TOPIC_SEGMENT", &cfg.SpeakTopicSegment, &err)
+	envBool("SPEAK_TOPIC_HUMANIZE", &cfg.SpeakTopicHumanize, &err)
+	envInt("QUEUE_SIZE", &cfg.QueueSize, &err)
+	envInt("WORKERS", &cfg.Workers, &err)
+	envBool("QUEUE_DROP_OLDEST", &cfg.QueueDropOldest, &err)
+	envBool("PREEMPT", &cfg.Preempt, &err)
+	envInt("TTS_TIMEOUT_SECONDS", &cfg.TTSTimeoutSeconds, &err)
+	envInt("SPEAK_RETRIES", &cfg.SpeakRetries, &err)
+	envInt("SPEAK_RETRY_DELAY_MS", &cfg.SpeakRetryDelayMs, &err)
+	envBool("RETRY_ON_TIMEOUT", &cfg.RetryOnTimeout, &err)
+	envInt("MAX_AGE_SECONDS", &cfg.MaxAgeSeconds, &err)
+	envInt("STATS_INTERVAL_MINUTES", &cfg.StatsIntervalMinutes, &err)
+	envInt("VOICE_ROTATION_SEED", &cfg.VoiceRotationSeed, &err)
+	envInt("VOICE_REFRESH_MINUTES", &cfg.VoiceRefreshMinutes, &err)
+	envBool("RETRY_MISSING_VOICE", &cfg.RetryMissingVoice, &err)
+	envInt("FAILURE_THRESHOLD", &cfg.FailureThreshold, &err)
+	envString("REMEDIATION_COMMAND", &cfg.RemediationCommand)
+	envInt("RECONNECT_MAX_SECONDS", &cfg.ReconnectMaxSeconds, &err)
+	envBool("ANNOUNCE_RECONNECT", &cfg.AnnounceReconnect, &err)
+	envString("RECONNECT_PHRASE", &cfg.ReconnectPhrase)
+	envInt("CONNECT_TIMEOUT_SECONDS", &cfg.ConnectTimeoutSeconds, &err)
+	envInt("STARTUP_JITTER_MS", &cfg.StartupJitterMs, &err)
+	envInt("SUBSCRIBE_TIMEOUT_SECONDS", &cfg.SubscribeTimeoutSeconds, &err)
+	envString("CA_FILE", &cfg.CAFile)
+	envString("CLIENT_CERT_FILE", &cfg.ClientCertFile)
+	envString("CLIENT_KEY_FILE", &cfg.ClientKeyFile)
+	envString("CLIENT_PFX_FILE", &cfg.ClientPFXFile)
+	envString("CLIENT_PFX_PASSWORD", &cfg.ClientPFXPassword)
+	envBool("INSECURE_SKIP_VERIFY", &cfg.InsecureSkipVerify, &err)
+	envString("STATUS_TOPIC", &cfg.StatusTopic)
+	var publishers string
+	envString("ALLOWED_PUBLISHERS", &publishers)
+	if list := splitList(publishers); len(list) > 0 {
+		cfg.AllowedPublishers = list
+	}
+	envString("PUBLISHER_PROPERTY", &cfg.PublisherProperty)
+	envInt("PUBLISHER_TOPIC_LEVEL", &cfg.PublisherTopicLevel, &err)
+	envString("COMMAND_TOPIC", &cfg.CommandTopic)
+	envString("EVENTS_TOPIC", &cfg.EventsTopic)
+	envString("DROP_TOPIC", &cfg.DropTopic)
+	envString("NOW_SPEAKING_TOPIC", &cfg.NowSpeakingTopic)
+	envString("MUTE_MODE", &cfg.MuteMode)
+	envString("QUIET_HOURS", &cfg.QuietHours)
+	envInt("QUIET_HOURS_BYPASS_PRIORITY", &cfg.QuietHoursBypassPriority, &err)
+	envString("GATE_TOPIC", &cfg.GateTopic)
+	envString("GATE_MATCH_VALUE", &cfg.GateMatchValue)
+	envString("WILL_TOPIC", &cfg.WillTopic)
+	envString("WILL_PAYLOAD", &cfg.WillPayload)
+	envBool("WILL_RETAIN", &cfg.WillRetain, &err)
+	envString("AVAILABILITY_TOPIC", &cfg.AvailabilityTopic)
+	envString("ONLINE_PAYLOAD", &cfg.OnlinePayload)
+	envString("UNHEALTHY_PAYLOAD", &cfg.UnhealthyPayload)
+	envString("HTTP_ADDR", &cfg.HTTPAddr)
+	envString("SPEAKER", &cfg.Speaker)
+	envString("POWERSHELL_PATH", &cfg.PowerShellPath)
+	envString("AZURE_KEY", &cfg.AzureKey)
+	envString("AZURE_REGION", &cfg.AzureRegion)
+	envString("AZURE_VOICE", &cfg.AzureVoice)
+	envInt("DEDUP_SECONDS", &cfg.DedupSeconds, &err)
+	envInt("RATE_LIMIT_PER_MINUTE", &cfg.RateLimitPerMinute, &err)
+	envInt("RATE_LIMIT_BURST", &cfg.RateLimitBurst, &err)
+	envBool("DEBUG", &cfg.Debug, &err)
+	envBool("SELFTEST_ON_START", &cfg.SelfTestOnStart, &err)
+	envString("ON_EMPTY_TEXT", &cfg.OnEmptyText)
+	envString("EMPTY_TEXT_PHRASE", &cfg.EmptyTextPhrase)
+	envBool("NORMALIZE", &cfg.Normalize, &err)
+	envString("NORMALIZE_MODE", &cfg.NormalizeMode)
+	envBool("EXPAND_NUMBERS", &cfg.ExpandNumbers, &err)
+	envString("NUMBER_LOCALE", &cfg.NumberLocale)
+	envBool("DETECT_CULTURE", &cfg.DetectCulture, &err)
+	envString("DEFAULT_CULTURE", &cfg.DefaultCulture)
+	envString("BLOCKLIST_MODE", &cfg.BlocklistMode)
+	envString("BLOCKLIST_TOKEN", &cfg.BlocklistToken)
+	envString("OUTPUT_DIR", &cfg.OutputDir)
+	envString("TEXT_FILE_DIR", &cfg.TextFileDir)
+	envString("PLAYER_COMMAND", &cfg.PlayerCommand)
+	envString("AUDIO_DEVICE", &cfg.AudioDevice)
+	envInt("DUCK_MEDIA_PERCENT", &cfg.DuckMediaPercent, &err)
+	envString("DUCK_COMMAND", &cfg.DuckCommand)
+	envString("DUCK_RESTORE_COMMAND", &cfg.DuckRestoreCommand)
+	envString("AUDIO_TOPIC", &cfg.AudioTopic)
+	envInt("AUDIO_CHUNK_SIZE", &cfg.AudioChunkSize, &err)
+	envString("CACHE_DIR", &cfg.CacheDir)
+	envInt("CACHE_MAX_MB", &cfg.CacheMaxMB, &err)
+	envString("LOG_FORMAT", &cfg.LogFormat)
+	envInt("MAX_LOG_SIZE_MB", &cfg.MaxLogSizeMB, &err)
+	envInt("LOG_BACKUPS", &cfg.LogBackups, &err)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// envLookup 返回 TTS_<name> 去掉首尾空白后的值，未设置或为空时 ok 为 false
+func envLookup(name string) (string, bool) {
+	v := strings.TrimSpace(os.Getenv(envPrefix + name))
+	return v, v != ""
+}
+
+// envString 在 TTS_<name> 非空时写入 dst
+func envString(name string, dst *string) {
+	if v, ok := envLookup(name); ok {
+		*dst = v
+	}
+}
+
+// envInt 在 TTS_<name> 非空时按整数解析写入 dst，解析失败时记录到 *errp（只保留第一个错误）
+func envInt(name string, dst *int, errp *error) {
+	v, ok := envLookup(name)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		if *errp == nil {
+			*errp = fmt.Errorf("环境变量 %s%s=%q 不是有效的整数", envPrefix, name, v)
+		}
+		return
+	}
+	*dst = n
+}
+
+// envBool 在 TTS_<name> 非空时按布尔值（true/false/1/0 等）解析写入 dst，
+// 解析失败时记录到 *errp（只保留第一个错误）
+func envBool(name string, dst *bool, errp *error) {
+	v, ok := envLookup(name)
+	if !ok {
+		return
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		if *errp == nil {
+			*errp = fmt.Errorf("环境变量 %s%s=%q 不是有效的布尔值", envPrefix, name, v)
+		}
+		return
+	}
+	*dst = b
+}
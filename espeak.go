@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// LinuxSpeaker 使用 espeak-ng 朗读
+type LinuxSpeaker struct{}
+
+func (LinuxSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	log.Printf("🔊 尝试朗读文本 (长度=%d): %.50q", len(text), text)
+
+	args := []string{
+		"-s", fmt.Sprint(wordsPerMinute(opts.Rate)),
+		"-a", fmt.Sprint(opts.Volume), // 振幅 0..200，100 为默认音量
+	}
+	if opts.Voice != "" {
+		args = append(args, "-v", opts.Voice)
+	} else if opts.Lang != "" {
+		// espeak-ng 的语音以小写语言代码命名，如 en-us
+		args = append(args, "-v", strings.ToLower(opts.Lang))
+	}
+	if opts.SSML {
+		args = append(args, "-m")
+	}
+	if opts.OutputFile != "" {
+		args = append(args, "-w", opts.OutputFile)
+	}
+	// 文本通过 stdin 传入，避免以 - 开头的文本被当作参数
+	args = append(args, "--stdin")
+
+	cmd := exec.CommandContext(ctx, "espeak-ng", args...)
+	cmd.Stdin = strings.NewReader(text)
+	return runTTSCommand(ctx, "espeak-ng", cmd)
+}
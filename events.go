@@ -0,0 +1,64 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// 连接状态事件
+const (
+	eventConnected    = "connected"
+	eventDisconnected = "disconnected"
+	eventReconnecting = "reconnecting"
+)
+
+// maxPendingEvents 为断线期间最多缓存的事件数，超出时丢弃最旧的事件
+const maxPendingEvents = 100
+
+// connEvent 是发布到 EventsTopic 的连接状态事件
+type connEvent struct {
+	Event     string `json:"event"`
+	Broker    string `json:"broker"`
+	Reason    string `json:"reason,omitempty"`  // disconnected 的原因
+	Attempt   int    `json:"attempt,omitempty"` // reconnecting 的重连次数，从 1 开始
+	Timestamp string `json:"timestamp"`         // RFC3339，事件发生的时间
+}
+
+// eventPublisher 发布连接状态事件。断线期间无法发布，disconnected、reconnecting
+// 等事件先缓存，连接恢复后在 connected 之前按发生顺序补发，时间戳为实际发生的时间
+type eventPublisher struct {
+	mu      sync.Mutex
+	topic   string
+	pending []connEvent
+}
+
+// Publish 记录一个事件并在已连接时发布；topic 为空时不做任何事
+func (p *eventPublisher) Publish(client mqtt.Client, event, broker, reason string, attempt int) {
+	if p.topic == "" {
+		return
+	}
+	ev := connEvent{
+		Event:     event,
+		Broker:    broker,
+		Reason:    reason,
+		Attempt:   attempt,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending = append(p.pending, ev)
+	if n := len(p.pending) - maxPendingEvents; n > 0 {
+		log.Printf("⚠️ 断线期间的连接事件过多，丢弃最旧的 %d 条", n)
+		p.pending = p.pending[n:]
+	}
+	if !client.IsConnected() {
+		return
+	}
+	for _, ev := range p.pending {
+		publishStatus(client, p.topic, ev)
+	}
+	p.pending = nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// remediationTimeout 为补救命令的最长执行时间
+const remediationTimeout = 60 * time.Second
+
+// failureTracker 统计连续的 TTS 失败（超时或出错；被打断、停止、取消的朗读不计入）。
+// 连续失败达到 threshold 次时进入不健康状态并调用 onUnhealthy，之后每再失败 threshold 次
+// 再调用一次；下一次朗读成功时清零并调用 onRecovered。threshold <= 0 表示不检测
+type failureTracker struct {
+	mu        sync.Mutex
+	threshold int
+	count     int
+	unhealthy bool
+
+	// 两个回调都在 worker 中同步执行，onUnhealthy 返回前该 worker 不会朗读下一条
+	onUnhealthy func(ctx context.Context, count int)
+	onRecovered func()
+}
+
+// SetThreshold 修改触发不健康状态的连续失败次数，从下一次失败开始生效
+func (t *failureTracker) SetThreshold(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.threshold = n
+}
+
+// Status 返回是否处于不健康状态以及当前的连续失败次数
+func (t *failureTracker) Status() (unhealthy bool, count int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.unhealthy, t.count
+}
+
+// observe 记录一次朗读结果
+func (t *failureTracker) observe(ctx context.Context, err error) {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, errPreempted), errors.Is(err, errStopped):
+		return
+	case err == nil:
+		t.mu.Lock()
+		recovered := t.unhealthy
+		t.count, t.unhealthy = 0, false
+		t.mu.Unlock()
+		if recovered {
+			log.Println("💚 TTS 已恢复正常")
+			if t.onRecovered != nil {
+				t.onRecovered()
+			}
+		}
+		return
+	}
+
+	t.mu.Lock()
+	t.count++
+	count := t.count
+	trigger := t.threshold > 0 && count%t.threshold == 0
+	if trigger {
+		t.unhealthy = true
+	}
+	t.mu.Unlock()
+	if trigger {
+		log.Printf("🚨 TTS 连续失败 %d 次，音频子系统可能已挂起", count)
+		if t.onUnhealthy != nil {
+			t.onUnhealthy(ctx, count)
+		}
+	}
+}
+
+// runRemediation 执行补救命令（如重启 Windows 音频服务），按空白分割参数，不经过 shell
+func runRemediation(ctx context.Context, command string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, remediationTimeout)
+	defer cancel()
+
+	log.Printf("🩺 正在执行补救命令: %s", command)
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, fields[0], fields[1:]...)
+	output := &cmdOutputLogger{name: "补救命令"}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = 2 * time.Second
+	err := cmd.Run()
+	output.Flush()
+	if err != nil {
+		log.Printf("❌ 补救命令执行失败（耗时: %v）: %v", time.Since(start), err)
+		return
+	}
+	log.Printf("🩺 补救命令已完成（耗时: %v），继续朗读", time.Since(start))
+}
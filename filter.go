@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// payloadMatches 判断解码后的消息体是否满足 cfg.PayloadFilter 中的全部条件。
+// 条件形如 key=value，key 可用 . 引用嵌套字段或数组下标（如 event.type=doorbell、
+// items.0.kind=alarm）；字段为字符串时与 value 直接比较，其余类型按 JSON 文本比较
+// （如 speak=true、level=3、owner=null）。消息体不是 JSON 对象时，
+// PayloadFilterRequireJSON 为 false 则不过滤，否则视为不满足
+func payloadMatches(cfg *Config, body []byte) (bool, string) {
+	if len(cfg.PayloadFilter) == 0 {
+		return true, ""
+	}
+	var data map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil || dec.More() {
+		if cfg.PayloadFilterRequireJSON {
+			return false, "消息体不是 JSON 对象"
+		}
+		return true, ""
+	}
+	for _, cond := range cfg.PayloadFilter {
+		key, want, _ := strings.Cut(cond, "=")
+		got, ok := lookupPath(data, strings.TrimSpace(key))
+		if !ok {
+			return false, fmt.Sprintf("缺少字段 %s", strings.TrimSpace(key))
+		}
+		if filterValue(got) != strings.TrimSpace(want) {
+			return false, fmt.Sprintf("不满足条件 %s", cond)
+		}
+	}
+	return true, ""
+}
+
+// lookupPath 按 . 分隔的路径取出 data 中的字段
+func lookupPath(data interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return nil, false
+			}
+			data = next
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			data = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return data, true
+}
+
+// filterValue 返回字段用于比较的文本：字符串为其本身，其余为 JSON 文本
+func filterValue(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// validatePayloadFilter 检查 PayloadFilter 中的每个条件都是 key=value
+func validatePayloadFilter(filter []string) error {
+	for _, cond := range filter {
+		key, _, ok := strings.Cut(cond, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("无效的 payload_filter 条件 %q，应为 key=value（如 speak=true）", cond)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// gateState 缓存 GateTopic 上最近一次收到的值（通常是保留消息），nil 表示尚未收到
+type gateState struct {
+	value atomic.Pointer[string]
+}
+
+// onGate 是 GateTopic 的消息回调，只记录最新的值
+func (b *bridge) onGate(client mqtt.Client, msg mqtt.Message) {
+	defer msg.Ack()
+	v := strings.TrimSpace(string(msg.Payload()))
+	if old := b.gate.value.Swap(&v); old == nil || *old != v {
+		log.Printf("🚪 门控状态 [主题: %s]: %q", msg.Topic(), v)
+	}
+}
+
+// gated 判断此刻是否因门控而不朗读，返回原因；未配置 GateTopic 时总是放行。
+// 值与 GateMatchValue 比较时忽略大小写，尚未收到任何值时视为不匹配
+func (b *bridge) gated() (string, bool) {
+	cfg := b.config()
+	if cfg.GateTopic == "" {
+		return "", false
+	}
+	v := b.gate.value.Load()
+	if v == nil {
+		return "尚未收到 " + cfg.GateTopic + " 的状态", true
+	}
+	if !strings.EqualFold(*v, cfg.GateMatchValue) {
+		return cfg.GateTopic + " 为 " + *v + "，不是 " + cfg.GateMatchValue, true
+	}
+	return "", false
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// newHTTPHandler 返回 HTTP 接口：
+//
+//	POST /say {"text":"...","voice":"...","rate":0,"volume":100}
+//	POST /say {"texts":["...","..."]}
+//	POST /say {"file":"announcements/evacuation.txt"}
+//	POST /say {"severity":"critical","message":"..."}
+//	GET  /healthz
+//	GET  /metrics
+//
+// /say 的请求与 MQTT 消息进入同一个朗读队列，入队后立即返回 202；
+// /healthz 在 MQTT 已连接且 TTS 未连续失败时返回 200，否则返回 503；
+// /metrics 以 Prometheus 文本格式导出消息、朗读和队列相关指标
+func newHTTPHandler(b *bridge) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /say", func(w http.ResponseWriter, r *http.Request) {
+		var p ttsPayload
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "请求体不是有效的 JSON: " + err.Error()})
+			return
+		}
+		if p.Text == "" {
+			p.Text = p.Message
+		}
+		if err := decodePayloadText(&p); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		p = applySeverity(b.config().Severities, p)
+		reqs, err := newSpeakRequests(b.config(), p)
+		if errors.Is(err, errBlocked) {
+			b.dropBlocked("", p)
+			writeJSON(w, http.StatusOK, map[string]string{"status": "blocked"})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		log.Printf("收到 HTTP 朗读请求 [%s]: %.50q（共 %d 段）", r.RemoteAddr, reqs[0].Text, len(reqs))
+		b.state.touchMessage()
+		switch err := b.enqueue(reqs...); {
+		case errors.Is(err, errDuplicate):
+			writeJSON(w, http.StatusOK, map[string]string{"status": "duplicate"})
+			return
+		case errors.Is(err, errMuted):
+			writeJSON(w, http.StatusOK, map[string]string{"status": "muted"})
+			return
+		case errors.Is(err, errQuiet):
+			writeJSON(w, http.StatusOK, map[string]string{"status": "quiet_hours"})
+			return
+		case errors.Is(err, errGated):
+			writeJSON(w, http.StatusOK, map[string]string{"status": "gated"})
+			return
+		case errors.Is(err, errThrottled):
+			writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": err.Error()})
+			return
+		case err != nil:
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "queued"})
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		h := b.health()
+		code := http.StatusOK
+		if !h.Connected || !h.Healthy {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, h)
+	})
+	mux.HandleFunc("GET /metrics", metricsHandler(b))
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// runHTTPServer 在 addr 上提供 handler，ctx 结束时关闭服务
+func runHTTPServer(ctx context.Context, addr string, handler http.Handler) {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("🌐 HTTP 接口已启动: %s", addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("❌ HTTP 接口启动失败: %v", err)
+	}
+}
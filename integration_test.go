@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+// 集成测试在进程内启动 mochi-mqtt broker，用真实的 paho 客户端连接桥接器，
+// 通过 broker 的内联客户端发布消息和观察桥接器发布的内容，不需要外部 broker
+
+func TestMain(m *testing.M) {
+	// 桥接器的日志很多，只在 -v 时输出
+	flag.Parse()
+	if !testing.Verbose() {
+		log.SetOutput(io.Discard)
+	}
+	os.Exit(m.Run())
+}
+
+// testBroker 是进程内的 MQTT broker
+type testBroker struct {
+	server *mochi.Server
+	tcp    string // 如 tcp://127.0.0.1:12345
+	ws     string // 如 ws://127.0.0.1:12346/mqtt，只有 startWebsocketBroker 启动的 broker 才有
+	once   sync.Once
+}
+
+// stop 关闭 broker，可重复调用
+func (b *testBroker) stop() {
+	b.once.Do(func() { b.server.Close() })
+}
+
+// startBroker 启动只监听本机随机端口、允许任何客户端的 broker，测试结束时关闭
+func startBroker(t *testing.T) *testBroker {
+	t.Helper()
+	return startBrokerAt(t, "127.0.0.1:0")
+}
+
+// startBrokerAt 在 addr 上启动 broker，extra 为额外的监听器
+func startBrokerAt(t *testing.T, addr string, extra ...listeners.Listener) *testBroker {
+	t.Helper()
+	server := mochi.New(&mochi.Options{
+		InlineClient: true,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "tcp", Address: addr})
+	for _, l := range append([]listeners.Listener{tcp}, extra...) {
+		if err := server.AddListener(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := server.Serve(); err != nil {
+		t.Fatal(err)
+	}
+	b := &testBroker{server: server, tcp: "tcp://" + tcp.Address()}
+	t.Cleanup(b.stop)
+	return b
+}
+
+// startWebsocketBroker 启动同时监听 TCP 和 WebSocket 的 broker
+func startWebsocketBroker(t *testing.T) *testBroker {
+	t.Helper()
+	// WebSocket 监听器的 Address 返回配置的地址，需要先选好空闲端口
+	addr := freeAddr(t)
+	b := startBrokerAt(t, "127.0.0.1:0", listeners.NewWebsocket(listeners.Config{ID: "ws", Address: addr}))
+	b.ws = "ws://" + addr + "/mqtt"
+	return b
+}
+
+// publish 通过内联客户端向 topic 发布 QoS 1 消息
+func (b *testBroker) publish(t *testing.T, topic, payload string) {
+	t.Helper()
+	if err := b.server.Publish(topic, []byte(payload), false, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// subscribe 订阅 filter，收到的消息体送入返回的通道；id 在同一个 broker 上须唯一
+func (b *testBroker) subscribe(t *testing.T, filter string, id int) <-chan packets.Packet {
+	t.Helper()
+	ch := make(chan packets.Packet, 16)
+	err := b.server.Subscribe(filter, id, func(cl *mochi.Client, sub packets.Subscription, pk packets.Packet) {
+		select {
+		case ch <- pk:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch
+}
+
+// spokenCall 是 recordingSpeaker 记录的一次朗读
+type spokenCall struct {
+	Text string
+	Opts speakOptions
+}
+
+// recordingSpeaker 记录每次朗读并立即返回 err
+type recordingSpeaker struct {
+	mu    sync.Mutex
+	calls []spokenCall
+	spoke chan spokenCall
+	err   error
+}
+
+func newRecordingSpeaker() *recordingSpeaker {
+	return &recordingSpeaker{spoke: make(chan spokenCall, 16)}
+}
+
+func (s *recordingSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	call := spokenCall{Text: text, Opts: opts}
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+	select {
+	case s.spoke <- call:
+	default:
+	}
+	return s.err
+}
+
+// next 等待下一次朗读
+func (s *recordingSpeaker) next(t *testing.T) spokenCall {
+	t.Helper()
+	select {
+	case call := <-s.spoke:
+		return call
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待朗读超时")
+		return spokenCall{}
+	}
+}
+
+// testConfig 返回连接到 broker、订阅 test/tts 的已校验配置，在线状态发布到 test/tts/availability
+func testConfig(t *testing.T, broker string) *Config {
+	t.Helper()
+	cfg := defaultConfig()
+	cfg.Brokers = []string{broker}
+	cfg.Topics = []string{"test/tts"}
+	cfg.ClientID = "tts-test"
+	cfg.AvailabilityTopic = "test/tts/availability"
+	cfg.VoiceRefreshMinutes = 0
+	if err := validateConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+// startBridge 在后台运行桥接器，等到它宣告在线（即已订阅全部主题）才返回，
+// 测试结束时停止桥接器并等待 run 返回
+func startBridge(t *testing.T, b *testBroker, cfg *Config, speaker Speaker) {
+	t.Helper()
+	startBridgeWith(t, b, cfg, speaker, runDeps{})
+}
+
+// startBridgeWith 与 startBridge 相同，但使用给定的 deps（如热加载配置）
+func startBridgeWith(t *testing.T, b *testBroker, cfg *Config, speaker Speaker, deps runDeps) {
+	t.Helper()
+	online := b.subscribe(t, cfg.AvailabilityTopic, 1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, speaker, deps) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("桥接器未按时退出")
+		}
+	})
+	for {
+		select {
+		case pk := <-online:
+			if string(pk.Payload) == cfg.OnlinePayload {
+				return
+			}
+		case err := <-done:
+			t.Fatalf("桥接器提前退出: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("等待桥接器上线超时")
+		}
+	}
+}
+
+func TestBridgeSpeaksPublishedMessage(t *testing.T) {
+	broker := startBroker(t)
+	speaker := newRecordingSpeaker()
+	startBridge(t, broker, testConfig(t, broker.tcp), speaker)
+
+	broker.publish(t, "test/tts", `{"text":"你好","voice":"Microsoft Huihui Desktop","rate":3,"volume":80}`)
+	got := speaker.next(t)
+	want := spokenCall{Text: "你好", Opts: speakOptions{Voice: "Microsoft Huihui Desktop", Rate: 3, Volume: 80}}
+	if got != want {
+		t.Errorf("朗读 %+v，期望 %+v", got, want)
+	}
+}
+
+func TestBridgeSpeaksPlainText(t *testing.T) {
+	broker := startBroker(t)
+	speaker := newRecordingSpeaker()
+	cfg := testConfig(t, broker.tcp)
+	cfg.Rate = -2
+	startBridge(t, broker, cfg, speaker)
+
+	broker.publish(t, "test/tts", "门铃响了")
+	got := speaker.next(t)
+	if got.Text != "门铃响了" || got.Opts.Rate != -2 || got.Opts.Volume != 100 {
+		t.Errorf("朗读 %+v，期望使用默认语速 -2、音量 100 朗读纯文本", got)
+	}
+}
+
+// freeAddr 返回本机一个当前空闲的 TCP 地址
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// 服务先于 broker 启动时，桥接器应持续重试而不是退出，broker 就绪后上线并朗读
+func TestBridgeWaitsForBroker(t *testing.T) {
+	addr := freeAddr(t)
+	cfg := testConfig(t, "tcp://"+addr)
+	cfg.ConnectTimeoutSeconds = 1
+	speaker := newRecordingSpeaker()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, speaker, runDeps{}) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	select {
+	case err := <-done:
+		t.Fatalf("broker 不可用时桥接器退出: %v", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	broker := startBrokerAt(t, addr)
+	// 在线状态是保留消息，桥接器先上线也能收到
+	online := broker.subscribe(t, cfg.AvailabilityTopic, 1)
+	select {
+	case pk := <-online:
+		if string(pk.Payload) != cfg.OnlinePayload {
+			t.Fatalf("在线状态为 %q", pk.Payload)
+		}
+	case err := <-done:
+		t.Fatalf("桥接器退出: %v", err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("broker 启动后桥接器未能连接")
+	}
+	broker.publish(t, "test/tts", "broker 已就绪")
+	if got := speaker.next(t); got.Text != "broker 已就绪" {
+		t.Errorf("朗读 %q", got.Text)
+	}
+}
+
+// 尚未连接上 broker 时收到退出信号，run 应及时返回
+func TestBridgeStopsWhileConnecting(t *testing.T) {
+	cfg := testConfig(t, "tcp://"+freeAddr(t))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- run(ctx, cfg, newRecordingSpeaker(), runDeps{}) }()
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("run 返回 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("退出信号后 run 未返回")
+	}
+}
+
+// 通过 ws:// 连接 broker（validateBroker 补全 /mqtt 路径），MQTT 3.1.1 和 5 都能正常接收和朗读
+func TestBridgeOverWebsocket(t *testing.T) {
+	for _, version := range []int{4, 5} {
+		t.Run(fmt.Sprintf("v%d", version), func(t *testing.T) {
+			broker := startWebsocketBroker(t)
+			speaker := newRecordingSpeaker()
+			cfg := testConfig(t, strings.TrimSuffix(broker.ws, "/mqtt"))
+			cfg.ProtocolVersion = version
+			if cfg.Brokers[0] != broker.ws {
+				t.Fatalf("broker 地址为 %q，期望补全为 %q", cfg.Brokers[0], broker.ws)
+			}
+			startBridge(t, broker, cfg, speaker)
+
+			broker.publish(t, "test/tts", `{"text":"通过 WebSocket","rate":1}`)
+			got := speaker.next(t)
+			if got.Text != "通过 WebSocket" || got.Opts.Rate != 1 {
+				t.Errorf("朗读 %+v", got)
+			}
+		})
+	}
+}
+
+// 订阅通配符主题时，回执中的 topic 是消息实际到达的具体主题，而不是订阅的过滤器
+func TestBridgeAckUsesConcreteTopic(t *testing.T) {
+	broker := startBroker(t)
+	cfg := testConfig(t, broker.tcp)
+	cfg.Topics = []string{"test/+/tts"}
+	cfg.StatusTopic = "test/status"
+	status := broker.subscribe(t, cfg.StatusTopic, 1)
+	startBridge(t, broker, cfg, newRecordingSpeaker())
+
+	next := func() map[string]any {
+		t.Helper()
+		select {
+		case pk := <-status:
+			var v map[string]any
+			if err := json.Unmarshal(pk.Payload, &v); err != nil {
+				t.Fatalf("回执 %q 不是 JSON: %v", pk.Payload, err)
+			}
+			return v
+		case <-time.After(5 * time.Second):
+			t.Fatal("等待回执超时")
+			return nil
+		}
+	}
+
+	broker.publish(t, "test/kitchen/tts", `{"text":"厨房的水烧开了"}`)
+	if ack := next(); ack["topic"] != "test/kitchen/tts" || ack["success"] != true {
+		t.Errorf("朗读回执为 %v，期望 topic 为 test/kitchen/tts", ack)
+	}
+
+	broker.publish(t, "test/garage/tts", `{"text":`)
+	if ack := next(); ack["topic"] != "test/garage/tts" || ack["error"] == nil {
+		t.Errorf("解析失败的回执为 %v，期望 topic 为 test/garage/tts", ack)
+	}
+}
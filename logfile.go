@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// rotatingFile 是按大小轮转的日志文件：写入后超过 maxSize 时，
+// 当前文件重命名为 path.1（已有的 path.1 顺延为 path.2，以此类推），
+// 最多保留 backups 个旧文件，然后打开新的空文件继续写入
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	maxSize int64 // <= 0 表示不轮转
+	backups int
+}
+
+func openRotatingFile(path string) (*rotatingFile, error) {
+	r := &rotatingFile{path: path}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// SetLimits 设置轮转阈值（字节）和保留的旧文件数量
+func (r *rotatingFile) SetLimits(maxSize int64, backups int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.maxSize = maxSize
+	r.backups = backups
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err == nil && r.maxSize > 0 && r.size >= r.maxSize {
+		if rerr := r.rotate(); rerr != nil {
+			fmt.Fprintf(os.Stderr, "日志轮转失败: %v\n", rerr)
+		}
+	}
+	return n, err
+}
+
+// rotate 需持有 r.mu。Windows 上无法重命名已打开的文件，因此先关闭再重命名
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	if r.backups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.backups))
+		for i := r.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Truncate(r.path, 0); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// Reopen 关闭当前文件并按原路径重新打开，供 logrotate 之类的外部工具
+// 重命名日志文件后使用；持锁切换，写入不会落到两个文件之间
+func (r *rotatingFile) Reopen() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	return r.open()
+}
+
+// reopenOnSIGHUP 每次收到 SIGHUP 时重新打开 r，直到 ctx 结束。
+// Windows 不会产生 SIGHUP，此时等同于空操作
+func reopenOnSIGHUP(ctx context.Context, r *rotatingFile) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := r.Reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "重新打开日志文件失败: %v\n", err)
+				continue
+			}
+			log.Printf("📝 收到 SIGHUP，已重新打开日志文件 %s", r.path)
+		}
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// 日志格式
+const (
+	logFormatText = "text" // 默认，带 emoji 的可读文本
+	logFormatJSON = "json" // 每行一个 JSON 对象，便于日志系统采集
+)
+
+// jsonLogger 在 --log-format json 时非 nil，标准 log 包的输出也会经由它写出
+var jsonLogger *slog.Logger
+
+// setupJSONLogging 将日志切换为 JSON 格式写入 w：
+// 现有的 log.Printf 调用通过 slogWriter 转为 slog 记录，级别按消息前缀推断
+func setupJSONLogging(w io.Writer) {
+	jsonLogger = slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	log.SetFlags(0)
+	log.SetOutput(slogWriter{jsonLogger})
+}
+
+// slogWriter 把 log 包写出的每一行作为一条 slog 记录
+type slogWriter struct {
+	l *slog.Logger
+}
+
+func (w slogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\n")
+	w.l.Log(context.Background(), logLevel(msg), msg)
+	return len(p), nil
+}
+
+// logLevel 根据消息前缀推断级别：❌ 为 error，⚠️ 和 ⏰ 为 warn，[debug] 为 debug，其余为 info
+func logLevel(msg string) slog.Level {
+	switch {
+	case strings.HasPrefix(msg, "❌"):
+		return slog.LevelError
+	case strings.HasPrefix(msg, "⚠️"), strings.HasPrefix(msg, "⏰"):
+		return slog.LevelWarn
+	case strings.HasPrefix(msg, "[debug]"):
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// logEvent 记录一条带结构化字段的事件：文本格式下与 log.Printf 输出相同，
+// JSON 格式下额外带上 event 和 attrs 字段（如 topic、text_len、duration_ms、error）
+func logEvent(event string, attrs []slog.Attr, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if jsonLogger == nil {
+		log.Output(2, msg)
+		return
+	}
+	jsonLogger.LogAttrs(context.Background(), logLevel(msg), msg, append([]slog.Attr{slog.String("event", event)}, attrs...)...)
+}
@@ -4,142 +4,1297 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
+	"os/signal"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
-	"context"
+	"unicode/utf8"
+	"log/slog"
 	"encoding/json"
+	"context"
+	"errors"
+	"io"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/spf13/pflag"
 )
 
 type Config struct {
-	Broker   string
-	Topic    string
+	// Brokers 为 broker 地址列表，连接时按顺序尝试，第一个之后的作为备用
+	Brokers []string
+	Topics   []string // 订阅的主题，共用同一个消息回调
+
+	// TopicSettings 按主题（可含 + 和 # 通配符）设置默认的语音、语速和音量，
+	// 消息未指定时使用第一条匹配的设置；只能在配置文件中设置
+	TopicSettings []topicSettings
+
+	// Severities 按消息中的 severity 字段（不区分大小写）设置语音、语速、音量和优先级，
+	// 如让 critical 告警用更响、更快的另一个语音朗读；消息本身的字段和主题设置之前生效，
+	// 未知的级别使用默认值。只能在配置文件中设置
+	Severities map[string]severitySettings
+
+	// VoiceRotation 非空时，消息和主题设置都未指定 voice（也未指定 lang）的消息
+	// 按权重从中随机选择语音，未安装的语音不会被选中；只能在配置文件中设置。
+	// VoiceRotationSeed 非 0 时以固定种子选择，便于复现
+	VoiceRotation     []weightedVoice
+	VoiceRotationSeed int
+
+	// 后端能列出语音时，每 VoiceRefreshMinutes 分钟刷新一次已安装的语音（<= 0 只在启动时列出）。
+	// 消息指定的语音未安装时向状态主题发布诊断信息（含已安装的语音）：
+	// RetryMissingVoice 为 true 时消息等待语音安装，每次刷新后重试，最多等待一小时；
+	// 否则改用默认语音朗读
+	VoiceRefreshMinutes int
+	RetryMissingVoice   bool
+
+	// QoS 为订阅使用的服务质量等级 0/1/2。broker 实际下发的等级取
+	// 发布方与订阅方中较低者；保留消息在（重新）订阅时同样按该等级下发，
+	// QoS 0 下若连接恰好在下发时中断，该保留消息不会重发
+	QoS int
+
+	// IgnoreRetained 为 true 时不朗读保留消息：broker 在每次（重新）订阅时
+	// 都会重发主题上的最后一条保留消息，重启后会被重复朗读
+	IgnoreRetained bool
+
+	// PayloadEncoding 为整个消息体的编码：plain（默认）、base64 或 gzip，
+	// 解码失败的消息不朗读，只记录并回报到状态主题
+	PayloadEncoding string
+
+	// PayloadCharset 为（解码后的）消息体的字符集，如 gbk、gb18030、big5，
+	// 默认 UTF-8；用于老旧的 Windows 发布端。HTTP 请求体是 JSON，总是 UTF-8
+	PayloadCharset string
+
+	// Template 非空时作为 Go text/template 应用于（解码后的）MQTT 消息体，
+	// 执行结果即为朗读文本，如 "温度 {{.value}} 度"；消息中的其他字段不再生效。
+	// 执行失败（如缺少字段）的消息只记录并回报到状态主题，不朗读
+	Template string
+
+	// PayloadFilter 非空时只朗读满足其中全部条件（key=value，见 payloadMatches）的 JSON 消息，
+	// 其余消息跳过；消息体不是 JSON 时默认照常朗读，PayloadFilterRequireJSON 为 true 时跳过
+	PayloadFilter            []string
+	PayloadFilterRequireJSON bool
+
+	// ProtocolVersion 为 MQTT 协议版本：3（3.1）、4（3.1.1）或 5，0 表示自动协商（3.1.1 或 3.1）。
+	// 5 时改用 paho.golang 客户端（见 v5Client），消息的 user properties voice、rate、priority
+	// 覆盖消息体中的同名字段，消息体可以是纯文本（见 applyUserProperties）
+	ProtocolVersion int
 	Username string
 	Password string
+	ClientID string // MQTT 客户端 ID，空则自动生成 tts-mqtt-<主机名>-<pid>
+	Rate     int // 默认语速 -10..10
+	Volume   int // 默认音量 0..100
+
+	// MaxTextLength 为单条朗读的最大字符数，<= 0 不限制。超长文本在
+	// SplitLongText 为 true 时按句拆分为多条依次朗读，否则丢弃并记录警告
+	MaxTextLength int
+	SplitLongText bool
+
+	// ChunkPauseMs 为拆分后相邻两段之间的停顿毫秒数，<= 0 不停顿。
+	// KeepChunksTogether 为 true 时拆分出的各段连续朗读，更高优先级的消息要等最后
+	// 一段读完（抢占正在朗读的一段时除外）；默认可在段与段之间插入
+	ChunkPauseMs       int
+	KeepChunksTogether bool
+
+	// InterUtteranceGapMs 为一条消息读完后、下一条开始前的停顿毫秒数，<= 0 不停顿；
+	// 停顿期间到达的更高优先级消息立即朗读
+	InterUtteranceGapMs int
+
+	// SpeakPrefix、SpeakSuffix 加在每条消息的前后（如 "请注意："），计入长度限制；
+	// 消息中的 prefix、suffix 字段可覆盖，设为 "" 表示这条消息不加
+	SpeakPrefix string
+	SpeakSuffix string
+
+	// SpeakTopicSegment 非 0 时把消息主题的第几级（从 1 开始，负数从末尾数起）作为前缀读出，
+	// 如 2 时 home/kitchen/tts -> "kitchen: ..."，便于一个音箱为多个房间朗读时区分来源；
+	// SpeakTopicHumanize 为 true 时读作 "Kitchen"（_、- 换成空格，词首大写）。
+	// topic_settings 中的 topic_segment 可按主题覆盖
+	SpeakTopicSegment  int
+	SpeakTopicHumanize bool
+
+	QueueSize       int  // 朗读队列容量
+
+	// Workers 为并发朗读的数量，默认 1。大于 1 只适合输出到文件（OutputDir）
+	// 或使用多个音频设备的场景，默认设备无法干净地播放重叠的语音
+	Workers int
+	QueueDropOldest bool // 队列满时丢弃最旧的消息（默认丢弃新消息）
+	Preempt         bool // 高于当前朗读优先级的消息立即打断当前朗读
+
+	// ManualAck 为 true 时关闭 paho 的自动确认，QoS 1/2 消息入队后才确认；
+	// 因队列满或限流未入队的消息不确认，由 broker 在重新连接后重发。
+	// 同时使用持久会话（clean session = false），需要固定的 ClientID
+	ManualAck bool
+
+	// TTSTimeoutSeconds 为单条朗读的超时秒数，<= 0 表示不限时。
+	// 超时后 powershell 进程会被终止（见 speakText），队列继续处理下一条
+	TTSTimeoutSeconds int
+
+	// SpeakRetries 为朗读失败（如音频设备暂时被占用）时的最多重试次数，<= 0 不重试；
+	// 重试前等待 SpeakRetryDelayMs 毫秒，之后每次翻倍。超时的朗读可能已读出一部分，
+	// RetryOnTimeout 为 true 时才重试。最终结果发布到 StatusTopic
+	SpeakRetries      int
+	SpeakRetryDelayMs int
+	RetryOnTimeout    bool
+
+	// MaxAgeSeconds 为消息在队列中的最长等待秒数，超过时不再朗读（记录日志并发布回执），
+	// 避免积压消除后朗读早已过时的提醒；<= 0 表示不限
+	MaxAgeSeconds int
+
+	// StatsIntervalMinutes 为在日志中记录统计摘要（收到、朗读、失败、丢弃、平均耗时）
+	// 的间隔分钟数，<= 0 不记录
+	StatsIntervalMinutes int
+
+	// FailureThreshold 为连续失败（含超时）多少次后判定音频子系统挂起，<= 0 表示不检测。
+	// 达到后记录严重错误、向在线状态主题发布 UnhealthyPayload，并执行 RemediationCommand
+	// （非空时，如重启 Windows 音频服务）后再继续朗读；下一次朗读成功时恢复为 OnlinePayload
+	FailureThreshold   int
+	RemediationCommand string
+
+	// 连接与订阅的超时秒数，<= 0 表示一直等待。ConnectTimeoutSeconds
+	// 同时作为 paho 单次连接尝试（含重连）的超时
+	ConnectTimeoutSeconds   int
+	SubscribeTimeoutSeconds int
+
+	// StartupJitterMs 大于 0 时首次连接前随机等待 [0, StartupJitterMs) 毫秒，
+	// 避免整屋断电恢复后所有桥接器同时连接 broker；重连不受影响
+	StartupJitterMs int
+
+	// ReconnectMaxSeconds 为断线重连的最大间隔秒数，间隔从 1 秒起按指数增长并随机抖动
+	ReconnectMaxSeconds int
+
+	// AnnounceReconnect 为 true 时，断线后重新连接成功会朗读 ReconnectPhrase（首次连接不朗读）
+	AnnounceReconnect bool
+	ReconnectPhrase   string
+
+	// TLS（broker 为 ssl:// 或 mqtts:// 时生效）
+	CAFile             string // 根证书 PEM 文件，空则使用系统证书
+	ClientCertFile     string // 客户端证书 PEM 文件（双向认证）
+	ClientKeyFile      string // 客户端私钥 PEM 文件
+	ClientPFXFile      string // 含客户端证书和私钥的 PKCS#12（.pfx/.p12）文件，与 PEM 二选一
+	ClientPFXPassword  string // ClientPFXFile 的密码
+	InsecureSkipVerify bool   // 跳过服务端证书校验，仅用于测试环境
+
+	StatusTopic string // 每条朗读结束后发布回执的主题，空则不发布
+
+	// AllowedPublishers 非空时只朗读这些发布者的消息，其余消息记录日志后丢弃。
+	// MQTT 5 下发布者取自名为 PublisherProperty 的 user property，需由 broker 写入发布者的 client ID；
+	// 未配置或消息没有该属性时取自消息主题的第 PublisherTopicLevel 级（从 1 开始，负数从末尾数起），
+	// 需由 broker ACL 保证该级为发布者的 client ID（见 publisherAllowed）。
+	// 只作用于 MQTT 消息，HTTP 接口不受限制
+	AllowedPublishers   []string
+	PublisherProperty   string
+	PublisherTopicLevel int
+
+	// EventsTopic 为连接状态事件（connected、disconnected、reconnecting）的主题，
+	// 断线期间的事件在重新连接后补发；空则不发布
+	EventsTopic string
+
+	// DropTopic 为丢弃事件的主题：消息因队列已满、限流、过期、静音、安静时段、
+	// 门控或屏蔽规则未被朗读时发布一条 JSON（格式见 dropEvent）；空则不发布
+	DropTopic string
+
+	// NowSpeakingTopic 为实时的正在朗读主题：开始朗读时发布保留的 JSON（格式见 nowSpeaking），
+	// 读完、超时或被取消后发布空的保留消息清除；空则不发布
+	NowSpeakingTopic string
+
+	// CommandTopic 为命令主题，如 {"cmd":"list_voices"}，结果发布到 StatusTopic；空则不订阅
+	CommandTopic string
+
+	// MuteMode 为通过命令 {"cmd":"mute"} 静音期间新消息的处理方式：drop（默认）或 queue
+	MuteMode string
+
+	// QuietHours 为每天不朗读的时段（本地时间），如 "22:00-07:00"，可跨越午夜；空则不启用。
+	// 安静时段内的消息只记录日志并向 StatusTopic 发布回执；
+	// priority 不低于 QuietHoursBypassPriority（> 0 时）的消息照常朗读
+	QuietHours               string
+	QuietHoursBypassPriority int
+
+	// GateTopic 非空时订阅该主题（如在家状态 home/presence），缓存最新的值，
+	// 值与 GateMatchValue（忽略大小写）不一致或尚未收到时不朗读，只记录日志并发布回执
+	GateTopic      string
+	GateMatchValue string
+
+	// 遗嘱消息：异常断开时由 broker 向 WillTopic 发布 WillPayload
+	WillTopic   string
+	WillPayload string
+	WillRetain  bool
+
+	// 在线状态：订阅成功后向 AvailabilityTopic（空则使用 WillTopic）发布保留消息 OnlinePayload，
+	// 正常退出时发布 WillPayload，用于在面板中显示桥接器是否在线
+	AvailabilityTopic string
+	OnlinePayload     string
+	UnhealthyPayload  string
+
+	HTTPAddr string // HTTP 接口监听地址（如 :8080），空则不启用
+
+	// Speaker 为 TTS 后端：powershell、say、espeak、azure、noop，空则按操作系统选择
+	Speaker string
+
+	// PowerShellPath 为 PowerShell 可执行文件，默认 powershell（从 PATH 查找），
+	// 可指定 pwsh（PowerShell 7）或绝对路径；使用 PowerShell 后端时启动即检查是否存在
+	PowerShellPath string
+
+	// Azure Speech 服务（Speaker 为 azure 时使用）
+	AzureKey    string
+	AzureRegion string // 如 eastasia
+	AzureVoice  string // 默认神经网络语音，如 zh-CN-XiaoxiaoNeural
+
+	DedupSeconds int // 该时间窗口内与上一条相同的文本不再朗读，<= 0 不去重
+
+	// 令牌桶限流：每分钟最多 RateLimitPerMinute 条（<= 0 不限流），
+	// 允许短时突发 RateLimitBurst 条（<= 0 时等于每分钟条数），超出的消息丢弃
+	RateLimitPerMinute int
+	RateLimitBurst     int
+
+	Debug bool // 输出调试日志
+
+	SelfTestOnStart bool // 启动时先做一次 TTS 自检，失败只记录错误并继续运行
+
+	// DryRun 时只记录将要朗读的内容，不调用 TTS 后端、播放器、缓存和音量闪避；只能通过 --dry-run 设置
+	DryRun bool
+
+	// OnEmptyText 为（规范化后）文本为空的 MQTT 消息的处理方式：skip（默认，记录警告后跳过）、
+	// beep（朗读 EmptyTextPhrase，便于调试时确认桥接器在工作）或 report（发布到状态主题）
+	OnEmptyText     string
+	EmptyTextPhrase string
+
+	Normalize     bool   // 朗读前规范化文本：处理 emoji、删除控制字符、折叠空白
+	NormalizeMode string // "strip"（默认，删除 emoji）或 "describe"（常见 emoji 读作文字）
+
+	// ExpandNumbers 为 true 时将 ISO 日期、金额和带千位分隔符的数字改写为朗读形式，
+	// NumberLocale 为 zh（默认）或 en；消息指定 lang 时按其语言
+	ExpandNumbers bool
+	NumberLocale  string
+
+	// 消息既未指定 voice 也未指定 lang 时：DetectCulture 为 true 时按文字推测语言
+	// （见 detectCulture），无法推测或未启用时使用 DefaultCulture（BCP-47，如 zh-CN），
+	// 据此选择已安装的语音，避免区域设置错误的系统用英文语音读中文
+	DetectCulture  bool
+	DefaultCulture string
+
+	// Pronunciations 为发音词典（只能在配置文件中设置），朗读前替换其中的词，
+	// SSML 消息中输出 <phoneme> 或 <sub> 标签
+	Pronunciations map[string]pronunciation
+
+	// Blocklist 为屏蔽规则（正则，只能在配置文件中设置），用于阻止脏话、密码等被读出。
+	// BlocklistMode 为 skip（默认）时命中的消息整条不朗读，为 replace 时将命中的部分
+	// 替换为 BlocklistToken 后朗读；屏蔽时的日志只记录命中的规则序号，不记录原文
+	Blocklist      []string
+	BlocklistMode  string
+	BlocklistToken string
+
+	OutputDir string // 设置后朗读结果保存为该目录下的 .wav 文件，而不是播放
+
+	// TextFileDir 为消息 file 字段允许读取的目录，空则不接受 file
+	TextFileDir string
+
+	// PlayerCommand 非空时先合成 .wav 再用该命令播放（{file}、{device} 为占位符），
+	// 配合 AudioDevice 输出到指定声卡。System.Speech 本身无法选择设备，
+	// 两者都为空时保持直接输出到默认设备
+	PlayerCommand string
+	AudioDevice   string
+
+	// DuckMediaPercent 大于 0 时朗读期间把其他程序的音量降低到原来的该百分比，读完后恢复
+	// （见 DuckingSpeaker）；DuckCommand 和 DuckRestoreCommand 为降低和恢复音量的命令
+	// （{percent} 为占位符），为空时在 Windows 上通过 PowerShell 调整音频会话
+	DuckMediaPercent   int
+	DuckCommand        string
+	DuckRestoreCommand string
+
+	// AudioTopic 非空时不在本机播放，而是把合成的 .wav 分块发布到该主题，
+	// 供远程客户端播放（格式见 audioHeader）；AudioChunkSize 为分块字节数
+	AudioTopic     string
+	AudioChunkSize int
+
+	// CacheDir 非空时缓存合成的音频，重复的文本直接播放缓存文件；
+	// 缓存总大小超过 CacheMaxMB 时淘汰最久未使用的文件（<= 0 不限制）
+	CacheDir   string
+	CacheMaxMB int
+
+	LogFormat string // 日志格式：text（默认）或 json
+
+	MaxLogSizeMB int // 日志文件超过该大小（MB）时轮转，<= 0 不轮转
+	LogBackups   int // 轮转时保留的旧日志文件数量
 }
 
-var f mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
-	payload := string(msg.Payload())
-	log.Printf("收到 MQTT 消息 [主题: %s]: %s", msg.Topic(), payload)
+// availabilityTopic 返回发布在线状态的主题，空表示不发布
+func (c *Config) availabilityTopic() string {
+	if c.AvailabilityTopic != "" {
+		return c.AvailabilityTopic
+	}
+	return c.WillTopic
+}
 
-	var text string
-	var j struct{ Text string `json:"text"` }
-	if err := json.Unmarshal([]byte(payload), &j); err == nil && j.Text != "" {
-		text = j.Text
-	} else {
-		text = payload
+func (c *Config) connectTimeout() time.Duration {
+	return time.Duration(c.ConnectTimeoutSeconds) * time.Second
+}
+
+func (c *Config) subscribeTimeout() time.Duration {
+	return time.Duration(c.SubscribeTimeoutSeconds) * time.Second
+}
+
+// speakOptions 是单次朗读的参数，由消息字段与 Config 默认值合并而来
+type speakOptions struct {
+	Voice  string
+	Lang   string // BCP-47 语言标记（如 zh-CN），Voice 为空时按语言选择语音
+	Rate   int
+	Volume int
+
+	// OutputDir 非空时保存为该目录下带时间戳的 .wav 文件，
+	// 由 worker 在朗读前分配具体文件名写入 OutputFile
+	OutputDir  string
+	OutputFile string
+
+	SSML bool // Text 为已校验的 SSML，使用 SpeakSsml 朗读
+}
+
+// ttsPayload 是 JSON 格式消息体，除 text 外的字段均为可选
+type ttsPayload struct {
+	Text  string `json:"text"`
+	Texts []string `json:"texts"` // 多段文本，按顺序连续朗读；非空时忽略 text
+	Voice  string `json:"voice"`  // 已安装的语音名称，如 "Microsoft Zira Desktop"
+	Lang   string `json:"lang"`   // BCP-47 语言标记，如 "zh-CN"、"en-US"；voice 优先
+	Rate   *int   `json:"rate"`   // 语速 -10..10，超出范围会被截断
+	Volume *int   `json:"volume"` // 音量 0..100，超出范围会被截断
+	Output string `json:"output"` // 保存 .wav 的子目录，相对于 Config.OutputDir（见 confineOutputDir）
+	// File 为 Config.TextFileDir 下的 UTF-8 文本文件（见 confineTextFile），
+	// 非空时朗读文件内容并忽略 text 和 texts，同样受长度上限和拆分规则约束
+	File   string `json:"file"`
+	SSML   bool   `json:"ssml"`   // text 为 SSML（根元素 <speak>），等同于 "format":"ssml"
+	Format string `json:"format"` // "text"（默认）或 "ssml"
+	// Encoding 为 text 和 texts 的编码：plain（默认）或 base64
+	Encoding string `json:"encoding"`
+	Prefix   *string `json:"prefix"` // 覆盖 Config.SpeakPrefix
+	Suffix   *string `json:"suffix"` // 覆盖 Config.SpeakSuffix
+	// ReplyTo 非空时整条消息朗读结束后向该主题发布完成消息（格式同状态回执），
+	// CorrelationID 原样带回，用于关联请求与回复
+	ReplyTo       string `json:"reply_to"`
+	CorrelationID string `json:"correlation_id"`
+
+	Priority int `json:"priority"` // 越大越优先，默认 0；同优先级按到达顺序
+
+	// Severity 为告警级别，按 Config.Severities 选择朗读参数；告警系统的消息常用
+	// message 存放正文，text 为空时作为 text
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// clampInt 将 v 限制在 [lo, hi] 区间内
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
 	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
 
-	text = strings.TrimSpace(text)
-	if text == "" || len(text) > 500 {
-		log.Println("⚠️ 文本为空或过长，跳过朗读")
-		return
+// bridge 汇总各消息入口（MQTT、HTTP）共享的配置、朗读队列和运行状态
+type bridge struct {
+	cfg   atomic.Pointer[Config] // 当前配置，热加载时整体替换
+	queue *speakQueue
+	state bridgeState
+	dedup dedupFilter
+	limit rateLimiter
+	gate  gateState
+
+	// missing 为等待语音安装的消息（见 RetryMissingVoice）
+	missing missingVoices
+
+	// reload 重新合并全部配置来源，用于 reload 命令；未使用配置文件时为 nil
+	reload   func() (*Config, error)
+	reloadMu sync.Mutex
+
+	// client 用于发布 reply_to 回复，由 run 在创建 MQTT 客户端后设置
+	client mqtt.Client
+	// switchBrokers 断开当前连接并改为连接 brokers，由 run 设置；不连接 MQTT 时为 nil
+	switchBrokers func(brokers []string) error
+}
+
+// config 返回当前生效的配置，调用方不应修改返回值
+func (b *bridge) config() *Config {
+	return b.cfg.Load()
+}
+
+// 消息未进入队列的原因
+var (
+	errQueueFull = errors.New("朗读队列已满")
+	errDuplicate = errors.New("重复的文本")
+	errMuted     = errors.New("已静音")
+	errThrottled = errors.New("消息过多，已限流")
+	errQuiet     = errors.New("安静时段，不朗读")
+	errGated     = errors.New("门控条件不满足，不朗读")
+	errBlocked   = errors.New("命中屏蔽规则，不朗读")
+)
+
+// 静音期间新消息的处理方式
+const (
+	muteDrop  = "drop"  // 丢弃（默认）
+	muteQueue = "queue" // 照常入队，解除静音后朗读
+)
+
+// enqueue 是各入口共用的入队逻辑，reqs 为同一条消息的一段或多段文本，
+// 未进入队列时返回原因。超过 MaxTextLength 的普通文本（已由 newSpeakRequest
+// 确认允许拆分）按句拆为多条同优先级请求；所有请求一次性入队，
+// 其他消息不会插入其间（多个 worker 时只保证开始朗读的顺序）
+func (b *bridge) enqueue(reqs ...speakRequest) (err error) {
+	// 未进入队列的消息也回复 reply_to，发布方不必等待超时；
+	// enqueue 多在 MQTT 回调中调用，在 goroutine 中发布以免等待确认时阻塞回调
+	defer func() {
+		if err != nil {
+			go b.publishReply(reqs[0], err, 0)
+		}
+	}()
+	texts := make([]string, len(reqs))
+	for i, req := range reqs {
+		texts[i] = req.Text
+	}
+	text := strings.Join(texts, "\n")
+	src := "HTTP"
+	if reqs[0].Topic != "" {
+		src = "主题: " + reqs[0].Topic
 	}
+	if _, muted := b.queue.MutedUntil(); muted && b.config().MuteMode != muteQueue {
+		log.Printf("🔇 静音中，丢弃消息 [%s]: %.50q", src, text)
+		b.queue.dropped(reqs, dropMuted)
+		return errMuted
+	}
+	if b.quiet(reqs, time.Now()) {
+		log.Printf("🌙 安静时段，不朗读 [%s]: %.50q", src, text)
+		// onResult 发布回执时等待 broker 确认，放到 goroutine 中以免阻塞 MQTT 回调
+		if onResult := b.queue.onResult; onResult != nil {
+			go func() {
+				for _, req := range reqs {
+					onResult(req, errQuiet, 0)
+				}
+			}()
+		}
+		b.queue.dropped(reqs, dropQuietHours)
+		return errQuiet
+	}
+	if reason, gated := b.gated(); gated {
+		log.Printf("🚪 %s，不朗读 [%s]: %.50q", reason, src, text)
+		if onResult := b.queue.onResult; onResult != nil {
+			go func() {
+				for _, req := range reqs {
+					onResult(req, errGated, 0)
+				}
+			}()
+		}
+		b.queue.dropped(reqs, dropGated)
+		return errGated
+	}
+	if !b.dedup.Allow(text, time.Now()) {
+		debugf("🔁 重复的文本，已忽略 [%s]: %.50q", src, text)
+		return errDuplicate
+	}
+	if !b.limit.Allow(time.Now()) {
+		debugf("🚦 限流中，丢弃消息 [%s]: %.50q", src, text)
+		b.queue.dropped(reqs, dropRateLimit)
+		return errThrottled
+	}
+	if voice := reqs[0].Opts.Voice; voice != "" {
+		if installed, known := installedVoices.Lookup(voice); known && !installed && b.missingVoice(reqs) {
+			return nil
+		}
+	}
+	return b.push(reqs)
+}
 
+// push 拆分 reqs 并放入朗读队列，不再检查静音、限流等条件
+func (b *bridge) push(reqs []speakRequest) error {
+	parts := splitRequests(b.config(), reqs)
+	trackReply(parts)
+	if !b.queue.Enqueue(parts...) {
+		return errQueueFull
+	}
+	return nil
+}
 
-	// ✅ 异步处理 TTS，避免阻塞 MQTT 回调
-    go func(t string) {
-        ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-        defer cancel()
-        
-        done := make(chan error, 1)
-        go func() {
-            done <- speakText(t)
-        }()
-
-        select {
-        case err := <-done:
-            if err != nil {
-                log.Printf("❌ TTS 错误: %v", err)
-            } else {
-                log.Printf("✅ 已完成朗读: %q", t)
-            }
-        case <-ctx.Done():
-            log.Printf("⏰ TTS 超时（30秒），放弃朗读: %.50q", t)
-            // 注意：无法强制 kill powershell 进程，但至少不卡主线
-        }
-    }(text)
-
-	
-}
-
-func speakText(text string) error {
-	 log.Printf("🔊 尝试朗读文本 (长度=%d): %.50q", len(text), text) // 最多显示前50字符
-
-    // 转义 PowerShell 特殊字符
-	safeText := strings.ReplaceAll(text, "\"", "`\"")
-	safeText = strings.ReplaceAll(safeText, "$", "`$")
-
-	start := time.Now()
-
-	// 构建 PowerShell 命令（增加错误捕获和静默模式）
-	psCmd := `
-			try {
-			    Add-Type -AssemblyName System.Speech
-			    $synth = New-Object System.Speech.Synthesis.SpeechSynthesizer
-			    $synth.Speak("` + safeText + `")
-			    Write-Host "✅ TTS 成功: 长度=$(("` + safeText + `").Length)"
-			} catch {
-			    Write-Error "❌ TTS 失败: $($_.Exception.Message)"
-			    exit 1
+// quiet 判断 reqs 此刻是否应因安静时段而不朗读，任一请求的优先级达到豁免值时照常朗读
+func (b *bridge) quiet(reqs []speakRequest, now time.Time) bool {
+	cfg := b.config()
+	if cfg.QuietHours == "" {
+		return false
+	}
+	q, err := parseQuietHours(cfg.QuietHours)
+	if err != nil || !q.inQuietHours(now) {
+		return false
+	}
+	if cfg.QuietHoursBypassPriority > 0 {
+		for _, req := range reqs {
+			if req.Priority >= cfg.QuietHoursBypassPriority {
+				return false
 			}
-			`
+		}
+	}
+	return true
+}
+
+// announce 朗读桥接器自身的提示（如重新连接），与消息一样受静音、安静时段等限制
+func (b *bridge) announce(text string) {
+	reqs, err := newSpeakRequests(b.config(), ttsPayload{Text: text})
+	if err != nil {
+		log.Printf("⚠️ 提示文本无效: %v", err)
+		return
+	}
+	log.Printf("📢 朗读提示: %q", text)
+	b.enqueue(reqs...)
+}
 
-	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", psCmd)
+// chunkGroups 为拆分出的各段分配同一个组号，用于段间停顿和连续朗读
+var chunkGroups atomic.Uint64
+
+// splitRequests 将超过 MaxTextLength 的非 SSML 文本拆分为多个请求，其余请求原样保留
+func splitRequests(cfg *Config, reqs []speakRequest) []speakRequest {
+	var parts []speakRequest
+	for _, req := range reqs {
+		if max := cfg.MaxTextLength; max > 0 && !req.Opts.SSML && utf8.RuneCountInString(req.Text) > max {
+			chunks := splitText(req.Text, max)
+			group := chunkGroups.Add(1)
+			for i, chunk := range chunks {
+				part := req
+				part.Text = chunk
+				part.group, part.chunk, part.chunks = group, i, len(chunks)
+				parts = append(parts, part)
+			}
+			log.Printf("✂️ 文本超过 %d 字符，拆分为 %d 段朗读", max, len(chunks))
+			continue
+		}
+		parts = append(parts, req)
+	}
+	return parts
+}
 
-	// 捕获 stdout + stderr 合并输出
-	output, err := cmd.CombinedOutput()
+// onMessage 是 MQTT 消息回调，解析后的请求放入队列依次朗读，
+// 未在消息中指定的朗读参数取自 cfg
+func (b *bridge) onMessage(client mqtt.Client, msg mqtt.Message) {
+	// 只有 manual_ack 时才由这里决定是否确认；自动确认模式下 paho 总会确认
+	ack := true
+	defer func() {
+		if ack {
+			msg.Ack()
+		}
+	}()
+	payload := string(msg.Payload())
+	logEvent("message_received", []slog.Attr{
+		slog.String("topic", msg.Topic()),
+		slog.Int("text_len", utf8.RuneCountInString(payload)),
+	}, "收到 MQTT 消息 [主题: %s]: %s", msg.Topic(), payload)
+	b.state.touchMessage()
 
-	// 记录完整输出（包含 Write-Host 和 Write-Error）
-	logMsg := strings.TrimSpace(string(output))
-	if logMsg != "" {
-		log.Printf("🔊 PowerShell TTS 输出: %s", logMsg)
+	if sender, ok := publisherAllowed(b.config(), msg); !ok {
+		log.Printf("⛔ 发布者 %q 不在允许列表中，丢弃消息 [主题: %s]", sender, msg.Topic())
+		return
+	}
+	if msg.Retained() && b.config().IgnoreRetained {
+		log.Printf("📌 忽略保留消息 [主题: %s]: %.50q", msg.Topic(), payload)
+		return
 	}
 
+	body, err := decodePayload(msg.Payload(), b.config().PayloadEncoding, b.config().PayloadCharset)
+	if err == nil {
+		if ok, reason := payloadMatches(b.config(), body); !ok {
+			debugf("🔍 消息被 payload_filter 过滤（%s）[主题: %s]: %.50q", reason, msg.Topic(), payload)
+			return
+		}
+	}
+	var p ttsPayload
+	if tmpl := b.config().Template; err == nil && tmpl != "" {
+		var text string
+		text, err = renderTemplate(tmpl, body)
+		p = ttsPayload{Text: text}
+	} else if err == nil {
+		p, err = parsePayload(body)
+	}
+	if err != nil {
+		// 写错的 JSON 或无法解码的内容不能当作文本读出来，只记录并回报到状态主题；
+		// 在 goroutine 中发布，等待确认期间不阻塞 MQTT 回调
+		log.Printf("❌ [主题: %s] %v", msg.Topic(), err)
+		go publishStatus(client, b.config().StatusTopic, newPayloadError(msg.Topic(), payload, err))
+		return
+	}
+	p = applyUserProperties(msg, p)
+	p = applySeverity(b.config().Severities, p)
+	p = applyTopicSettings(b.config().TopicSettings, msg.Topic(), p)
+	p = applyTopicLabel(b.config(), msg.Topic(), p)
+	reqs, err := newSpeakRequests(b.config(), p)
+	if errors.Is(err, errEmptyText) {
+		reqs, err = b.onEmptyText(client, msg.Topic(), payload, p)
+		if err == nil && reqs == nil {
+			return
+		}
+	}
+	if errors.Is(err, errBlocked) {
+		b.dropBlocked(msg.Topic(), p)
+		return
+	}
 	if err != nil {
-		log.Printf("❌ PowerShell TTS 执行失败: %v", err)
+		log.Printf("⚠️ [主题: %s] %v，跳过朗读", msg.Topic(), err)
+		if errors.Is(err, errUnsafeOutput) || errors.Is(err, errTextFile) {
+			go publishStatus(client, b.config().StatusTopic, newPayloadError(msg.Topic(), payload, err))
+		}
+		return
+	}
+	for i := range reqs {
+		reqs[i].Topic = msg.Topic()
+	}
+
+	// ✅ 放入队列由 worker 异步朗读，避免阻塞 MQTT 回调
+	if err := b.enqueue(reqs...); errors.Is(err, errQueueFull) || errors.Is(err, errThrottled) {
+		if b.config().ManualAck {
+			ack = false
+			log.Printf("🤝 消息未入队（%v），不确认，等待 broker 重发 [主题: %s]", err, msg.Topic())
+		}
+	}
+}
+
+// onEmptyText 按 OnEmptyText 处理文本为空的消息：skip 返回 errEmptyText，由调用方记录并跳过；
+// report 在后台向状态主题发布诊断信息（不阻塞 MQTT 回调）并返回 nil, nil；beep 返回朗读 EmptyTextPhrase 的请求
+func (b *bridge) onEmptyText(client mqtt.Client, topic, payload string, p ttsPayload) ([]speakRequest, error) {
+	cfg := b.config()
+	switch cfg.OnEmptyText {
+	case emptyReport:
+		log.Printf("⚠️ [主题: %s] %v，已回报到状态主题", topic, errEmptyText)
+		go publishStatus(client, cfg.StatusTopic, newPayloadError(topic, payload, errEmptyText))
+		return nil, nil
+	case emptyBeep:
+		log.Printf("🔔 [主题: %s] %v，朗读提示音 %q", topic, errEmptyText, cfg.EmptyTextPhrase)
+		none := ""
+		p.Text, p.Texts, p.File = cfg.EmptyTextPhrase, nil, ""
+		p.Prefix, p.Suffix = &none, &none
+		return newSpeakRequests(cfg, p)
+	}
+	return nil, errEmptyText
+}
+
+// debugEnabled 为 true 时 debugf 才输出日志，热加载时可能被其他 goroutine 修改
+var debugEnabled atomic.Bool
+
+func debugf(format string, args ...interface{}) {
+	if debugEnabled.Load() {
+		log.Printf("[debug] "+format, args...)
+	}
+}
+
+// errInvalidText 表示文本为空或超过长度限制
+var errInvalidText = errors.New("文本为空或过长")
+
+// errEmptyText 表示（规范化后的）文本为空，按 Config.OnEmptyText 处理
+var errEmptyText = errors.New("文本为空")
+
+// 空文本的处理方式
+const (
+	emptySkip   = "skip"   // 记录警告后跳过（默认）
+	emptyBeep   = "beep"   // 朗读 EmptyTextPhrase，确认桥接器在工作
+	emptyReport = "report" // 向状态主题发布诊断信息
+)
+
+// errBadPayload 表示以 { 开头、本意是 JSON 的消息体无法解析或缺少文本字段
+var errBadPayload = errors.New("无效的 JSON 消息")
+
+// parsePayload 解析消息体：以 { 开头时按 JSON 解析，必须含 text 或 texts 字段，
+// 否则返回 errBadPayload；其余消息体整体作为纯文本
+func parsePayload(payload []byte) (ttsPayload, error) {
+	if !strings.HasPrefix(strings.TrimSpace(string(payload)), "{") {
+		return ttsPayload{Text: string(payload)}, nil
+	}
+	var j ttsPayload
+	if err := json.Unmarshal(payload, &j); err != nil {
+		return ttsPayload{}, fmt.Errorf("%w: %v", errBadPayload, err)
+	}
+	if j.Text == "" {
+		j.Text = j.Message
+	}
+	if j.Text == "" && len(j.Texts) == 0 && j.File == "" {
+		return ttsPayload{}, fmt.Errorf("%w: 缺少 text、texts 或 file 字段", errBadPayload)
+	}
+	if err := decodePayloadText(&j); err != nil {
+		return ttsPayload{}, err
+	}
+	return j, nil
+}
+
+// newSpeakRequests 为消息中的每段文本（texts 数组，或单个 text）生成朗读请求，
+// 其余字段对所有文本生效；无效的段落跳过，全部无效时返回错误。MQTT 与 HTTP 入口共用
+func newSpeakRequests(cfg *Config, p ttsPayload) ([]speakRequest, error) {
+	// 整条消息使用同一个轮换语音，多段文本之间不换声音
+	if strings.TrimSpace(p.Voice) == "" && strings.TrimSpace(p.Lang) == "" && len(cfg.VoiceRotation) > 0 {
+		p.Voice = voiceRotation.Pick(cfg.VoiceRotation)
+	}
+	if p.Output != "" {
+		dir, err := confineOutputDir(cfg.OutputDir, p.Output)
+		if err != nil {
+			return nil, err
+		}
+		p.Output = dir
+	}
+	if p.File != "" {
+		text, err := readTextFile(cfg.TextFileDir, p.File)
+		if err != nil {
+			return nil, err
+		}
+		p.Text, p.Texts = text, nil
+	}
+	if len(p.Texts) == 0 {
+		req, err := newSpeakRequest(cfg, p)
+		if err != nil {
+			return nil, err
+		}
+		return []speakRequest{req}, nil
+	}
+	var reqs []speakRequest
+	allEmpty := true
+	// 前缀只加在第一段、后缀只加在最后一段，整条消息只读一次
+	prefix, suffix, none := p.Prefix, p.Suffix, ""
+	for i, text := range p.Texts {
+		p.Text = text
+		p.Prefix, p.Suffix = prefix, suffix
+		if i > 0 {
+			p.Prefix = &none
+		}
+		if i < len(p.Texts)-1 {
+			p.Suffix = &none
+		}
+		req, err := newSpeakRequest(cfg, p)
+		if errors.Is(err, errBlocked) {
+			// 任何一段命中屏蔽规则时整条消息都不朗读
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("⚠️ texts[%d] %v，跳过", i, err)
+			allEmpty = allEmpty && errors.Is(err, errEmptyText)
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+	if len(reqs) == 0 {
+		if allEmpty {
+			return nil, errEmptyText
+		}
+		return nil, errInvalidText
+	}
+	return reqs, nil
+}
+
+// newSpeakRequest 校验单段文本并将消息字段与 cfg 中的默认值合并为朗读请求
+func newSpeakRequest(cfg *Config, p ttsPayload) (speakRequest, error) {
+	text := p.Text
+	if cfg.Normalize {
+		text = normalizeText(text, cfg.NormalizeMode)
+	}
+	// 两种模式都在规范化后的原文上匹配，不受数字展开、读音替换和前后缀的影响
+	if cfg.BlocklistMode == blocklistReplace {
+		var hit int
+		if text, hit = applyBlocklist(text, blocklistRules(cfg), true, cfg.BlocklistToken); hit >= 0 {
+			log.Printf("🚫 命中第 %d 条屏蔽规则，已替换为 %q", hit+1, cfg.BlocklistToken)
+		}
+	} else if _, hit := applyBlocklist(text, blocklistRules(cfg), false, ""); hit >= 0 {
+		log.Printf("🚫 命中第 %d 条屏蔽规则，不朗读", hit+1)
+		return speakRequest{}, errBlocked
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return speakRequest{}, errEmptyText
+	}
+
+	var replyTo string
+	if p.ReplyTo != "" {
+		if validReplyTopic(p.ReplyTo) {
+			replyTo = p.ReplyTo
+		} else {
+			log.Printf("⚠️ reply_to %q 不能含通配符，不回复", p.ReplyTo)
+		}
+	}
+
+	opts := speakOptions{Rate: cfg.Rate, Volume: cfg.Volume, OutputDir: cfg.OutputDir}
+	if p.Rate != nil {
+		opts.Rate = *p.Rate
+	}
+	if p.Volume != nil {
+		opts.Volume = *p.Volume
+	}
+	if p.Output != "" {
+		opts.OutputDir = p.Output
+	}
+	opts.Rate = clampInt(opts.Rate, -10, 10)
+	opts.Volume = clampInt(opts.Volume, 0, 100)
+
+	voice := strings.TrimSpace(p.Voice)
+	if voice != "" && !isValidVoiceName(voice) {
+		log.Printf("⚠️ 语音名称 %q 含有非法字符，使用默认语音", voice)
+		voice = ""
+	}
+	opts.Voice = voice
+
+	lang := strings.TrimSpace(p.Lang)
+	if lang != "" && !isValidLang(lang) {
+		log.Printf("⚠️ 语言标记 %q 无效（应为 BCP-47，如 zh-CN），使用默认语音", lang)
+		lang = ""
+	}
+	opts.Lang = lang
+
+	if p.SSML || strings.EqualFold(p.Format, "ssml") {
+		if isValidSSML(text) {
+			opts.SSML = true
+		} else {
+			log.Println("⚠️ SSML 格式无效（需为根元素 <speak> 的 XML），按普通文本朗读")
+		}
+	}
+
+	// 系统区域设置不对时 System.Speech 会用英文语音读中文，按文字或 DefaultCulture 选择语言；
+	// SSML 自带 xml:lang，不做推测
+	if opts.Voice == "" && opts.Lang == "" && !opts.SSML {
+		if cfg.DetectCulture {
+			opts.Lang = detectCulture(text)
+		}
+		if opts.Lang == "" {
+			opts.Lang = cfg.DefaultCulture
+		}
+	}
+
+	if cfg.ExpandNumbers && !opts.SSML {
+		text = expandNumbers(text, numberLocale(opts.Lang, cfg.NumberLocale))
+	}
+	text = applyPronunciations(text, cfg.Pronunciations, opts.SSML)
+
+	prefix, suffix := cfg.SpeakPrefix, cfg.SpeakSuffix
+	if p.Prefix != nil {
+		prefix = *p.Prefix
+	}
+	if p.Suffix != nil {
+		suffix = *p.Suffix
+	}
+	text = wrapSpeech(text, prefix, suffix, opts.SSML)
+
+	// SSML 无法安全拆分，超长时总是丢弃
+	if n := utf8.RuneCountInString(text); cfg.MaxTextLength > 0 && n > cfg.MaxTextLength && (!cfg.SplitLongText || opts.SSML) {
+		log.Printf("⚠️ 文本长度 %d 超过上限 %d（可启用 split_long_text 拆分朗读）", n, cfg.MaxTextLength)
+		return speakRequest{}, errInvalidText
+	}
+
+	return speakRequest{Text: text, Opts: opts, Priority: p.Priority, ReplyTo: replyTo, CorrelationID: p.CorrelationID}, nil
+}
+
+// validateConfig 校验合并后的配置，并将 broker 地址规范化（如补全 tcp://），
+// 命令行、环境变量和配置文件中的值都在这里统一校验
+func validateConfig(cfg *Config) error {
+	if len(cfg.Brokers) == 0 {
+		return errors.New("未配置 broker 地址")
+	}
+	brokers := make([]string, len(cfg.Brokers))
+	for i, broker := range cfg.Brokers {
+		normalized, err := validateBroker(broker)
+		if err != nil {
+			return errors.New(redactConfig(cfg, err.Error()))
+		}
+		if normalized != broker {
+			log.Printf("ℹ️ broker 地址已规范化为: %s", redactConfig(cfg, normalized))
+		}
+		brokers[i] = normalized
+	}
+	cfg.Brokers = brokers
+
+	if err := validateTopicSettings(cfg.TopicSettings); err != nil {
 		return err
 	}
+	if err := validatePublishers(cfg); err != nil {
+		return err
+	}
+	if err := validateDucking(cfg); err != nil {
+		return err
+	}
+	if err := validateVoiceRotation(cfg.VoiceRotation); err != nil {
+		return err
+	}
+
+	if cfg.QoS < 0 || cfg.QoS > 2 {
+		return fmt.Errorf("无效的 QoS 等级 %d，只能是 0、1 或 2", cfg.QoS)
+	}
+
+	switch cfg.ProtocolVersion {
+	case 0, 3, 4, 5:
+	default:
+		return fmt.Errorf("无效的 protocol_version %d，只能是 3、4 或 5", cfg.ProtocolVersion)
+	}
+
+	switch cfg.OnEmptyText {
+	case "", emptySkip, emptyBeep, emptyReport:
+	default:
+		return fmt.Errorf("无效的 on_empty_text %q，只能是 skip、beep 或 report", cfg.OnEmptyText)
+	}
+
+	switch cfg.NormalizeMode {
+	case "", normalizeStrip, normalizeDescribe:
+	default:
+		return fmt.Errorf("无效的 normalize_mode %q，只能是 strip 或 describe", cfg.NormalizeMode)
+	}
+
+	switch cfg.BlocklistMode {
+	case "", blocklistSkip, blocklistReplace:
+	default:
+		return fmt.Errorf("无效的 blocklist_mode %q，只能是 skip 或 replace", cfg.BlocklistMode)
+	}
+	if _, err := compileBlocklist(cfg.Blocklist); err != nil {
+		return fmt.Errorf("无效的 blocklist: %w", err)
+	}
+
+	switch strings.ToLower(cfg.PayloadEncoding) {
+	case "", encodingPlain, encodingBase64, encodingGzip:
+	default:
+		return fmt.Errorf("无效的 payload_encoding %q，只能是 plain、base64 或 gzip", cfg.PayloadEncoding)
+	}
+	if err := validCharset(cfg.PayloadCharset); err != nil {
+		return fmt.Errorf("无效的 payload_charset %q（如 utf-8、gbk、gb18030、big5）", cfg.PayloadCharset)
+	}
+	if err := validatePayloadFilter(cfg.PayloadFilter); err != nil {
+		return err
+	}
+	if cfg.Template != "" {
+		if _, err := parseTemplate(cfg.Template); err != nil {
+			return fmt.Errorf("无效的 template: %w", err)
+		}
+	}
+
+	if cfg.DefaultCulture != "" && !isValidLang(cfg.DefaultCulture) {
+		return fmt.Errorf("无效的 default_culture %q（应为 BCP-47，如 zh-CN）", cfg.DefaultCulture)
+	}
+
+	switch cfg.NumberLocale {
+	case "", numberLocaleZh, numberLocaleEn:
+	default:
+		return fmt.Errorf("无效的 number_locale %q，只能是 zh 或 en", cfg.NumberLocale)
+	}
 
-	log.Printf("🔊 朗读结束，耗时: %v", time.Since(start))
+	switch cfg.MuteMode {
+	case "", muteDrop, muteQueue:
+	default:
+		return fmt.Errorf("无效的 mute_mode %q，只能是 drop 或 queue", cfg.MuteMode)
+	}
+
+	if cfg.QuietHours != "" {
+		if _, err := parseQuietHours(cfg.QuietHours); err != nil {
+			return err
+		}
+	}
 
+	switch cfg.LogFormat {
+	case "", logFormatText, logFormatJSON:
+	default:
+		return fmt.Errorf("无效的 log_format %q，只能是 text 或 json", cfg.LogFormat)
+	}
 	return nil
 }
 
-func loadConfigFromFile(path string) (*Config, error) {
+// loadConfigFromFile 读取 JSON 配置文件，返回以 base 为基础、
+// 仅覆盖文件中出现的字段后的新配置
+func loadConfigFromFile(path string, base *Config) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("无法读取配置文件 %q: %w", path, err)
 	}
+	warnIfWorldReadable(path)
 	var raw map[string]interface{}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("配置文件 %q 不是有效的 JSON: %w", path, err)
 	}
 
 	// 手动提取字段（避免结构体零值覆盖）
-	cfg := &Config{}
-	if v, ok := raw["broker"]; ok {
-		if s, ok := v.(string); ok {
-			cfg.Broker = s
+	cfg := *base
+	// "brokers" 数组优先于 "broker"（可用逗号分隔多个）
+	var broker string
+	jsonString(raw, "broker", &broker)
+	if broker != "" {
+		cfg.Brokers = splitList(broker)
+	}
+	jsonStringList(raw, "brokers", &cfg.Brokers)
+	// "topics" 数组优先于单个 "topic"
+	var topic string
+	jsonString(raw, "topic", &topic)
+	if topic != "" {
+		cfg.Topics = splitList(topic)
+	}
+	jsonStringList(raw, "topics", &cfg.Topics)
+	jsonTopicSettings(raw, "topic_settings", &cfg.TopicSettings)
+	jsonSeverities(raw, "severities", &cfg.Severities)
+	jsonVoiceRotation(raw, "voice_rotation", &cfg.VoiceRotation)
+	jsonInt(raw, "voice_rotation_seed", &cfg.VoiceRotationSeed)
+	jsonInt(raw, "voice_refresh_minutes", &cfg.VoiceRefreshMinutes)
+	jsonBool(raw, "retry_missing_voice", &cfg.RetryMissingVoice)
+	jsonInt(raw, "qos", &cfg.QoS)
+	jsonBool(raw, "ignore_retained", &cfg.IgnoreRetained)
+	jsonString(raw, "payload_encoding", &cfg.PayloadEncoding)
+	jsonString(raw, "payload_charset", &cfg.PayloadCharset)
+	jsonString(raw, "template", &cfg.Template)
+	jsonStringList(raw, "payload_filter", &cfg.PayloadFilter)
+	jsonBool(raw, "payload_filter_require_json", &cfg.PayloadFilterRequireJSON)
+	jsonBool(raw, "manual_ack", &cfg.ManualAck)
+	jsonInt(raw, "protocol_version", &cfg.ProtocolVersion)
+	jsonString(raw, "username", &cfg.Username)
+	jsonString(raw, "password", &cfg.Password)
+	jsonString(raw, "client_id", &cfg.ClientID)
+	jsonInt(raw, "rate", &cfg.Rate)
+	jsonInt(raw, "volume", &cfg.Volume)
+	jsonInt(raw, "max_text_length", &cfg.MaxTextLength)
+	jsonBool(raw, "split_long_text", &cfg.SplitLongText)
+	jsonInt(raw, "chunk_pause_ms", &cfg.ChunkPauseMs)
+	jsonBool(raw, "keep_chunks_together", &cfg.KeepChunksTogether)
+	jsonInt(raw, "inter_utterance_gap_ms", &cfg.InterUtteranceGapMs)
+	jsonString(raw, "speak_prefix", &cfg.SpeakPrefix)
+	jsonString(raw, "speak_suffix", &cfg.SpeakSuffix)
+	jsonInt(raw, "speak_topic_segment", &cfg.SpeakTopicSegment)
+	jsonBool(raw, "speak_topic_humanize", &cfg.SpeakTopicHumanize)
+	jsonInt(raw, "queue_size", &cfg.QueueSize)
+	jsonInt(raw, "workers", &cfg.Workers)
+	jsonBool(raw, "queue_drop_oldest", &cfg.QueueDropOldest)
+	jsonBool(raw, "preempt", &cfg.Preempt)
+	jsonInt(raw, "tts_timeout_seconds", &cfg.TTSTimeoutSeconds)
+	jsonInt(raw, "speak_retries", &cfg.SpeakRetries)
+	jsonInt(raw, "speak_retry_delay_ms", &cfg.SpeakRetryDelayMs)
+	jsonBool(raw, "retry_on_timeout", &cfg.RetryOnTimeout)
+	jsonInt(raw, "max_age_seconds", &cfg.MaxAgeSeconds)
+	jsonInt(raw, "stats_interval_minutes", &cfg.StatsIntervalMinutes)
+	jsonInt(raw, "failure_threshold", &cfg.FailureThreshold)
+	jsonString(raw, "remediation_command", &cfg.RemediationCommand)
+	jsonInt(raw, "reconnect_max_seconds", &cfg.ReconnectMaxSeconds)
+	jsonBool(raw, "announce_reconnect", &cfg.AnnounceReconnect)
+	jsonString(raw, "reconnect_phrase", &cfg.ReconnectPhrase)
+	jsonInt(raw, "connect_timeout_seconds", &cfg.ConnectTimeoutSeconds)
+	jsonInt(raw, "startup_jitter_ms", &cfg.StartupJitterMs)
+	jsonInt(raw, "subscribe_timeout_seconds", &cfg.SubscribeTimeoutSeconds)
+	jsonString(raw, "ca_file", &cfg.CAFile)
+	jsonString(raw, "client_cert_file", &cfg.ClientCertFile)
+	jsonString(raw, "client_key_file", &cfg.ClientKeyFile)
+	jsonString(raw, "client_pfx_file", &cfg.ClientPFXFile)
+	jsonString(raw, "client_pfx_password", &cfg.ClientPFXPassword)
+	jsonBool(raw, "insecure_skip_verify", &cfg.InsecureSkipVerify)
+	jsonString(raw, "status_topic", &cfg.StatusTopic)
+	jsonStringList(raw, "allowed_publishers", &cfg.AllowedPublishers)
+	jsonString(raw, "publisher_property", &cfg.PublisherProperty)
+	jsonInt(raw, "publisher_topic_level", &cfg.PublisherTopicLevel)
+	jsonString(raw, "command_topic", &cfg.CommandTopic)
+	jsonString(raw, "events_topic", &cfg.EventsTopic)
+	jsonString(raw, "drop_topic", &cfg.DropTopic)
+	jsonString(raw, "now_speaking_topic", &cfg.NowSpeakingTopic)
+	jsonString(raw, "mute_mode", &cfg.MuteMode)
+	jsonString(raw, "quiet_hours", &cfg.QuietHours)
+	jsonInt(raw, "quiet_hours_bypass_priority", &cfg.QuietHoursBypassPriority)
+	jsonString(raw, "gate_topic", &cfg.GateTopic)
+	jsonString(raw, "gate_match_value", &cfg.GateMatchValue)
+	jsonString(raw, "will_topic", &cfg.WillTopic)
+	jsonString(raw, "will_payload", &cfg.WillPayload)
+	jsonBool(raw, "will_retain", &cfg.WillRetain)
+	jsonString(raw, "availability_topic", &cfg.AvailabilityTopic)
+	jsonString(raw, "online_payload", &cfg.OnlinePayload)
+	jsonString(raw, "unhealthy_payload", &cfg.UnhealthyPayload)
+	jsonString(raw, "http_addr", &cfg.HTTPAddr)
+	jsonString(raw, "speaker", &cfg.Speaker)
+	jsonString(raw, "powershell_path", &cfg.PowerShellPath)
+	jsonString(raw, "azure_key", &cfg.AzureKey)
+	jsonString(raw, "azure_region", &cfg.AzureRegion)
+	jsonString(raw, "azure_voice", &cfg.AzureVoice)
+	jsonInt(raw, "dedup_seconds", &cfg.DedupSeconds)
+	jsonInt(raw, "rate_limit_per_minute", &cfg.RateLimitPerMinute)
+	jsonInt(raw, "rate_limit_burst", &cfg.RateLimitBurst)
+	jsonBool(raw, "debug", &cfg.Debug)
+	jsonBool(raw, "selftest_on_start", &cfg.SelfTestOnStart)
+	jsonString(raw, "on_empty_text", &cfg.OnEmptyText)
+	jsonString(raw, "empty_text_phrase", &cfg.EmptyTextPhrase)
+	jsonBool(raw, "normalize", &cfg.Normalize)
+	jsonString(raw, "normalize_mode", &cfg.NormalizeMode)
+	jsonBool(raw, "expand_numbers", &cfg.ExpandNumbers)
+	jsonString(raw, "number_locale", &cfg.NumberLocale)
+	jsonBool(raw, "detect_culture", &cfg.DetectCulture)
+	jsonString(raw, "default_culture", &cfg.DefaultCulture)
+	jsonPronunciations(raw, "pronunciations", &cfg.Pronunciations)
+	jsonStringList(raw, "blocklist", &cfg.Blocklist)
+	jsonString(raw, "blocklist_mode", &cfg.BlocklistMode)
+	jsonString(raw, "blocklist_token", &cfg.BlocklistToken)
+	jsonString(raw, "output_dir", &cfg.OutputDir)
+	jsonString(raw, "text_file_dir", &cfg.TextFileDir)
+	jsonString(raw, "player_command", &cfg.PlayerCommand)
+	jsonString(raw, "audio_device", &cfg.AudioDevice)
+	jsonInt(raw, "duck_media_percent", &cfg.DuckMediaPercent)
+	jsonString(raw, "duck_command", &cfg.DuckCommand)
+	jsonString(raw, "duck_restore_command", &cfg.DuckRestoreCommand)
+	jsonString(raw, "audio_topic", &cfg.AudioTopic)
+	jsonInt(raw, "audio_chunk_size", &cfg.AudioChunkSize)
+	jsonString(raw, "cache_dir", &cfg.CacheDir)
+	jsonInt(raw, "cache_max_mb", &cfg.CacheMaxMB)
+	jsonString(raw, "log_format", &cfg.LogFormat)
+	jsonInt(raw, "max_log_size_mb", &cfg.MaxLogSizeMB)
+	jsonInt(raw, "log_backups", &cfg.LogBackups)
+	return &cfg, nil
+}
+
+// jsonString 在 raw[key] 为非空字符串时写入 dst
+func jsonString(raw map[string]interface{}, key string, dst *string) {
+	if v, ok := raw[key]; ok {
+		if s, ok := v.(string); ok && s != "" {
+			*dst = s
+		}
+	}
+}
+
+// jsonStringList 在 raw[key] 为非空字符串数组时写入 dst，忽略其中的非字符串和空字符串
+func jsonStringList(raw map[string]interface{}, key string, dst *[]string) {
+	v, ok := raw[key]
+	if !ok {
+		return
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return
+	}
+	var list []string
+	for _, item := range items {
+		if s, ok := item.(string); ok && strings.TrimSpace(s) != "" {
+			list = append(list, strings.TrimSpace(s))
+		}
+	}
+	if len(list) > 0 {
+		*dst = list
+	}
+}
+
+// defaultClientID 生成 tts-mqtt-<主机名>-<pid>，避免同一 broker 上的多个实例互相踢下线
+func defaultClientID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("tts-mqtt-%s-%d", host, os.Getpid())
+}
+
+// splitList 拆分逗号分隔的列表（主题、broker 地址），去掉空白项
+func splitList(s string) []string {
+	var topics []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			topics = append(topics, t)
+		}
+	}
+	return topics
+}
+
+// timeoutText 返回超时的可读形式，<= 0 为“不限时”
+func timeoutText(d time.Duration) string {
+	if d <= 0 {
+		return "不限时"
+	}
+	return d.String()
+}
+
+// waitToken 等待 token 完成，timeout <= 0 时一直等待；超时返回 false
+func waitToken(token mqtt.Token, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return token.Wait()
+	}
+	return token.WaitTimeout(timeout)
+}
+
+// subscribeTopics 以 qos 逐个订阅主题并分别记录结果，单个主题失败不影响其他主题，
+// 每个主题最多等待 timeout（<= 0 一直等待），返回订阅失败的主题
+func subscribeTopics(client mqtt.Client, topics []string, qos byte, handler mqtt.MessageHandler, timeout time.Duration) []string {
+	var failed []string
+	for _, topic := range topics {
+		token := client.Subscribe(topic, qos, handler)
+		if !waitToken(token, timeout) {
+			log.Printf("⚠️ 订阅主题超时: %s", topic)
+			failed = append(failed, topic)
+			continue
 		}
+		if err := token.Error(); err != nil {
+			log.Printf("⚠️ 订阅主题失败 %s: %v", topic, err)
+			failed = append(failed, topic)
+			continue
+		}
+		log.Printf("✅ 订阅成功: %s (QoS %d)", topic, qos)
 	}
-	if v, ok := raw["topic"]; ok {
-		if s, ok := v.(string); ok {
-			cfg.Topic = s
+	return failed
+}
+
+// jsonBool 在 raw[key] 为布尔值时写入 dst
+func jsonBool(raw map[string]interface{}, key string, dst *bool) {
+	if v, ok := raw[key]; ok {
+		if b, ok := v.(bool); ok {
+			*dst = b
 		}
 	}
-	if v, ok := raw["username"]; ok {
-		if s, ok := v.(string); ok {
-			cfg.Username = s
+}
+
+// jsonInt 在 raw[key] 为数字时写入 dst（0 也是有效值）
+func jsonInt(raw map[string]interface{}, key string, dst *int) {
+	if v, ok := raw[key]; ok {
+		if n, ok := v.(float64); ok {
+			*dst = int(n)
 		}
 	}
-	if v, ok := raw["password"]; ok {
-		if s, ok := v.(string); ok {
-			cfg.Password = s
+}
+
+// defaultConfig 返回未经任何配置来源覆盖的默认配置
+func defaultConfig() *Config {
+	return &Config{
+		Brokers:                 []string{"tcp://localhost:1883"},
+		Topics:                  []string{"home/tts/say"},
+		QoS:                     1,
+		Volume:                  100,
+		MaxTextLength:           500,
+		QueueSize:               32,
+		Workers:                 1,
+		TTSTimeoutSeconds:       30,
+		SpeakRetryDelayMs:       1000,
+		ReconnectMaxSeconds:     120,
+		ReconnectPhrase:         "MQTT 已重新连接",
+		ConnectTimeoutSeconds:   10,
+		SubscribeTimeoutSeconds: 5,
+		LogBackups:              3,
+		AzureVoice:              "zh-CN-XiaoxiaoNeural",
+		WillPayload:             "offline",
+		OnlinePayload:           "online",
+		UnhealthyPayload:        "unhealthy",
+		FailureThreshold:        5,
+		WillRetain:              true,
+		CacheMaxMB:              100,
+		AudioChunkSize:          defaultAudioChunkSize,
+		PowerShellPath:          "powershell",
+		GateMatchValue:          "home",
+		VoiceRefreshMinutes:     10,
+		EmptyTextPhrase:         "嘀",
+		BlocklistToken:          "哔",
+	}
+}
+
+// resolveConfig 以 defaults 为基础依次合并配置文件（configPath 为空则跳过）、环境变量（TTS_*）
+// 和 applyFlags 给出的命令行参数并校验，优先级：默认值 < 配置文件 < 环境变量 < 命令行参数。
+// 除读取配置文件和环境变量外没有副作用，defaults 不会被修改
+func resolveConfig(defaults *Config, configPath string, applyFlags func(*Config)) (*Config, error) {
+	cfg := defaults
+	if configPath != "" {
+		// 合并：配置文件中出现的字段覆盖默认值
+		fileCfg, err := loadConfigFromFile(configPath, cfg)
+		if err != nil {
+			return nil, err
 		}
+		cfg = fileCfg
+	}
+
+	// 环境变量（TTS_*）覆盖配置文件
+	cfg, err := loadConfigFromEnv(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if applyFlags != nil {
+		applyFlags(cfg)
+	}
+	// 在合并配置时而不是连接时补全默认的 client ID，热加载重新合并得到相同的值，
+	// 不会把 client_id 误报为已修改
+	if cfg.ClientID == "" {
+		cfg.ClientID = defaultClientID()
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
 	}
 	return cfg, nil
 }
@@ -150,30 +1305,221 @@ func main() {
         topic    string
         username string
         password string
+        clientID string
+        qos      int
+        ignoreRetained  bool
+        manualAck       bool
+        payloadEncoding string
+        payloadCharset  string
+        tmpl            string
+        payloadFilter   string
+        protocolVersion int
+        rate     int
+        volume   int
+        maxTextLength   int
+        splitLongText   bool
+        chunkPauseMs    int
+        keepChunks      bool
+        utteranceGapMs  int
+        speakPrefix     string
+        speakSuffix     string
+        topicSegment    int
+        topicHumanize   bool
+        queueSize       int
+        workers         int
+        queueDropOldest bool
+        preempt         bool
+        ttsTimeout      int
+        speakRetries    int
+        maxAge          int
+        statsInterval   int
+        voiceRefresh    int
+        retryVoice      bool
+        failureThreshold int
+        remediationCommand string
+        reconnectMax    int
+        announceReconn  bool
+        reconnPhrase    string
+        connectTimeout  int
+        startupJitter   int
+        subscribeTimeout int
+        caFile          string
+        clientCertFile  string
+        clientKeyFile   string
+        clientPFXFile   string
+        clientPFXPass   string
+        insecure        bool
+        statusTopic     string
+        allowedPubs     string
+        publisherProp   string
+        publisherLevel  int
+        commandTopic    string
+        eventsTopic     string
+        dropTopic       string
+        nowSpeaking     string
+        muteMode        string
+        quietHours      string
+        quietBypass     int
+        gateTopic       string
+        gateMatch       string
+        willTopic       string
+        availTopic      string
+        onlinePayload   string
+        unhealthyPayload string
+        httpAddr        string
+        dedupSeconds    int
+        rateLimit       int
+        rateBurst       int
+        debug           bool
+        onEmptyText     string
+        normalize       bool
+        normalizeMode   string
+        expandNumbers   bool
+        detectLang      bool
+        defaultCulture  string
+        numberLocaleArg string
+        dryRun          bool
+        selfTest        bool
+        once            bool
+        onceText        string
+        selfTestOnStart bool
+        speakerName     string
+        powerShell      string
+        azureKey        string
+        azureRegion     string
+        azureVoice      string
+        outputDir       string
+        textFileDir     string
+        playerCommand   string
+        audioDevice     string
+        duckPercent     int
+        audioTopic      string
+        audioChunkSize  int
+        cacheDir        string
+        cacheMaxMB      int
+        logFormat       string
+        maxLogSizeMB    int
+        logBackups      int
+        configPath string
         showHelp bool
+        showVersion bool
+        logPath    string
+        installSvc   bool
+        uninstallSvc bool
+        serviceName  string
+        logStdout  bool
+        logCaller  bool
     )
 
 
-	logFile, err := os.OpenFile("tts-mqtt.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "无法创建日志文件: %v\n", err)
-        os.Exit(1)
-    }
-    defer logFile.Close()
-
-    log.SetOutput(logFile)
-
-    // 设置日志前缀（含时间戳）
-    log.SetFlags(log.LstdFlags | log.Lshortfile) // Lshortfile 显示文件:行号，便于调试
-    // =============================
-
-	
-
-	pflag.StringVarP(&broker, "broker", "b", "", "MQTT Broker 地址 (e.g. tcp://localhost:1883)")
-    pflag.StringVarP(&topic, "topic", "t", "", "订阅的主题")
+	pflag.StringVarP(&broker, "broker", "b", "", "MQTT Broker 地址，多个用逗号分隔时按顺序故障转移 (e.g. tcp://localhost:1883、ssl://host:8883、ws://host:9001/mqtt)")
+    pflag.StringVarP(&topic, "topic", "t", "", "订阅的主题，多个用逗号分隔")
     pflag.StringVarP(&username, "username", "u", "", "MQTT 用户名")
     pflag.StringVarP(&password, "password", "p", "", "MQTT 密码")
+    pflag.StringVar(&clientID, "client-id", "", "MQTT 客户端 ID（默认 tts-mqtt-<主机名>-<pid>）")
+    pflag.IntVar(&qos, "qos", 1, "订阅 QoS 等级 (0/1/2)")
+    pflag.BoolVar(&ignoreRetained, "ignore-retained", false, "不朗读 broker 重发的保留消息（避免重连后重复朗读）")
+    pflag.StringVar(&payloadEncoding, "payload-encoding", "", "消息体编码：plain（默认）、base64 或 gzip")
+    pflag.StringVar(&payloadCharset, "payload-charset", "", "消息体字符集，如 gbk、gb18030、big5（默认 utf-8）")
+    pflag.StringVar(&tmpl, "template", "", "把 JSON 消息体代入该模板得到朗读文本 (e.g. \"温度 {{.value}} 度\")")
+    pflag.StringVar(&payloadFilter, "payload-filter", "", "只朗读满足全部条件的 JSON 消息，多个 key=value 用逗号分隔 (e.g. speak=true,event.type=doorbell)")
+    pflag.BoolVar(&manualAck, "manual-ack", false, "消息入队后才确认，队列满或限流时不确认由 broker 重发（需固定 --client-id）")
+    pflag.IntVar(&protocolVersion, "protocol-version", 0, "MQTT 协议版本：3（3.1）、4（3.1.1）或 5，0 自动协商")
+    pflag.IntVar(&rate, "rate", 0, "默认语速 (-10..10)")
+    pflag.IntVar(&volume, "volume", 100, "默认音量 (0..100)")
+    pflag.IntVar(&maxTextLength, "max-text-length", 500, "单条朗读的最大字符数（0 不限制）")
+    pflag.BoolVar(&splitLongText, "split-long-text", false, "超长文本按句拆分朗读，而不是丢弃")
+    pflag.IntVar(&chunkPauseMs, "chunk-pause", 0, "拆分后相邻两段之间的停顿毫秒数")
+    pflag.BoolVar(&keepChunks, "keep-chunks-together", false, "拆分出的各段连续朗读，高优先级消息不插入其间")
+    pflag.IntVar(&utteranceGapMs, "utterance-gap", 0, "相邻两条消息之间的停顿毫秒数")
+    pflag.StringVar(&speakPrefix, "speak-prefix", "", "加在每条消息前朗读的文字 (e.g. \"请注意：\")")
+    pflag.StringVar(&speakSuffix, "speak-suffix", "", "加在每条消息后朗读的文字")
+    pflag.IntVar(&topicSegment, "speak-topic-segment", 0, "将主题的第几级（负数从末尾数起）作为前缀读出，0 表示不读")
+    pflag.BoolVar(&topicHumanize, "speak-topic-humanize", false, "读出的主题级别中 _、- 换成空格，词首大写")
+    pflag.IntVar(&queueSize, "queue-size", 32, "朗读队列容量")
+    pflag.IntVar(&workers, "workers", 1, "并发朗读数量（>1 仅适合输出到文件或多音频设备）")
+    pflag.BoolVar(&queueDropOldest, "queue-drop-oldest", false, "队列满时丢弃最旧的消息（默认丢弃新消息）")
+    pflag.BoolVar(&preempt, "preempt", false, "高优先级消息打断当前朗读")
+    pflag.IntVar(&ttsTimeout, "tts-timeout", 30, "单条朗读超时秒数，超时终止 PowerShell 进程（<= 0 不限时）")
+    pflag.IntVar(&speakRetries, "speak-retries", 0, "朗读失败时的最多重试次数（0 不重试）")
+    pflag.IntVar(&maxAge, "max-age", 0, "消息排队超过该秒数时不再朗读（<= 0 不限）")
+    pflag.IntVar(&statsInterval, "stats-interval", 0, "每隔多少分钟在日志中记录统计摘要（<= 0 不记录）")
+    pflag.IntVar(&voiceRefresh, "voice-refresh", 10, "每隔多少分钟刷新已安装的语音列表（<= 0 只在启动时列出）")
+    pflag.BoolVar(&retryVoice, "retry-missing-voice", false, "指定的语音未安装时等待安装后再朗读，而不是改用默认语音")
+    pflag.IntVar(&failureThreshold, "failure-threshold", 5, "连续失败多少次后判定音频子系统挂起（<= 0 不检测）")
+    pflag.StringVar(&remediationCommand, "remediation-command", "", "连续失败达到阈值后执行的补救命令 (e.g. \"powershell -Command Restart-Service Audiosrv -Force\")")
+    pflag.IntVar(&reconnectMax, "reconnect-max", 120, "断线重连的最大间隔秒数（从 1 秒起指数增长并随机抖动）")
+    pflag.BoolVar(&announceReconn, "announce-reconnect", false, "断线后重新连接成功时朗读提示（首次连接不朗读）")
+    pflag.StringVar(&reconnPhrase, "reconnect-phrase", "", "重新连接成功时朗读的文本（默认“MQTT 已重新连接”）")
+    pflag.IntVar(&connectTimeout, "connect-timeout", 10, "连接 MQTT Broker 的超时秒数（<= 0 一直等待）")
+    pflag.IntVar(&startupJitter, "startup-jitter", 0, "首次连接前最多随机等待的毫秒数，避免大量实例同时连接（0 不等待）")
+    pflag.IntVar(&subscribeTimeout, "subscribe-timeout", 5, "订阅单个主题的超时秒数（<= 0 一直等待）")
+    pflag.StringVar(&caFile, "ca-file", "", "TLS 根证书 PEM 文件")
+    pflag.StringVar(&clientCertFile, "client-cert", "", "TLS 客户端证书 PEM 文件")
+    pflag.StringVar(&clientKeyFile, "client-key", "", "TLS 客户端私钥 PEM 文件")
+    pflag.StringVar(&clientPFXFile, "client-pfx", "", "TLS 客户端证书和私钥的 PKCS#12 (.pfx) 文件")
+    pflag.StringVar(&clientPFXPass, "client-pfx-password", "", "PKCS#12 文件的密码（建议改用 TTS_CLIENT_PFX_PASSWORD 环境变量）")
+    pflag.BoolVar(&insecure, "insecure", false, "跳过 TLS 服务端证书校验（仅用于测试）")
+    pflag.StringVar(&statusTopic, "status-topic", "", "朗读结束后发布回执的主题 (e.g. home/tts/status)")
+    pflag.StringVar(&allowedPubs, "allowed-publishers", "", "只朗读这些发布者（client ID）的消息，多个用逗号分隔，需配合 --publisher-property 或 --publisher-topic-level")
+    pflag.StringVar(&publisherProp, "publisher-property", "", "MQTT 5 消息中携带发布者 client ID 的 user property（需由 broker 写入）")
+    pflag.IntVar(&publisherLevel, "publisher-topic-level", 0, "主题中第几级为发布者的 client ID（负数从末尾数起，需 broker ACL 保证）")
+    pflag.StringVar(&eventsTopic, "events-topic", "", "连接状态事件（connected、disconnected、reconnecting）的主题 (e.g. home/tts/events)")
+    pflag.StringVar(&dropTopic, "drop-topic", "", "消息未朗读即被丢弃时发布事件的主题 (e.g. home/tts/dropped)")
+    pflag.StringVar(&nowSpeaking, "now-speaking-topic", "", "以保留消息发布正在朗读内容的主题，读完后清除 (e.g. home/tts/now)")
+    pflag.StringVar(&commandTopic, "command-topic", "", "命令主题 (e.g. home/tts/cmd)，支持 list_voices、mute、unmute、stop、reload、status，结果发布到回执主题")
+    pflag.StringVar(&muteMode, "mute-mode", "", "静音期间的消息：drop（丢弃，默认）或 queue（解除后朗读）")
+    pflag.StringVar(&quietHours, "quiet-hours", "", "每天不朗读的时段（本地时间），如 22:00-07:00")
+    pflag.IntVar(&quietBypass, "quiet-bypass-priority", 0, "优先级不低于该值的消息在安静时段照常朗读（0 不豁免）")
+    pflag.StringVar(&gateTopic, "gate-topic", "", "门控主题 (e.g. home/presence)，其值与 --gate-match 一致时才朗读")
+    pflag.StringVar(&gateMatch, "gate-match", "", "门控主题的值为该值时朗读（默认 home，忽略大小写）")
+    pflag.StringVar(&willTopic, "will-topic", "", "遗嘱消息主题，异常断开时发布 offline、连接后发布 online")
+    pflag.StringVar(&availTopic, "availability-topic", "", "在线状态主题，订阅成功后发布保留的 online（默认同 --will-topic）")
+    pflag.StringVar(&onlinePayload, "online-payload", "", "在线状态消息内容（默认 online）")
+    pflag.StringVar(&unhealthyPayload, "unhealthy-payload", "", "TTS 连续失败时的在线状态消息内容（默认 unhealthy）")
+    pflag.StringVar(&httpAddr, "http-addr", "", "启用 HTTP 接口的监听地址 (e.g. :8080)，提供 POST /say、GET /healthz 和 GET /metrics")
+    pflag.IntVar(&dedupSeconds, "dedup", 0, "该秒数内与上一条相同的文本不再朗读（0 不去重）")
+    pflag.IntVar(&rateLimit, "rate-limit", 0, "每分钟最多朗读的消息数，超出丢弃（0 不限流）")
+    pflag.IntVar(&rateBurst, "rate-burst", 0, "限流允许的突发消息数（默认等于 --rate-limit）")
+    pflag.BoolVar(&debug, "debug", false, "输出调试日志")
+    pflag.StringVar(&onEmptyText, "on-empty-text", "", "空文本消息的处理方式：skip（默认）、beep（朗读提示音）或 report（发布到状态主题）")
+    pflag.BoolVar(&normalize, "normalize", false, "朗读前处理 emoji、删除控制字符并折叠空白")
+    pflag.StringVar(&normalizeMode, "normalize-mode", "", "emoji 处理方式：strip（删除，默认）或 describe（读作文字）")
+    pflag.BoolVar(&expandNumbers, "expand-numbers", false, "将日期、金额和带千位分隔符的数字改写为朗读形式")
+    pflag.StringVar(&numberLocaleArg, "number-locale", "", "数字朗读的语言：zh（默认）或 en")
+    pflag.BoolVar(&detectLang, "detect-culture", false, "消息未指定 voice 和 lang 时按文字（中日韩、拉丁字母）推测朗读语言")
+    pflag.StringVar(&defaultCulture, "default-culture", "", "消息未指定 voice 和 lang（且无法推测）时使用的语言 (e.g. zh-CN)")
+    pflag.StringVar(&outputDir, "output-dir", "", "将朗读保存为该目录下的 .wav 文件，而不是播放")
+    pflag.StringVar(&textFileDir, "text-file-dir", "", "允许消息通过 file 字段朗读的文本文件目录")
+    pflag.StringVar(&playerCommand, "player", "", "播放 .wav 的命令，{file}、{device} 为占位符 (e.g. \"mpv --audio-device={device} {file}\")")
+    pflag.StringVar(&audioDevice, "audio-device", "", "输出音频设备名称，需配合含 {device} 的 --player 使用")
+    pflag.IntVar(&duckPercent, "duck-media-percent", 0, "朗读时把其他程序的音量降低到原来的百分比 (e.g. 30)，0 表示不降低")
+    pflag.StringVar(&audioTopic, "audio-topic", "", "把合成的 .wav 分块发布到该主题供远程播放，而不是在本机播放")
+    pflag.IntVar(&audioChunkSize, "audio-chunk-size", defaultAudioChunkSize, "发布音频时每块的字节数")
+    pflag.StringVar(&cacheDir, "cache-dir", "", "合成音频的缓存目录，重复的文本直接播放缓存（空则不缓存）")
+    pflag.IntVar(&cacheMaxMB, "cache-max-mb", 100, "音频缓存的最大总大小 (MB)，超出时淘汰最久未使用的文件")
+    pflag.StringVar(&logFormat, "log-format", "", "日志格式：text（默认）或 json")
+    pflag.IntVar(&maxLogSizeMB, "max-log-size", 0, "日志文件超过该大小（MB）时轮转（0 不轮转）")
+    pflag.IntVar(&logBackups, "log-backups", 3, "轮转时保留的旧日志文件数量")
+    pflag.StringVar(&speakerName, "speaker", "", "TTS 后端：powershell、say、espeak、azure、noop（默认按操作系统选择）")
+    pflag.StringVar(&powerShell, "powershell-path", "", "PowerShell 可执行文件，如 pwsh 或绝对路径（默认 powershell）")
+    pflag.StringVar(&azureKey, "azure-key", "", "Azure Speech 服务密钥")
+    pflag.StringVar(&azureRegion, "azure-region", "", "Azure Speech 服务区域 (e.g. eastasia)")
+    pflag.StringVar(&azureVoice, "azure-voice", "", "Azure 默认语音 (默认 zh-CN-XiaoxiaoNeural)")
+    pflag.BoolVar(&selfTest, "selftest", false, "合成一段短语到临时 .wav 检查 TTS 是否可用，输出结果后退出")
+    pflag.BoolVar(&once, "once", false, "不连接 MQTT，朗读 --text 或标准输入中的文本后退出（失败时退出码为 1）")
+    pflag.StringVar(&onceText, "text", "", "--once 模式下朗读的文本（隐含 --once）")
+    pflag.BoolVar(&selfTestOnStart, "selftest-on-start", false, "启动时先做一次 TTS 自检，失败只记录错误")
+    pflag.BoolVar(&dryRun, "dry-run", false, "只连接 MQTT 并记录将要朗读的内容，不调用 PowerShell")
+    pflag.StringVarP(&configPath, "config", "c", "", "配置文件路径（默认自动加载当前目录下的 config.json）")
     pflag.BoolVarP(&showHelp, "help", "h", false, "显示帮助")
+    pflag.BoolVar(&showVersion, "version", false, "显示版本和构建信息后退出")
+    pflag.StringVar(&logPath, "log-file", "tts-mqtt.log", "日志文件路径")
+    pflag.BoolVar(&logStdout, "log-stdout", false, "日志输出到标准输出而不是文件（适合容器环境）")
+    pflag.BoolVar(&logCaller, "log-caller", version == "dev", "日志中显示源文件和行号（未注入版本号的开发构建默认开启）")
+    pflag.BoolVar(&installSvc, "install-service", false, "注册为开机启动的 Windows 服务（其余参数作为服务启动参数）后退出")
+    pflag.BoolVar(&uninstallSvc, "uninstall-service", false, "卸载 Windows 服务后退出")
+    pflag.StringVar(&serviceName, "service-name", "win-tts-api", "Windows 服务名称")
     pflag.Parse()
 
 	if showHelp {
@@ -181,51 +1527,79 @@ func main() {
 		os.Exit(0)
 	}
 
+	if showVersion {
+		fmt.Println(versionString())
+		os.Exit(0)
+	}
+
+	// 日志默认写入当前目录的 tts-mqtt.log，轮转阈值在读取配置后设置；
+	// --log-stdout 时 logFile 为 nil，不轮转也不响应 SIGHUP
+	var logOut io.Writer = os.Stdout
+	var logFile *rotatingFile
+	if !logStdout {
+		f, err := openRotatingFile(logPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❌ 无法写入日志文件 %q: %v\n", logPath, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		logFile, logOut = f, f
+	}
+	log.SetOutput(logOut)
+
+	// 设置日志前缀（含时间戳），--log-caller 时加上文件:行号，便于调试
+	if logCaller {
+		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	} else {
+		log.SetFlags(log.LstdFlags)
+	}
+
+	if installSvc || uninstallSvc {
+		var err error
+		if installSvc {
+			err = installService(serviceName, serviceArgs(os.Args[1:]))
+		} else {
+			err = uninstallService(serviceName)
+		}
+		if err != nil {
+			log.Printf("❌ %v", err)
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("完成")
+		os.Exit(0)
+	}
+
+	// 由 Windows 服务管理器启动时，停止服务会取消 svcCtx，效果与 Ctrl-C 相同
+	svcCtx, finishService := startService(serviceName)
+	defer finishService()
+
 	if showHelp {
         pflag.Usage()
         os.Exit(0)
     }
 
     // 默认配置
-    cfg := &Config{
-        Broker: "tcp://localhost:1883",
-        Topic:  "home/tts/say",
-    }
+    defaults := defaultConfig()
 
     const defaultConfigFile = "config.json"
-    var loadedFromConfig = false
 
-    // ✅ 自动检测 config.json 是否存在
-    if _, err := os.Stat(defaultConfigFile); err == nil {
-        // 文件存在，尝试加载
-        fileCfg, err := loadConfigFromFile(defaultConfigFile)
-        if err != nil {
-            log.Fatalf("❌ 配置文件 %q 存在但加载失败: %v", defaultConfigFile, err)
-        }
-        // 合并：配置文件字段优先，非空才覆盖
-        if fileCfg.Broker != "" {
-            cfg.Broker = fileCfg.Broker
-        }
-        if fileCfg.Topic != "" {
-            cfg.Topic = fileCfg.Topic
-        }
-        if fileCfg.Username != "" {
-            cfg.Username = fileCfg.Username
-        }
-        if fileCfg.Password != "" {
-            cfg.Password = fileCfg.Password
+    // ✅ 显式指定 -c 时文件必须存在；否则自动检测 config.json 是否存在
+    if configPath != "" {
+        if _, err := os.Stat(configPath); err != nil {
+            log.Fatalf("❌ 指定的配置文件 %q 不可用: %v", configPath, err)
         }
-        loadedFromConfig = true
-        log.Printf("✅ 使用配置文件: %s", defaultConfigFile)
+    } else if _, err := os.Stat(defaultConfigFile); err == nil {
+        configPath = defaultConfigFile
     }
 
-    // ✅ 仅当未从配置文件加载时，才应用命令行参数
-    if !loadedFromConfig {
-        if broker != "" {
-            cfg.Broker = broker
+    // applyFlags 用显式给出的命令行参数覆盖其余来源
+    applyFlags := func(cfg *Config) {
+        if brokers := splitList(broker); len(brokers) > 0 {
+            cfg.Brokers = brokers
         }
-        if topic != "" {
-            cfg.Topic = topic
+        if topics := splitList(topic); len(topics) > 0 {
+            cfg.Topics = topics
         }
         if username != "" {
             cfg.Username = username
@@ -233,65 +1607,359 @@ func main() {
         if password != "" {
             cfg.Password = password
         }
-        log.Println("ℹ️ 未找到 config.json，使用命令行参数或默认值")
+        if clientID != "" {
+            cfg.ClientID = clientID
+        }
+        if pflag.CommandLine.Changed("qos") {
+            cfg.QoS = qos
+        }
+        if payloadEncoding != "" {
+            cfg.PayloadEncoding = payloadEncoding
+        }
+        if payloadCharset != "" {
+            cfg.PayloadCharset = payloadCharset
+        }
+        if tmpl != "" {
+            cfg.Template = tmpl
+        }
+        if filter := splitList(payloadFilter); len(filter) > 0 {
+            cfg.PayloadFilter = filter
+        }
+        if pflag.CommandLine.Changed("manual-ack") {
+            cfg.ManualAck = manualAck
+        }
+        if pflag.CommandLine.Changed("ignore-retained") {
+            cfg.IgnoreRetained = ignoreRetained
+        }
+        if pflag.CommandLine.Changed("protocol-version") {
+            cfg.ProtocolVersion = protocolVersion
+        }
+        if pflag.CommandLine.Changed("rate") {
+            cfg.Rate = rate
+        }
+        if pflag.CommandLine.Changed("volume") {
+            cfg.Volume = volume
+        }
+        if pflag.CommandLine.Changed("max-text-length") {
+            cfg.MaxTextLength = maxTextLength
+        }
+        if pflag.CommandLine.Changed("split-long-text") {
+            cfg.SplitLongText = splitLongText
+        }
+        if pflag.CommandLine.Changed("chunk-pause") {
+            cfg.ChunkPauseMs = chunkPauseMs
+        }
+        if pflag.CommandLine.Changed("keep-chunks-together") {
+            cfg.KeepChunksTogether = keepChunks
+        }
+        if pflag.CommandLine.Changed("utterance-gap") {
+            cfg.InterUtteranceGapMs = utteranceGapMs
+        }
+        if speakPrefix != "" {
+            cfg.SpeakPrefix = speakPrefix
+        }
+        if speakSuffix != "" {
+            cfg.SpeakSuffix = speakSuffix
+        }
+        if pflag.CommandLine.Changed("speak-topic-segment") {
+            cfg.SpeakTopicSegment = topicSegment
+        }
+        if pflag.CommandLine.Changed("speak-topic-humanize") {
+            cfg.SpeakTopicHumanize = topicHumanize
+        }
+        if pflag.CommandLine.Changed("queue-size") {
+            cfg.QueueSize = queueSize
+        }
+        if pflag.CommandLine.Changed("workers") {
+            cfg.Workers = workers
+        }
+        if pflag.CommandLine.Changed("queue-drop-oldest") {
+            cfg.QueueDropOldest = queueDropOldest
+        }
+        if pflag.CommandLine.Changed("preempt") {
+            cfg.Preempt = preempt
+        }
+        if pflag.CommandLine.Changed("tts-timeout") {
+            cfg.TTSTimeoutSeconds = ttsTimeout
+        }
+        if pflag.CommandLine.Changed("speak-retries") {
+            cfg.SpeakRetries = speakRetries
+        }
+        if pflag.CommandLine.Changed("max-age") {
+            cfg.MaxAgeSeconds = maxAge
+        }
+        if pflag.CommandLine.Changed("stats-interval") {
+            cfg.StatsIntervalMinutes = statsInterval
+        }
+        if pflag.CommandLine.Changed("voice-refresh") {
+            cfg.VoiceRefreshMinutes = voiceRefresh
+        }
+        if pflag.CommandLine.Changed("retry-missing-voice") {
+            cfg.RetryMissingVoice = retryVoice
+        }
+        if pflag.CommandLine.Changed("failure-threshold") {
+            cfg.FailureThreshold = failureThreshold
+        }
+        if remediationCommand != "" {
+            cfg.RemediationCommand = remediationCommand
+        }
+        if pflag.CommandLine.Changed("reconnect-max") {
+            cfg.ReconnectMaxSeconds = reconnectMax
+        }
+        if pflag.CommandLine.Changed("announce-reconnect") {
+            cfg.AnnounceReconnect = announceReconn
+        }
+        if reconnPhrase != "" {
+            cfg.ReconnectPhrase = reconnPhrase
+        }
+        if pflag.CommandLine.Changed("connect-timeout") {
+            cfg.ConnectTimeoutSeconds = connectTimeout
+        }
+        if pflag.CommandLine.Changed("startup-jitter") {
+            cfg.StartupJitterMs = startupJitter
+        }
+        if pflag.CommandLine.Changed("subscribe-timeout") {
+            cfg.SubscribeTimeoutSeconds = subscribeTimeout
+        }
+        if caFile != "" {
+            cfg.CAFile = caFile
+        }
+        if clientCertFile != "" {
+            cfg.ClientCertFile = clientCertFile
+        }
+        if clientKeyFile != "" {
+            cfg.ClientKeyFile = clientKeyFile
+        }
+        if clientPFXFile != "" {
+            cfg.ClientPFXFile = clientPFXFile
+        }
+        if clientPFXPass != "" {
+            cfg.ClientPFXPassword = clientPFXPass
+        }
+        if pflag.CommandLine.Changed("insecure") {
+            cfg.InsecureSkipVerify = insecure
+        }
+        if statusTopic != "" {
+            cfg.StatusTopic = statusTopic
+        }
+        if pubs := splitList(allowedPubs); len(pubs) > 0 {
+            cfg.AllowedPublishers = pubs
+        }
+        if publisherProp != "" {
+            cfg.PublisherProperty = publisherProp
+        }
+        if pflag.CommandLine.Changed("publisher-topic-level") {
+            cfg.PublisherTopicLevel = publisherLevel
+        }
+        if eventsTopic != "" {
+            cfg.EventsTopic = eventsTopic
+        }
+        if dropTopic != "" {
+            cfg.DropTopic = dropTopic
+        }
+        if nowSpeaking != "" {
+            cfg.NowSpeakingTopic = nowSpeaking
+        }
+        if commandTopic != "" {
+            cfg.CommandTopic = commandTopic
+        }
+        if muteMode != "" {
+            cfg.MuteMode = muteMode
+        }
+        if quietHours != "" {
+            cfg.QuietHours = quietHours
+        }
+        if pflag.CommandLine.Changed("quiet-bypass-priority") {
+            cfg.QuietHoursBypassPriority = quietBypass
+        }
+        if gateTopic != "" {
+            cfg.GateTopic = gateTopic
+        }
+        if gateMatch != "" {
+            cfg.GateMatchValue = gateMatch
+        }
+        if willTopic != "" {
+            cfg.WillTopic = willTopic
+        }
+        if availTopic != "" {
+            cfg.AvailabilityTopic = availTopic
+        }
+        if onlinePayload != "" {
+            cfg.OnlinePayload = onlinePayload
+        }
+        if unhealthyPayload != "" {
+            cfg.UnhealthyPayload = unhealthyPayload
+        }
+        if httpAddr != "" {
+            cfg.HTTPAddr = httpAddr
+        }
+        if pflag.CommandLine.Changed("dedup") {
+            cfg.DedupSeconds = dedupSeconds
+        }
+        if pflag.CommandLine.Changed("rate-limit") {
+            cfg.RateLimitPerMinute = rateLimit
+        }
+        if pflag.CommandLine.Changed("rate-burst") {
+            cfg.RateLimitBurst = rateBurst
+        }
+        if pflag.CommandLine.Changed("selftest-on-start") {
+            cfg.SelfTestOnStart = selfTestOnStart
+        }
+        if dryRun {
+            cfg.DryRun = true
+        }
+        if pflag.CommandLine.Changed("debug") {
+            cfg.Debug = debug
+        }
+        if onEmptyText != "" {
+            cfg.OnEmptyText = onEmptyText
+        }
+        if pflag.CommandLine.Changed("normalize") {
+            cfg.Normalize = normalize
+        }
+        if normalizeMode != "" {
+            cfg.NormalizeMode = normalizeMode
+        }
+        if pflag.CommandLine.Changed("expand-numbers") {
+            cfg.ExpandNumbers = expandNumbers
+        }
+        if numberLocaleArg != "" {
+            cfg.NumberLocale = numberLocaleArg
+        }
+        if pflag.CommandLine.Changed("detect-culture") {
+            cfg.DetectCulture = detectLang
+        }
+        if defaultCulture != "" {
+            cfg.DefaultCulture = defaultCulture
+        }
+        if outputDir != "" {
+            cfg.OutputDir = outputDir
+        }
+        if textFileDir != "" {
+            cfg.TextFileDir = textFileDir
+        }
+        if playerCommand != "" {
+            cfg.PlayerCommand = playerCommand
+        }
+        if audioDevice != "" {
+            cfg.AudioDevice = audioDevice
+        }
+        if pflag.CommandLine.Changed("duck-media-percent") {
+            cfg.DuckMediaPercent = duckPercent
+        }
+        if audioTopic != "" {
+            cfg.AudioTopic = audioTopic
+        }
+        if pflag.CommandLine.Changed("audio-chunk-size") {
+            cfg.AudioChunkSize = audioChunkSize
+        }
+        if cacheDir != "" {
+            cfg.CacheDir = cacheDir
+        }
+        if pflag.CommandLine.Changed("cache-max-mb") {
+            cfg.CacheMaxMB = cacheMaxMB
+        }
+        if speakerName != "" {
+            cfg.Speaker = speakerName
+        }
+        if powerShell != "" {
+            cfg.PowerShellPath = powerShell
+        }
+        if azureKey != "" {
+            cfg.AzureKey = azureKey
+        }
+        if azureRegion != "" {
+            cfg.AzureRegion = azureRegion
+        }
+        if azureVoice != "" {
+            cfg.AzureVoice = azureVoice
+        }
+        if logFormat != "" {
+            cfg.LogFormat = logFormat
+        }
+        if pflag.CommandLine.Changed("max-log-size") {
+            cfg.MaxLogSizeMB = maxLogSizeMB
+        }
+        if pflag.CommandLine.Changed("log-backups") {
+            cfg.LogBackups = logBackups
+        }
+    }
+
+    // loadConfig 依次合并默认值、配置文件、环境变量和命令行参数并校验，
+    // 启动时和配置文件热加载时共用
+    loadConfig := func() (*Config, error) {
+        return resolveConfig(defaults, configPath, applyFlags)
+    }
+
+    cfg, err := loadConfig()
+    if err != nil {
+        log.Fatalf("❌ 加载配置失败: %v", err)
+    }
+    if configPath != "" {
+        log.Printf("✅ 使用配置文件: %s", configPath)
+    } else {
+        log.Println("ℹ️ 未找到配置文件，使用命令行参数或默认值")
     }
-	
-	// 启动 MQTT 客户端
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(cfg.Broker)
-	opts.SetClientID("go-tts-client")
-	opts.SetAutoReconnect(true)
-	opts.SetConnectRetry(true)
-	opts.SetConnectRetryInterval(5 * time.Second)
-
-	opts.SetOnConnectHandler(func(client mqtt.Client) {
-	    log.Println("🔌 MQTT 连接成功，正在重新订阅主题...")
-	    token := client.Subscribe(cfg.Topic, 1, f)
-	    if !token.WaitTimeout(5 * time.Second) || token.Error() != nil {
-	        log.Fatalf("❌ 重订阅失败: %v", token.Error())
-	    }
-	    log.Printf("✅ 重订阅成功: %s", cfg.Topic)
-	})
-	
-	// 可选：添加连接丢失回调用于调试
-	opts.SetConnectionLostHandler(func(client mqtt.Client, err error) {
-	    log.Printf("⚠️ MQTT 连接已断开: %v", err)
-	})
-
-	if cfg.Username != "" {
-		opts.SetUsername(cfg.Username)
-	}
-	if cfg.Password != "" {
-		opts.SetPassword(cfg.Password)
-	}
-
-	client := mqtt.NewClient(opts)
-	
-	token := client.Connect()
-	// 设置 10 秒超时
-	if !token.WaitTimeout(10 * time.Second) {
-	    log.Fatal("❌ 连接 MQTT Broker 超时（10秒）")
-	}
-	if err := token.Error(); err != nil {
-	    log.Fatalf("❌ 无法连接到 MQTT Broker: %v", err)
-	}
-		
-	token = client.Subscribe(cfg.Topic, 1, f)
-	if !token.WaitTimeout(10 * time.Second) {
-		log.Fatalf("订阅主题超时 %s: %v", cfg.Topic, token.Error())
-	}
-	if err := token.Error(); err != nil {
-	    log.Fatalf("❌ 无法订阅主题: %v", err)
-	}
-
-	log.Printf("✅ 已连接 MQTT Broker: %s", cfg.Broker)
-	if cfg.Username != "" {
-		log.Printf("👤 使用用户名: %s", cfg.Username)
-	}
-	log.Printf("🎧 正在监听主题: %s", cfg.Topic)
-	log.Println("💡 示例:")
-	log.Println(`   tts-mqtt.exe -b tcp://192.168.1.100:1883 -t my/tts -u user -p pass`)
-	log.Println(`   tts-mqtt.exe -c config.json`)
-
-	select {}
+
+	if cfg.LogFormat == logFormatJSON {
+		setupJSONLogging(logOut)
+	}
+	log.Printf("🏷️ %s", versionString())
+
+	debugEnabled.Store(cfg.Debug)
+
+	if cfg.MaxLogSizeMB > 0 && logFile != nil {
+		logFile.SetLimits(int64(cfg.MaxLogSizeMB)<<20, cfg.LogBackups)
+		log.Printf("📝 日志超过 %d MB 时轮转，保留 %d 个旧文件", cfg.MaxLogSizeMB, cfg.LogBackups)
+	}
+
+	// 默认单个 worker 依次朗读，保证语音不重叠；高优先级先读，同优先级按到达顺序
+	powerShellPath = cfg.PowerShellPath
+	speaker, err := newOutputSpeaker(cfg)
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+	log.Printf("🔈 TTS 后端: %T", speaker)
+	if selfTest {
+		// 自检结果同时输出到控制台，便于直接在命令行查看
+		if err := runSelfTest(context.Background(), speaker, time.Duration(cfg.TTSTimeoutSeconds)*time.Second); err != nil {
+			log.Printf("❌ TTS 自检失败: %v", err)
+			fmt.Fprintf(os.Stderr, "TTS 自检失败: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("TTS 自检通过")
+		os.Exit(0)
+	}
+	if cfg.SelfTestOnStart {
+		if err := runSelfTest(context.Background(), speaker, time.Duration(cfg.TTSTimeoutSeconds)*time.Second); err != nil {
+			log.Printf("❌ TTS 自检失败，朗读可能无法正常工作: %v", err)
+		}
+	}
+	if cfg.VoiceRotationSeed != 0 {
+		voiceRotation.Seed(int64(cfg.VoiceRotationSeed))
+	}
+	if len(cfg.VoiceRotation) > 0 {
+		checkVoiceRotation(context.Background(), speaker, cfg.VoiceRotation)
+		log.Printf("🎲 语音轮换: %d 个语音按权重随机选择", len(cfg.VoiceRotation))
+	}
+	if once || onceText != "" {
+		onceCtx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := runOnce(onceCtx, cfg, speaker, onceText)
+		cancel()
+		if err != nil {
+			log.Printf("❌ 朗读失败: %v", err)
+			fmt.Fprintf(os.Stderr, "朗读失败: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	// Ctrl-C / SIGTERM 时取消 ctx：终止正在进行的朗读并断开 MQTT
+	ctx, stop := signal.NotifyContext(svcCtx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if logFile != nil {
+		go reopenOnSIGHUP(ctx, logFile)
+	}
+	if err := run(ctx, cfg, speaker, runDeps{ConfigPath: configPath, Reload: loadConfig}); err != nil {
+		log.Fatalf("❌ %v", err)
+	}
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 每个字段取优先级最高的来源：默认值 < config.json < TTS_* 环境变量 < 命令行参数
+func TestResolveConfigPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `{
+		"broker": "tcp://file.local:1883",
+		"topic": "file/tts",
+		"qos": 2,
+		"rate": 2,
+		"username": "file-user",
+		"client_id": "tts-file"
+	}`)
+	t.Setenv("TTS_TOPIC", "env/tts")
+	t.Setenv("TTS_RATE", "4")
+	t.Setenv("TTS_USERNAME", "env-user")
+	t.Setenv("TTS_CLIENT_ID", "")
+	flags := func(cfg *Config) {
+		cfg.Topics = []string{"flag/tts"}
+	}
+
+	cfg, err := resolveConfig(defaultConfig(), path, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		field string
+		got   interface{}
+		want  interface{}
+	}{
+		{"volume（默认值）", cfg.Volume, 100},
+		{"broker（配置文件）", cfg.Brokers[0], "tcp://file.local:1883"},
+		{"qos（配置文件）", cfg.QoS, 2},
+		{"client_id（配置文件，环境变量为空不覆盖）", cfg.ClientID, "tts-file"},
+		{"rate（环境变量覆盖配置文件）", cfg.Rate, 4},
+		{"username（环境变量覆盖配置文件）", cfg.Username, "env-user"},
+		{"topic（命令行覆盖环境变量）", cfg.Topics[0], "flag/tts"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v，期望 %v", tt.field, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(cfg.Topics, []string{"flag/tts"}) {
+		t.Errorf("topics = %v", cfg.Topics)
+	}
+}
+
+// 没有配置文件时环境变量直接覆盖默认值，defaults 本身不被修改
+func TestResolveConfigWithoutFile(t *testing.T) {
+	t.Setenv("TTS_BROKER", "tcp://a.local:1883,tcp://b.local:1883")
+	t.Setenv("TTS_QOS", "0")
+	defaults := defaultConfig()
+	cfg, err := resolveConfig(defaults, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.Brokers, []string{"tcp://a.local:1883", "tcp://b.local:1883"}) || cfg.QoS != 0 {
+		t.Errorf("brokers = %v, qos = %d", cfg.Brokers, cfg.QoS)
+	}
+	if defaults.QoS != 1 || len(defaults.Brokers) != 1 {
+		t.Errorf("defaults 被修改: qos = %d, brokers = %v", defaults.QoS, defaults.Brokers)
+	}
+}
+
+func TestResolveConfigRejectsInvalidSources(t *testing.T) {
+	t.Run("bad env number", func(t *testing.T) {
+		t.Setenv("TTS_RATE", "fast")
+		if _, err := resolveConfig(defaultConfig(), "", nil); err == nil {
+			t.Error("TTS_RATE=fast 未报错")
+		}
+	})
+	t.Run("bad json", func(t *testing.T) {
+		path := writeConfigFile(t, `{"broker": `)
+		if _, err := resolveConfig(defaultConfig(), path, nil); err == nil {
+			t.Error("无效的配置文件未报错")
+		}
+	})
+	t.Run("invalid after merge", func(t *testing.T) {
+		path := writeConfigFile(t, `{"qos": 1}`)
+		t.Setenv("TTS_QOS", "3")
+		if _, err := resolveConfig(defaultConfig(), path, nil); err == nil {
+			t.Error("合并后 qos 为 3 未报错")
+		}
+	})
+}
+
+func TestClampInt(t *testing.T) {
+	tests := []struct{ v, lo, hi, want int }{
+		{-11, -10, 10, -10},
+		{-10, -10, 10, -10},
+		{0, -10, 10, 0},
+		{10, -10, 10, 10},
+		{11, -10, 10, 10},
+		{-1, 0, 100, 0},
+		{0, 0, 100, 0},
+		{100, 0, 100, 100},
+		{101, 0, 100, 100},
+	}
+	for _, tt := range tests {
+		if got := clampInt(tt.v, tt.lo, tt.hi); got != tt.want {
+			t.Errorf("clampInt(%d, %d, %d) = %d，期望 %d", tt.v, tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+// 消息中的 rate、volume 覆盖配置的默认值（0 也是有效值），超出范围时截断
+func TestNewSpeakRequestRateVolume(t *testing.T) {
+	intp := func(n int) *int { return &n }
+	cfg := defaultConfig()
+	cfg.Rate = 3
+	cfg.Volume = 80
+	tests := []struct {
+		name         string
+		rate, volume *int
+		wantRate     int
+		wantVolume   int
+	}{
+		{"defaults", nil, nil, 3, 80},
+		{"explicit zero", intp(0), intp(0), 0, 0},
+		{"lower bounds", intp(-10), intp(0), -10, 0},
+		{"upper bounds", intp(10), intp(100), 10, 100},
+		{"below range", intp(-11), intp(-1), -10, 0},
+		{"above range", intp(11), intp(101), 10, 100},
+		{"far out of range", intp(-1000), intp(1000), -10, 100},
+	}
+	for _, tt := range tests {
+		req, err := newSpeakRequest(cfg, ttsPayload{Text: "你好", Rate: tt.rate, Volume: tt.volume})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if req.Opts.Rate != tt.wantRate || req.Opts.Volume != tt.wantVolume {
+			t.Errorf("%s: rate=%d volume=%d，期望 %d %d", tt.name, req.Opts.Rate, req.Opts.Volume, tt.wantRate, tt.wantVolume)
+		}
+	}
+
+	// 配置的默认值超出范围时同样截断
+	cfg.Rate, cfg.Volume = 20, 150
+	req, err := newSpeakRequest(cfg, ttsPayload{Text: "你好"})
+	if err != nil || req.Opts.Rate != 10 || req.Opts.Volume != 100 {
+		t.Errorf("超出范围的默认值: rate=%d volume=%d err=%v，期望 10 100", req.Opts.Rate, req.Opts.Volume, err)
+	}
+}
+
+func TestParsePayload(t *testing.T) {
+	intp := func(n int) *int { return &n }
+	tests := []struct {
+		name    string
+		in      string
+		want    ttsPayload
+		wantErr bool
+	}{
+		{"plain text", "门铃响了", ttsPayload{Text: "门铃响了"}, false},
+		{"plain text keeps spaces", "  你好  ", ttsPayload{Text: "  你好  "}, false},
+		{"json array is plain text", `["a","b"]`, ttsPayload{Text: `["a","b"]`}, false},
+		{"empty", "", ttsPayload{}, false},
+		{"json text", `{"text":"你好","rate":2,"volume":0,"priority":3}`, ttsPayload{Text: "你好", Rate: intp(2), Volume: intp(0), Priority: 3}, false},
+		{"leading whitespace", " \n{\"text\":\"你好\"}", ttsPayload{Text: "你好"}, false},
+		{"message as text", `{"message":"CPU 过热","severity":"critical"}`, ttsPayload{Text: "CPU 过热", Message: "CPU 过热", Severity: "critical"}, false},
+		{"text wins over message", `{"text":"你好","message":"再见"}`, ttsPayload{Text: "你好", Message: "再见"}, false},
+		{"texts", `{"texts":["一","二"]}`, ttsPayload{Texts: []string{"一", "二"}}, false},
+		{"file", `{"file":"notice.txt"}`, ttsPayload{File: "notice.txt"}, false},
+		{"base64 text", `{"text":"5L2g5aW9","encoding":"base64"}`, ttsPayload{Text: "你好", Encoding: "base64"}, false},
+		{"missing text", `{"voice":"Microsoft Zira Desktop"}`, ttsPayload{}, true},
+		{"empty text", `{"text":""}`, ttsPayload{}, true},
+		{"malformed", `{"text":"你好"`, ttsPayload{}, true},
+		{"wrong type", `{"text":"你好","rate":"fast"}`, ttsPayload{}, true},
+		{"text not a string", `{"text":42}`, ttsPayload{}, true},
+		{"bad base64", `{"text":"***","encoding":"base64"}`, ttsPayload{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePayload([]byte(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePayload(%q) = %+v，期望报错", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePayload(%q): %v", tt.in, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePayload(%q) = %+v，期望 %+v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+// JSON 格式错误和缺少文本字段都归为 errBadPayload，错误信息指出原因
+func TestParsePayloadErrors(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string // 错误信息应包含的内容
+	}{
+		{`{"voice":"x"}`, "缺少 text、texts 或 file 字段"},
+		{`{"text":"你好","rate":"fast"}`, "rate"},
+		{`{"text":`, "unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		_, err := parsePayload([]byte(tt.in))
+		if !errors.Is(err, errBadPayload) {
+			t.Errorf("parsePayload(%q) 返回 %v，期望 errBadPayload", tt.in, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parsePayload(%q) 的错误 %q 不含 %q", tt.in, err, tt.want)
+		}
+	}
+	if _, err := parsePayload([]byte(`{"text":"***","encoding":"base64"}`)); !errors.Is(err, errDecode) {
+		t.Errorf("无效的 base64 文本返回 %v，期望 errDecode", err)
+	}
+}
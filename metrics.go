@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// speakDurationBuckets 是朗读耗时直方图的上界（秒）
+var speakDurationBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60}
+
+// bridgeMetrics 汇总 /metrics 导出的计数器，按 Prometheus 文本格式输出，
+// 不引入 client_golang 依赖
+type bridgeMetrics struct {
+	messagesReceived atomic.Uint64
+	spoken           atomic.Uint64
+	failures         atomic.Uint64
+	timeouts         atomic.Uint64
+	dropped          atomic.Uint64 // 未朗读即被丢弃的请求，原因见 dropEvent.Reason
+
+	mu            sync.Mutex
+	bucketCounts  []uint64 // 与 speakDurationBuckets 对应的累计计数
+	durationSum   float64
+	durationCount uint64
+}
+
+// metrics 是进程内唯一的指标集合，由各入口与 worker 更新
+var metrics = &bridgeMetrics{bucketCounts: make([]uint64, len(speakDurationBuckets))}
+
+// observeSpeak 记录一次朗读的结果与耗时，被打断或取消的朗读只计入耗时
+func (m *bridgeMetrics) observeSpeak(err error, elapsed time.Duration) {
+	switch {
+	case err == nil:
+		m.spoken.Add(1)
+	case errors.Is(err, ErrSpeakTimeout):
+		m.timeouts.Add(1)
+		m.failures.Add(1)
+	case errors.Is(err, context.Canceled), errors.Is(err, errPreempted), errors.Is(err, errStopped):
+	default:
+		m.failures.Add(1)
+	}
+
+	sec := elapsed.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, le := range speakDurationBuckets {
+		if sec <= le {
+			m.bucketCounts[i]++
+		}
+	}
+	m.durationSum += sec
+	m.durationCount++
+}
+
+// writeTo 按 Prometheus 文本格式输出指标，queueDepth 为当前排队数量，
+// consecutiveFailures 为当前连续失败次数
+func (m *bridgeMetrics) writeTo(w io.Writer, queueDepth, consecutiveFailures int) {
+	counter := func(name, help string, v uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("tts_messages_received_total", "Messages received from MQTT and HTTP.", m.messagesReceived.Load())
+	counter("tts_utterances_spoken_total", "Utterances spoken successfully.", m.spoken.Load())
+	counter("tts_failures_total", "Utterances that failed, including timeouts.", m.failures.Load())
+	counter("tts_timeouts_total", "Utterances killed by the TTS timeout.", m.timeouts.Load())
+	counter("tts_dropped_total", "Requests dropped without being spoken (queue full, stale, rate limit, mute, quiet hours, gate, blocklist or missing voice).", m.dropped.Load())
+	fmt.Fprintf(w, "# HELP tts_queue_depth Requests waiting in the speak queue.\n# TYPE tts_queue_depth gauge\ntts_queue_depth %d\n", queueDepth)
+	fmt.Fprintf(w, "# HELP tts_consecutive_failures Consecutive failed utterances since the last success.\n# TYPE tts_consecutive_failures gauge\ntts_consecutive_failures %d\n", consecutiveFailures)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	const h = "tts_speak_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time spent speaking each utterance.\n# TYPE %s histogram\n", h, h)
+	for i, le := range speakDurationBuckets {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", h, strconv.FormatFloat(le, 'g', -1, 64), m.bucketCounts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h, m.durationCount)
+	fmt.Fprintf(w, "%s_sum %g\n%s_count %d\n", h, m.durationSum, h, m.durationCount)
+}
+
+// statsSnapshot 是某一时刻的累计计数，两次快照相减即为其间的增量
+type statsSnapshot struct {
+	received, spoken, failures, dropped uint64
+	durationSum                         float64
+	durationCount                       uint64
+}
+
+func (m *bridgeMetrics) snapshot() statsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return statsSnapshot{
+		received:      m.messagesReceived.Load(),
+		spoken:        m.spoken.Load(),
+		failures:      m.failures.Load(),
+		dropped:       m.dropped.Load(),
+		durationSum:   m.durationSum,
+		durationCount: m.durationCount,
+	}
+}
+
+// logStats 每隔 interval 记录一行自上次记录以来的统计，供不使用 Prometheus 的用户查看日志
+func (m *bridgeMetrics) logStats(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	prev := m.snapshot()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		cur := m.snapshot()
+		avg := time.Duration(0)
+		if n := cur.durationCount - prev.durationCount; n > 0 {
+			avg = time.Duration((cur.durationSum - prev.durationSum) / float64(n) * float64(time.Second))
+		}
+		log.Printf("📊 最近 %v: 收到 %d，朗读 %d，失败 %d，丢弃 %d，平均耗时 %v",
+			interval, cur.received-prev.received, cur.spoken-prev.spoken, cur.failures-prev.failures,
+			cur.dropped-prev.dropped, avg.Round(time.Millisecond))
+		prev = cur
+	}
+}
+
+// metricsHandler 返回 GET /metrics 的处理函数
+func metricsHandler(b *bridge) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, failures := b.queue.failures.Status()
+		metrics.writeTo(w, b.queue.Len(), failures)
+	}
+}
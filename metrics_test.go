@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// 每种丢弃原因都计入 tts_dropped_total，与 DropTopic 上的丢弃事件一致；
+// 命中屏蔽规则在 enqueue 之前处理，见 TestDropBlockedCountsMessage
+func TestDroppedCountsEveryReason(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		setup   func(cfg *Config)
+		prepare func(b *bridge)
+		err     error
+		reason  string
+	}{
+		{"muted", nil, func(b *bridge) { b.queue.Mute(time.Hour) }, errMuted, dropMuted},
+		{"quiet hours", func(cfg *Config) {
+			cfg.QuietHours = now.Add(-time.Hour).Format("15:04") + "-" + now.Add(time.Hour).Format("15:04")
+		}, nil, errQuiet, dropQuietHours},
+		{"gated", func(cfg *Config) { cfg.GateTopic, cfg.GateMatchValue = "home/presence", "home" }, nil, errGated, dropGated},
+		{"rate limit", func(cfg *Config) { cfg.RateLimitPerMinute = 1 }, func(b *bridge) {
+			b.limit.Configure(1, 1)
+			b.limit.Allow(time.Now())
+		}, errThrottled, dropRateLimit},
+		{"queue full", func(cfg *Config) { cfg.QueueSize = 1 }, func(b *bridge) {
+			b.queue.Enqueue(speakRequest{Text: "排在前面"})
+		}, errQueueFull, dropQueueFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			if tt.setup != nil {
+				tt.setup(cfg)
+			}
+			b := newTestBridge(cfg)
+			if tt.prepare != nil {
+				tt.prepare(b)
+			}
+			var reasons []string
+			b.queue.onDrop = func(req speakRequest, reason string) { reasons = append(reasons, reason) }
+
+			before := metrics.dropped.Load()
+			err := b.enqueue(speakRequest{Text: "门铃响了。"}, speakRequest{Text: "请开门。"})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("enqueue 返回 %v，期望 %v", err, tt.err)
+			}
+			// 两段文本各计一次
+			if got := metrics.dropped.Load() - before; got != 2 || len(reasons) != 2 {
+				t.Errorf("dropped 增加 %d，丢弃事件 %d 条，期望各 2", got, len(reasons))
+			}
+			for _, r := range reasons {
+				if r != tt.reason {
+					t.Errorf("丢弃原因 %q，期望 %q", r, tt.reason)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/packets"
+	"github.com/eclipse/paho.golang/paho"
+	"github.com/eclipse/paho.golang/paho/session"
+	"github.com/eclipse/paho.golang/paho/session/state"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// paho.mqtt.golang 只支持 MQTT 3.1 / 3.1.1，protocol_version 为 5 时改用 eclipse/paho.golang。
+// v5Client 把它包装成 mqtt.Client，重连、订阅、回执等代码不必区分协议版本；
+// 收到的消息为 *v5Message，可读取 user properties（见 applyUserProperties）
+
+// v5Client 用 paho.golang 实现 mqtt.Client，选项取自 paho.mqtt.golang 的 ClientOptions。
+// 与 SetAutoReconnect(false) 时的 paho 客户端一样，断线后不自动重连，由调用方再次 Connect
+type v5Client struct {
+	opts *mqtt.ClientOptions
+	// session 在各次连接间共用，非 clean session 时重连后可继续未确认的 QoS 1/2 消息
+	session session.SessionManager
+
+	mu     sync.Mutex
+	cli    *paho.Client // 当前连接，未连接时为 nil
+	routes []v5Route
+}
+
+// v5Route 是订阅的主题过滤器及其回调
+type v5Route struct {
+	filter  string
+	handler mqtt.MessageHandler
+}
+
+// newV5Client 按 opts 创建 MQTT 5 客户端，签名与 mqtt.NewClient 相同
+func newV5Client(opts *mqtt.ClientOptions) mqtt.Client {
+	return &v5Client{opts: opts, session: state.NewInMemory()}
+}
+
+// current 返回当前连接，未连接时为 nil
+func (c *v5Client) current() *paho.Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cli
+}
+
+func (c *v5Client) IsConnected() bool      { return c.current() != nil }
+func (c *v5Client) IsConnectionOpen() bool { return c.current() != nil }
+
+// Connect 按顺序尝试各个 broker，成功后调用 OnConnect，全部失败时 token 带最后一个错误
+func (c *v5Client) Connect() mqtt.Token {
+	t := newV5Token()
+	go func() { t.complete(c.connect()) }()
+	return t
+}
+
+func (c *v5Client) connect() error {
+	if c.IsConnected() {
+		return nil
+	}
+	err := fmt.Errorf("没有可连接的 broker")
+	for _, u := range c.opts.Servers {
+		tlsCfg := c.opts.TLSConfig
+		if c.opts.OnConnectAttempt != nil {
+			tlsCfg = c.opts.OnConnectAttempt(u, tlsCfg)
+		}
+		var cli *paho.Client
+		if cli, err = c.dial(u, tlsCfg); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		c.cli = cli
+		c.mu.Unlock()
+		// 连接在设置 c.cli 之前就断开时，断线回调已被忽略，在这里补上
+		select {
+		case <-cli.Done():
+			c.lost(cli, fmt.Errorf("连接已断开"))
+		default:
+		}
+		if c.opts.OnConnect != nil {
+			go c.opts.OnConnect(c)
+		}
+		return nil
+	}
+	return err
+}
+
+// dial 连接 u 并完成 MQTT 5 握手，ConnectTimeout > 0 时限制整个过程的耗时
+func (c *v5Client) dial(u *url.URL, tlsCfg *tls.Config) (*paho.Client, error) {
+	ctx := context.Background()
+	if c.opts.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.ConnectTimeout)
+		defer cancel()
+	}
+	conn, err := dialBroker(ctx, u, tlsCfg, c.opts)
+	if err != nil {
+		return nil, err
+	}
+	var cli *paho.Client
+	cli = paho.NewClient(paho.ClientConfig{
+		ClientID:                   c.opts.ClientID,
+		Conn:                       packets.NewThreadSafeConn(conn),
+		Session:                    c.session,
+		OnPublishReceived:          []func(paho.PublishReceived) (bool, error){c.route},
+		OnClientError:              func(err error) { c.lost(cli, err) },
+		OnServerDisconnect:         func(d *paho.Disconnect) { c.lost(cli, disconnectError(d)) },
+		EnableManualAcknowledgment: c.opts.AutoAckDisabled,
+	})
+	cp := &paho.Connect{
+		ClientID:     c.opts.ClientID,
+		KeepAlive:    uint16(c.opts.KeepAlive),
+		CleanStart:   c.opts.CleanSession,
+		Username:     c.opts.Username,
+		UsernameFlag: c.opts.Username != "",
+		Password:     []byte(c.opts.Password),
+		PasswordFlag: c.opts.Password != "",
+	}
+	if !c.opts.CleanSession {
+		// MQTT 5 的会话默认在断开时结束，持久会话需要显式的过期时间，这里与 3.1.1 一样永不过期
+		expiry := uint32(math.MaxUint32)
+		cp.Properties = &paho.ConnectProperties{SessionExpiryInterval: &expiry}
+	}
+	if c.opts.WillEnabled {
+		cp.WillMessage = &paho.WillMessage{
+			Topic:   c.opts.WillTopic,
+			Payload: c.opts.WillPayload,
+			QoS:     c.opts.WillQos,
+			Retain:  c.opts.WillRetained,
+		}
+	}
+	if _, err := cli.Connect(ctx, cp); err != nil {
+		return nil, err
+	}
+	return cli, nil
+}
+
+// dialBroker 按 u 的协议建立 TCP、TLS 或 WebSocket 连接（协议已由 validateBroker 规范化）
+func dialBroker(ctx context.Context, u *url.URL, tlsCfg *tls.Config, opts *mqtt.ClientOptions) (net.Conn, error) {
+	dialer := opts.Dialer
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	switch u.Scheme {
+	case "ws", "wss":
+		if u.Scheme == "ws" {
+			tlsCfg = nil
+		}
+		return mqtt.NewWebsocket(u.String(), tlsCfg, opts.ConnectTimeout, opts.HTTPHeaders, opts.WebsocketOptions)
+	}
+	if isTLSBroker(u.String()) {
+		d := tls.Dialer{NetDialer: dialer, Config: tlsCfg}
+		return d.DialContext(ctx, "tcp", u.Host)
+	}
+	return dialer.DialContext(ctx, "tcp", u.Host)
+}
+
+// disconnectError 把 broker 发来的 DISCONNECT 转为断线原因
+func disconnectError(d *paho.Disconnect) error {
+	if d.Properties != nil && d.Properties.ReasonString != "" {
+		return fmt.Errorf("broker 断开连接（原因码 0x%02x）: %s", d.ReasonCode, d.Properties.ReasonString)
+	}
+	return fmt.Errorf("broker 断开连接（原因码 0x%02x）", d.ReasonCode)
+}
+
+// lost 在 cli 仍是当前连接时标记为已断开并调用 OnConnectionLost；
+// 已被 Disconnect 或新连接取代的连接不再回调
+func (c *v5Client) lost(cli *paho.Client, err error) {
+	c.mu.Lock()
+	current := c.cli == cli
+	if current {
+		c.cli = nil
+	}
+	c.mu.Unlock()
+	if current && c.opts.OnConnectionLost != nil {
+		c.opts.OnConnectionLost(c, err)
+	}
+}
+
+// Disconnect 发送 DISCONNECT 并关闭连接。发布都在写入连接后才返回，不需要等待 quiesce
+func (c *v5Client) Disconnect(quiesce uint) {
+	c.mu.Lock()
+	cli := c.cli
+	c.cli = nil
+	c.mu.Unlock()
+	if cli != nil {
+		cli.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	}
+}
+
+// Publish 把消息写入连接后即完成，不等待 QoS 1/2 的确认，
+// 因此多次发布按调用顺序到达 broker（如先设置、后清除的保留消息）
+func (c *v5Client) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	t := newV5Token()
+	var body []byte
+	switch p := payload.(type) {
+	case string:
+		body = []byte(p)
+	case []byte:
+		body = p
+	default:
+		t.complete(fmt.Errorf("不支持的消息体类型 %T", payload))
+		return t
+	}
+	cli := c.current()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	_, err := cli.PublishWithOptions(context.Background(), &paho.Publish{
+		Topic:   topic,
+		QoS:     qos,
+		Retain:  retained,
+		Payload: body,
+	}, paho.PublishOptions{Method: paho.PublishMethod_AsyncSend})
+	t.complete(err)
+	return t
+}
+
+func (c *v5Client) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	return c.SubscribeMultiple(map[string]byte{topic: qos}, callback)
+}
+
+func (c *v5Client) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	t := newV5Token()
+	cli := c.current()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	sub := &paho.Subscribe{}
+	for topic, qos := range filters {
+		if callback != nil {
+			c.AddRoute(topic, callback)
+		}
+		sub.Subscriptions = append(sub.Subscriptions, paho.SubscribeOptions{Topic: topic, QoS: qos})
+	}
+	go func() {
+		_, err := cli.Subscribe(context.Background(), sub)
+		t.complete(err)
+	}()
+	return t
+}
+
+func (c *v5Client) Unsubscribe(topics ...string) mqtt.Token {
+	t := newV5Token()
+	c.mu.Lock()
+	c.routes = slices.DeleteFunc(c.routes, func(r v5Route) bool { return slices.Contains(topics, r.filter) })
+	cli := c.cli
+	c.mu.Unlock()
+	if cli == nil {
+		t.complete(mqtt.ErrNotConnected)
+		return t
+	}
+	go func() {
+		_, err := cli.Unsubscribe(context.Background(), &paho.Unsubscribe{Topics: topics})
+		t.complete(err)
+	}()
+	return t
+}
+
+// AddRoute 为 topic 设置回调，已有的同名过滤器被替换
+func (c *v5Client) AddRoute(topic string, callback mqtt.MessageHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, r := range c.routes {
+		if r.filter == topic {
+			c.routes[i].handler = callback
+			return
+		}
+	}
+	c.routes = append(c.routes, v5Route{filter: topic, handler: callback})
+}
+
+func (c *v5Client) OptionsReader() mqtt.ClientOptionsReader {
+	return mqtt.NewOptionsReader(c.opts)
+}
+
+// route 把收到的消息依次交给所有匹配的回调，与 paho 默认的按序处理一致
+func (c *v5Client) route(pr paho.PublishReceived) (bool, error) {
+	msg := &v5Message{pub: pr.Packet, cli: pr.Client, manualAck: c.opts.AutoAckDisabled}
+	c.mu.Lock()
+	routes := slices.Clone(c.routes)
+	c.mu.Unlock()
+	handled := false
+	for _, r := range routes {
+		if matchTopic(sharedSubscriptionFilter(r.filter), msg.Topic()) {
+			r.handler(c, msg)
+			handled = true
+		}
+	}
+	return handled, nil
+}
+
+// sharedSubscriptionFilter 去掉共享订阅 $share/<group>/ 前缀，返回实际匹配的过滤器
+func sharedSubscriptionFilter(filter string) string {
+	if rest, ok := strings.CutPrefix(filter, "$share/"); ok {
+		if _, f, ok := strings.Cut(rest, "/"); ok {
+			return f
+		}
+	}
+	return filter
+}
+
+// v5Message 是 MQTT 5 消息，实现 mqtt.Message 并可读取 user properties
+type v5Message struct {
+	pub       *paho.Publish
+	cli       *paho.Client
+	manualAck bool
+	once      sync.Once
+}
+
+func (m *v5Message) Duplicate() bool   { return m.pub.Duplicate() }
+func (m *v5Message) Qos() byte         { return m.pub.QoS }
+func (m *v5Message) Retained() bool    { return m.pub.Retain }
+func (m *v5Message) Topic() string     { return m.pub.Topic }
+func (m *v5Message) MessageID() uint16 { return m.pub.PacketID }
+func (m *v5Message) Payload() []byte   { return m.pub.Payload }
+
+// Ack 在关闭自动确认时确认消息，自动确认模式下由 paho 确认，这里什么也不做
+func (m *v5Message) Ack() {
+	if m.manualAck {
+		m.once.Do(func() {
+			if err := m.cli.Ack(m.pub); err != nil {
+				log.Printf("⚠️ 确认消息失败 [主题: %s]: %v", m.pub.Topic, err)
+			}
+		})
+	}
+}
+
+// UserProperty 返回名为 key 的第一个 user property，不存在时为 ""
+func (m *v5Message) UserProperty(key string) string {
+	if m.pub.Properties == nil {
+		return ""
+	}
+	return m.pub.Properties.User.Get(key)
+}
+
+// userProperty 返回 MQTT 5 消息名为 key 的 user property；3.1.1 的消息没有属性，总是返回 ""
+func userProperty(msg mqtt.Message, key string) string {
+	if m, ok := msg.(interface{ UserProperty(string) string }); ok {
+		return m.UserProperty(key)
+	}
+	return ""
+}
+
+// applyUserProperties 用 MQTT 5 消息的 user properties voice、rate、priority 覆盖 p 中的同名字段，
+// 发布方可以只发送纯文本、把朗读参数放在属性中；属性缺失时沿用消息体 JSON 中的值。
+// 无法解析为整数的 rate、priority 记录警告后忽略
+func applyUserProperties(msg mqtt.Message, p ttsPayload) ttsPayload {
+	if v := userProperty(msg, "voice"); v != "" {
+		p.Voice = v
+	}
+	if v := userProperty(msg, "rate"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			p.Rate = &n
+		} else {
+			log.Printf("⚠️ [主题: %s] user property rate=%q 不是整数，已忽略", msg.Topic(), v)
+		}
+	}
+	if v := userProperty(msg, "priority"); v != "" {
+		if n, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			p.Priority = n
+		} else {
+			log.Printf("⚠️ [主题: %s] user property priority=%q 不是整数，已忽略", msg.Topic(), v)
+		}
+	}
+	return p
+}
+
+// v5Token 实现 mqtt.Token，complete 后 Done 关闭、Error 返回操作结果
+type v5Token struct {
+	done chan struct{}
+	err  error
+}
+
+func newV5Token() *v5Token {
+	return &v5Token{done: make(chan struct{})}
+}
+
+func (t *v5Token) complete(err error) {
+	t.err = err
+	close(t.done)
+}
+
+func (t *v5Token) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *v5Token) WaitTimeout(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-t.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+func (t *v5Token) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *v5Token) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// fakeMessage 是 MQTT 3.1.1 的消息，没有 user properties
+type fakeMessage struct {
+	topic    string
+	payload  []byte
+	retained bool
+	acked    bool
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 1 }
+func (m *fakeMessage) Retained() bool    { return m.retained }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 1 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              { m.acked = true }
+
+// newV5Message 返回带 user properties 的 MQTT 5 消息，props 按 key, value 成对给出
+func newV5Message(topic string, props ...string) *v5Message {
+	pub := &paho.Publish{Topic: topic, Properties: &paho.PublishProperties{}}
+	for i := 0; i+1 < len(props); i += 2 {
+		pub.Properties.User.Add(props[i], props[i+1])
+	}
+	return &v5Message{pub: pub}
+}
+
+func TestApplyUserProperties(t *testing.T) {
+	rate := 2
+	fromJSON := ttsPayload{Text: "你好", Voice: "Microsoft Zira Desktop", Rate: &rate, Priority: 1}
+	tests := []struct {
+		name     string
+		msg      mqtt.Message
+		voice    string
+		rate     int
+		priority int
+	}{
+		{"mqtt 3.1.1", &fakeMessage{topic: "tts"}, "Microsoft Zira Desktop", 2, 1},
+		{"no properties", newV5Message("tts"), "Microsoft Zira Desktop", 2, 1},
+		{"all properties", newV5Message("tts", "voice", "Microsoft Huihui Desktop", "rate", "-3", "priority", "5"), "Microsoft Huihui Desktop", -3, 5},
+		{"rate only", newV5Message("tts", "rate", " 4 "), "Microsoft Zira Desktop", 4, 1},
+		{"invalid numbers", newV5Message("tts", "rate", "fast", "priority", "high"), "Microsoft Zira Desktop", 2, 1},
+		{"unknown property", newV5Message("tts", "volume", "10"), "Microsoft Zira Desktop", 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := applyUserProperties(tt.msg, fromJSON)
+			if p.Voice != tt.voice || p.Rate == nil || *p.Rate != tt.rate || p.Priority != tt.priority {
+				t.Errorf("得到 voice=%q rate=%v priority=%d，期望 %q %d %d", p.Voice, p.Rate, p.Priority, tt.voice, tt.rate, tt.priority)
+			}
+			if p.Text != "你好" {
+				t.Errorf("text 被修改为 %q", p.Text)
+			}
+		})
+	}
+	if *fromJSON.Rate != 2 {
+		t.Errorf("applyUserProperties 修改了调用方的 rate")
+	}
+}
+
+func TestSharedSubscriptionFilter(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"home/tts", "home/tts"},
+		{"$share/speakers/home/+/tts", "home/+/tts"},
+		{"$share/speakers", "$share/speakers"},
+		{"$SYS/#", "$SYS/#"},
+	}
+	for _, tt := range tests {
+		if got := sharedSubscriptionFilter(tt.in); got != tt.want {
+			t.Errorf("sharedSubscriptionFilter(%q) = %q，期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// publishV5 以 MQTT 5 客户端连接 broker，向 topic 发布带 user properties 的 QoS 1 消息
+func publishV5(t *testing.T, b *testBroker, topic, payload string, props ...string) {
+	t.Helper()
+	u, err := url.Parse(b.tcp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := paho.NewClient(paho.ClientConfig{Conn: conn})
+	ctx := context.Background()
+	if _, err := cli.Connect(ctx, &paho.Connect{ClientID: "tts-test-publisher", KeepAlive: 30, CleanStart: true}); err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Disconnect(&paho.Disconnect{})
+	pub := &paho.Publish{Topic: topic, QoS: 1, Payload: []byte(payload), Properties: &paho.PublishProperties{}}
+	for i := 0; i+1 < len(props); i += 2 {
+		pub.Properties.User.Add(props[i], props[i+1])
+	}
+	if _, err := cli.Publish(ctx, pub); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBridgeReadsUserProperties(t *testing.T) {
+	broker := startBroker(t)
+	speaker := newRecordingSpeaker()
+	cfg := testConfig(t, broker.tcp)
+	cfg.ProtocolVersion = 5
+	startBridge(t, broker, cfg, speaker)
+
+	// 纯文本消息体，朗读参数全部放在 user properties 中
+	publishV5(t, broker, "test/tts", "门铃响了", "voice", "Microsoft Huihui Desktop", "rate", "3")
+	got := speaker.next(t)
+	want := spokenCall{Text: "门铃响了", Opts: speakOptions{Voice: "Microsoft Huihui Desktop", Rate: 3, Volume: 100}}
+	if got != want {
+		t.Errorf("朗读 %+v，期望 %+v", got, want)
+	}
+
+	// 没有 user properties 时使用消息体 JSON 中的参数
+	publishV5(t, broker, "test/tts", `{"text":"洗衣机已完成","rate":-2,"volume":60}`)
+	got = speaker.next(t)
+	want = spokenCall{Text: "洗衣机已完成", Opts: speakOptions{Rate: -2, Volume: 60}}
+	if got != want {
+		t.Errorf("朗读 %+v，期望 %+v", got, want)
+	}
+
+	// 属性优先于消息体中的同名字段，其余字段不受影响
+	publishV5(t, broker, "test/tts", `{"text":"有人按门铃","rate":-2,"volume":60}`, "rate", "5")
+	got = speaker.next(t)
+	want = spokenCall{Text: "有人按门铃", Opts: speakOptions{Rate: 5, Volume: 60}}
+	if got != want {
+		t.Errorf("朗读 %+v，期望 %+v", got, want)
+	}
+}
+
+// MQTT 5 的命令回执与 3.1.1 一样发布到状态主题
+func TestBridgeV5PublishesReplies(t *testing.T) {
+	broker := startBroker(t)
+	cfg := testConfig(t, broker.tcp)
+	cfg.ProtocolVersion = 5
+	cfg.StatusTopic = "test/tts/status"
+	cfg.CommandTopic = "test/tts/cmd"
+	status := broker.subscribe(t, cfg.StatusTopic, 1)
+	startBridge(t, broker, cfg, newRecordingSpeaker())
+
+	broker.publish(t, cfg.CommandTopic, `{"cmd":"status"}`)
+	select {
+	case pk := <-status:
+		if len(pk.Payload) == 0 {
+			t.Error("状态回执为空")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待状态回执超时")
+	}
+}
+
+// broker 重启后 MQTT 5 客户端同样按退避重连、重新订阅
+func TestBridgeV5Reconnects(t *testing.T) {
+	addr := freeAddr(t)
+	broker := startBrokerAt(t, addr)
+	speaker := newRecordingSpeaker()
+	cfg := testConfig(t, broker.tcp)
+	cfg.ProtocolVersion = 5
+	startBridge(t, broker, cfg, speaker)
+
+	broker.stop()
+	broker = startBrokerAt(t, addr)
+	online := broker.subscribe(t, cfg.AvailabilityTopic, 1)
+	select {
+	case <-online:
+	case <-time.After(10 * time.Second):
+		t.Fatal("broker 重启后桥接器未能重新连接")
+	}
+	publishV5(t, broker, "test/tts", "已重新连接", "rate", "1")
+	if got := speaker.next(t); got.Text != "已重新连接" || got.Opts.Rate != 1 {
+		t.Errorf("朗读 %+v", got)
+	}
+}
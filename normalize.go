@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 文本规范化模式
+const (
+	normalizeStrip    = "strip"    // 删除 emoji
+	normalizeDescribe = "describe" // 常见 emoji 替换为文字描述，其余删除
+)
+
+// emojiDescriptions 是 describe 模式下常见 emoji 的朗读文字
+var emojiDescriptions = map[rune]string{
+	'✅': "完成",
+	'❌': "错误",
+	'⚠': "警告",
+	'❗': "注意",
+	'🔥': "火",
+	'👍': "赞",
+	'❤': "爱心",
+	'🎉': "庆祝",
+	'🔔': "铃声",
+	'🚪': "门",
+	'🌧': "下雨",
+	'☀': "晴天",
+	'⏰': "闹钟",
+	'🔋': "电池",
+	'💡': "灯",
+	'🚨': "警报",
+}
+
+// isEmoji 判断 r 是否属于 emoji 及其组合用字符（变体选择符、零宽连接符、肤色修饰等）
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF: // 表情、符号、旗帜、肤色修饰
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // 杂项符号、装饰符号
+		return true
+	case r >= 0x2300 && r <= 0x23FF: // ⌚ ⏰ 等技术符号
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // ⭐ ⬛ 等
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // 旗帜标签
+		return true
+	case r == 0xFE0F || r == 0xFE0E || r == 0x200D || r == 0x20E3:
+		return true
+	}
+	return false
+}
+
+// normalizeText 按 mode 处理 emoji，删除控制字符，并将连续空白折叠为一个空格。
+// 按 rune 处理，不会截断多字节字符。mode 为空时等同于 strip。
+func normalizeText(s, mode string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	pendingSpace := false
+	write := func(str string) {
+		if pendingSpace && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		pendingSpace = false
+		b.WriteString(str)
+	}
+
+	for _, r := range s {
+		switch {
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		case unicode.IsControl(r):
+			// 删除
+		case isEmoji(r):
+			if mode == normalizeDescribe {
+				if desc, ok := emojiDescriptions[r]; ok {
+					pendingSpace = true
+					write(desc)
+					pendingSpace = true
+				}
+			}
+		default:
+			write(string(r))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		strip    string
+		describe string
+	}{
+		{"plain", "门铃响了", "门铃响了", "门铃响了"},
+		{"emoji between cjk", "门铃🔔响了", "门铃响了", "门铃 铃声 响了"},
+		{"unknown emoji", "晚安🌙", "晚安", "晚安"},
+		{"variation selector", "⚠️高温", "高温", "警告 高温"},
+		{"text presentation selector", "☀︎晴", "晴", "晴天 晴"},
+		{"skin tone", "👍🏽好的", "好的", "赞 好的"},
+		{"zwj family", "👨‍👩‍👧回家了", "回家了", "回家了"},
+		{"zwj with known parts", "❤️‍🔥加油", "加油", "爱心 火 加油"},
+		{"keycap", "按1️⃣确认", "按1确认", "按1确认"},
+		{"flag", "🇨🇳国庆", "国庆", "国庆"},
+		{"only emoji", "🎉🎉", "", "庆祝 庆祝"},
+		{"collapse spaces", "你好   世界", "你好 世界", "你好 世界"},
+		{"tabs and newlines", "第一行\n\t第二行", "第一行 第二行", "第一行 第二行"},
+		{"ideographic space", "温度　　25度", "温度 25度", "温度 25度"},
+		{"trim", "  前后  ", "前后", "前后"},
+		{"space around removed emoji", "门 🚪 开了", "门 开了", "门 门 开了"},
+		{"control characters", "a\x00b\x07c\x1b", "abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeText(tt.in, normalizeStrip); got != tt.strip {
+				t.Errorf("strip: normalizeText(%q) = %q，期望 %q", tt.in, got, tt.strip)
+			}
+			if got := normalizeText(tt.in, ""); got != tt.strip {
+				t.Errorf("默认模式: normalizeText(%q) = %q，期望与 strip 相同的 %q", tt.in, got, tt.strip)
+			}
+			if got := normalizeText(tt.in, normalizeDescribe); got != tt.describe {
+				t.Errorf("describe: normalizeText(%q) = %q，期望 %q", tt.in, got, tt.describe)
+			}
+		})
+	}
+}
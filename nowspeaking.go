@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// nowSpeaking 是开始朗读时以保留消息发布到 NowSpeakingTopic 的 JSON，朗读结束后清空
+type nowSpeaking struct {
+	Text     string `json:"text"`
+	Topic    string `json:"topic,omitempty"` // 消息实际到达的主题，HTTP 请求为空
+	Voice    string `json:"voice,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	Started  string `json:"started"` // RFC3339，开始朗读的时间
+}
+
+// nowSpeakingPublisher 返回 speakQueue.onSpeaking 的实现：开始朗读时向 topic 发布保留的
+// nowSpeaking，所有 worker 都读完后发布空的保留消息清除。多个 worker 时显示最近开始的一条
+func nowSpeakingPublisher(client mqtt.Client, topic string) func(req speakRequest, speaking bool) {
+	var mu sync.Mutex
+	active := 0
+	return func(req speakRequest, speaking bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !speaking {
+			if active--; active == 0 {
+				publishMessage(client, topic, true, nil)
+			}
+			return
+		}
+		active++
+		data, err := json.Marshal(nowSpeaking{
+			Text:     req.Text,
+			Topic:    req.Topic,
+			Voice:    req.Opts.Voice,
+			Priority: req.Priority,
+			Started:  time.Now().Format(time.RFC3339),
+		})
+		if err != nil {
+			log.Printf("❌ 正在朗读消息序列化失败: %v", err)
+			return
+		}
+		publishMessage(client, topic, true, data)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 数字朗读的语言
+const (
+	numberLocaleZh = "zh" // 默认：一万两千、2024年1月5日、五千美元
+	numberLocaleEn = "en" // twelve thousand、January 5, 2024、five thousand dollars
+)
+
+var (
+	isoDatePattern = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	// 金额：符号后跟整数（可带千位分隔符）和最多两位小数
+	currencyPattern = regexp.MustCompile(`([$€£¥￥])\s?(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d{1,2}))?\b`)
+	// 带千位分隔符的数字；不带分隔符的长数字多为编号或电话，保持原样
+	groupedPattern = regexp.MustCompile(`\b\d{1,3}(?:,\d{3})+(?:\.\d+)?\b`)
+)
+
+// numberLocale 选择数字朗读的语言：请求的 lang 为中文或英文时优先，否则使用配置的 locale
+func numberLocale(lang, locale string) string {
+	for _, l := range []string{lang, locale} {
+		switch l = strings.ToLower(l); {
+		case strings.HasPrefix(l, "en"):
+			return numberLocaleEn
+		case strings.HasPrefix(l, "zh"):
+			return numberLocaleZh
+		}
+	}
+	return numberLocaleZh
+}
+
+// expandNumbers 将 ISO 日期、金额和带千位分隔符的数字改写为 locale 下的朗读形式，
+// 无法识别或超出范围的内容保持原样
+func expandNumbers(text, locale string) string {
+	en := numberLocale("", locale) == numberLocaleEn
+
+	text = isoDatePattern.ReplaceAllStringFunc(text, func(s string) string {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return s
+		}
+		if en {
+			return t.Format("January 2, 2006")
+		}
+		return t.Format("2006年1月2日")
+	})
+
+	text = currencyPattern.ReplaceAllStringFunc(text, func(s string) string {
+		m := currencyPattern.FindStringSubmatch(s)
+		n, err := strconv.ParseUint(strings.ReplaceAll(m[2], ",", ""), 10, 64)
+		if err != nil {
+			return s
+		}
+		var spoken string
+		var ok bool
+		if en {
+			spoken, ok = englishMoney(m[1], n, m[3])
+		} else {
+			spoken, ok = chineseMoney(m[1], n, m[3])
+		}
+		if !ok {
+			return s
+		}
+		return spoken
+	})
+
+	return groupedPattern.ReplaceAllStringFunc(text, func(s string) string {
+		intPart, frac, _ := strings.Cut(s, ".")
+		n, err := strconv.ParseUint(strings.ReplaceAll(intPart, ",", ""), 10, 64)
+		if err != nil {
+			return s
+		}
+		if en {
+			words, ok := englishNumber(n)
+			if !ok {
+				return s
+			}
+			if frac != "" {
+				words += " point " + englishDigits(frac)
+			}
+			return words
+		}
+		words, ok := chineseNumber(n)
+		if !ok {
+			return s
+		}
+		if frac != "" {
+			words += "点" + chineseDigits(frac)
+		}
+		return words
+	})
+}
+
+var (
+	englishOnes = []string{"zero", "one", "two", "three", "four", "five", "six", "seven", "eight", "nine",
+		"ten", "eleven", "twelve", "thirteen", "fourteen", "fifteen", "sixteen", "seventeen", "eighteen", "nineteen"}
+	englishTens   = []string{"", "", "twenty", "thirty", "forty", "fifty", "sixty", "seventy", "eighty", "ninety"}
+	englishScales = []string{"", "thousand", "million", "billion", "trillion"}
+)
+
+// englishNumber 将 n 读作英文单词（美式，不加 and），支持到 999 trillion
+func englishNumber(n uint64) (string, bool) {
+	if n == 0 {
+		return englishOnes[0], true
+	}
+	var groups []string
+	for scale := 0; n > 0; scale++ {
+		if scale >= len(englishScales) {
+			return "", false
+		}
+		if g := n % 1000; g > 0 {
+			words := englishHundreds(int(g))
+			if englishScales[scale] != "" {
+				words += " " + englishScales[scale]
+			}
+			groups = append([]string{words}, groups...)
+		}
+		n /= 1000
+	}
+	return strings.Join(groups, " "), true
+}
+
+// englishHundreds 读 1-999
+func englishHundreds(n int) string {
+	var parts []string
+	if n >= 100 {
+		parts = append(parts, englishOnes[n/100]+" hundred")
+		n %= 100
+	}
+	switch {
+	case n >= 20:
+		w := englishTens[n/10]
+		if n%10 > 0 {
+			w += "-" + englishOnes[n%10]
+		}
+		parts = append(parts, w)
+	case n > 0:
+		parts = append(parts, englishOnes[n])
+	}
+	return strings.Join(parts, " ")
+}
+
+// englishDigits 逐位读出小数部分，如 05 读作 zero five
+func englishDigits(s string) string {
+	words := make([]string, 0, len(s))
+	for _, c := range s {
+		words = append(words, englishOnes[c-'0'])
+	}
+	return strings.Join(words, " ")
+}
+
+// englishCurrencies 为各货币符号的主、辅单位（单数, 复数）
+var englishCurrencies = map[string][4]string{
+	"$": {"dollar", "dollars", "cent", "cents"},
+	"€": {"euro", "euros", "cent", "cents"},
+	"£": {"pound", "pounds", "penny", "pence"},
+	"¥": {"yuan", "yuan", "fen", "fen"},
+	"￥": {"yuan", "yuan", "fen", "fen"},
+}
+
+// englishMoney 读作如 five dollars and fifty cents；frac 为一到两位小数，可为空
+func englishMoney(symbol string, n uint64, frac string) (string, bool) {
+	units := englishCurrencies[symbol]
+	cents := 0
+	if frac != "" {
+		cents, _ = strconv.Atoi((frac + "0")[:2])
+	}
+	plural := func(v uint64, one, many string) (string, bool) {
+		w, ok := englishNumber(v)
+		if v == 1 {
+			return w + " " + one, ok
+		}
+		return w + " " + many, ok
+	}
+	major, ok := plural(n, units[0], units[1])
+	if !ok {
+		return "", false
+	}
+	if cents == 0 {
+		return major, true
+	}
+	minor, _ := plural(uint64(cents), units[2], units[3])
+	if n == 0 {
+		return minor, true
+	}
+	return major + " and " + minor, true
+}
+
+var (
+	chineseDigitChars = []string{"零", "一", "二", "三", "四", "五", "六", "七", "八", "九"}
+	chineseSmallUnits = []string{"", "十", "百", "千"}
+	chineseBigUnits   = []string{"", "万", "亿", "万亿"}
+)
+
+// chineseNumber 将 n 读作中文数字，如 12000 读作 一万两千、100000001 读作 一亿零一，
+// 支持到 9999 万亿
+func chineseNumber(n uint64) (string, bool) {
+	if n == 0 {
+		return chineseDigitChars[0], true
+	}
+	var sections []int // 从低到高每四位一节
+	for ; n > 0; n /= 10000 {
+		sections = append(sections, int(n%10000))
+	}
+	if len(sections) > len(chineseBigUnits) {
+		return "", false
+	}
+
+	var b strings.Builder
+	for i := len(sections) - 1; i >= 0; i-- {
+		sec := sections[i]
+		if sec == 0 {
+			continue
+		}
+		// 非最高节不足千位时补零，如 一万零五百
+		if b.Len() > 0 && sec < 1000 {
+			b.WriteString("零")
+		}
+		if sec == 2 && i > 0 {
+			b.WriteString("两") // 两万、两亿
+		} else {
+			b.WriteString(chineseSection(sec))
+		}
+		b.WriteString(chineseBigUnits[i])
+	}
+	// 十到十九开头时省略“一”，如 十万
+	s := b.String()
+	if strings.HasPrefix(s, "一十") {
+		s = strings.TrimPrefix(s, "一")
+	}
+	return s, true
+}
+
+// chineseSection 读 1-9999，中间的零只读一次，末尾的零不读，如 1005 读作 一千零五；
+// 百位、千位的 2 读作“两”
+func chineseSection(n int) string {
+	var b strings.Builder
+	zero := false
+	for pos := 3; pos >= 0; pos-- {
+		d := n / pow10[pos] % 10
+		if d == 0 {
+			zero = b.Len() > 0
+			continue
+		}
+		if zero {
+			b.WriteString("零")
+			zero = false
+		}
+		if d == 2 && pos >= 2 {
+			b.WriteString("两")
+		} else {
+			b.WriteString(chineseDigitChars[d])
+		}
+		b.WriteString(chineseSmallUnits[pos])
+	}
+	return b.String()
+}
+
+var pow10 = []int{1, 10, 100, 1000}
+
+// chineseDigits 逐位读出小数部分，如 05 读作 零五
+func chineseDigits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		b.WriteString(chineseDigitChars[c-'0'])
+	}
+	return b.String()
+}
+
+// chineseCurrencies 为各货币符号的中文名称
+var chineseCurrencies = map[string]string{
+	"$": "美元",
+	"€": "欧元",
+	"£": "英镑",
+	"¥": "元",
+	"￥": "元",
+}
+
+// chineseMoney 读作如 五千美元、五点五元；小数末尾的零不读
+func chineseMoney(symbol string, n uint64, frac string) (string, bool) {
+	words, ok := chineseNumber(n)
+	if !ok {
+		return "", false
+	}
+	if frac = strings.TrimRight(frac, "0"); frac != "" {
+		words += "点" + chineseDigits(frac)
+	}
+	return words + chineseCurrencies[symbol], true
+}
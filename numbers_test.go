@@ -0,0 +1,89 @@
+package main
+
+import "testing"
+
+func TestExpandNumbers(t *testing.T) {
+	tests := []struct {
+		in string
+		zh string
+		en string
+	}{
+		// ISO 日期，无效的日期保持原样
+		{"2024-01-05", "2024年1月5日", "January 5, 2024"},
+		{"会议定在 2024-03-15 上午", "会议定在 2024年3月15日 上午", "会议定在 March 15, 2024 上午"},
+		{"2024-13-05", "2024-13-05", "2024-13-05"},
+		{"2024-02-30", "2024-02-30", "2024-02-30"},
+		// 金额
+		{"$5", "五美元", "five dollars"},
+		{"$1", "一美元", "one dollar"},
+		{"$5.50", "五点五美元", "five dollars and fifty cents"},
+		{"$1.01", "一点零一美元", "one dollar and one cent"},
+		{"$0.99", "零点九九美元", "ninety-nine cents"},
+		{"€1,200", "一千两百欧元", "one thousand two hundred euros"},
+		{"¥5000", "五千元", "five thousand yuan"},
+		{"￥12.30", "十二点三元", "twelve yuan and thirty fen"},
+		{"£2.5", "二点五英镑", "two pounds and fifty pence"},
+		// 带千位分隔符的数字
+		{"12,000", "一万两千", "twelve thousand"},
+		{"20,000", "两万", "twenty thousand"},
+		{"10,500", "一万零五百", "ten thousand five hundred"},
+		{"1,005", "一千零五", "one thousand five"},
+		{"2,000,000", "两百万", "two million"},
+		{"1,234,567", "一百二十三万四千五百六十七", "one million two hundred thirty-four thousand five hundred sixty-seven"},
+		{"3,141.59", "三千一百四十一点五九", "three thousand one hundred forty-one point five nine"},
+		// 编号、电话等不带分隔符的数字，以及不合规的分组，保持原样
+		{"10086", "10086", "10086"},
+		{"100000001", "100000001", "100000001"},
+		{"1,00", "1,00", "1,00"},
+		// 超出范围时保持原样
+		{"12,345,678,901,234,567,890", "12,345,678,901,234,567,890", "12,345,678,901,234,567,890"},
+		{"$12,345,678,901,234,567,890", "$12,345,678,901,234,567,890", "$12,345,678,901,234,567,890"},
+	}
+	for _, tt := range tests {
+		if got := expandNumbers(tt.in, numberLocaleZh); got != tt.zh {
+			t.Errorf("zh: expandNumbers(%q) = %q，期望 %q", tt.in, got, tt.zh)
+		}
+		if got := expandNumbers(tt.in, numberLocaleEn); got != tt.en {
+			t.Errorf("en: expandNumbers(%q) = %q，期望 %q", tt.in, got, tt.en)
+		}
+	}
+}
+
+func TestChineseNumber(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want string
+	}{
+		{0, "零"},
+		{10, "十"},
+		{15, "十五"},
+		{20, "二十"},
+		{105, "一百零五"},
+		{1010, "一千零一十"},
+		{10000, "一万"},
+		{110000, "十一万"},
+		{100000001, "一亿零一"},
+		{200000000, "两亿"},
+	}
+	for _, tt := range tests {
+		if got, ok := chineseNumber(tt.n); !ok || got != tt.want {
+			t.Errorf("chineseNumber(%d) = %q, %v，期望 %q", tt.n, got, ok, tt.want)
+		}
+	}
+}
+
+func TestNumberLocale(t *testing.T) {
+	tests := []struct{ lang, locale, want string }{
+		{"", "", numberLocaleZh},
+		{"", "en", numberLocaleEn},
+		{"en-US", "zh", numberLocaleEn},
+		{"zh-CN", "en", numberLocaleZh},
+		{"ja-JP", "en", numberLocaleEn},
+		{"EN-GB", "", numberLocaleEn},
+	}
+	for _, tt := range tests {
+		if got := numberLocale(tt.lang, tt.locale); got != tt.want {
+			t.Errorf("numberLocale(%q, %q) = %q，期望 %q", tt.lang, tt.locale, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// runOnce 直接用 speaker 朗读 text 后返回，不连接 MQTT，供脚本和 CI 冒烟测试使用。
+// text 为空时从标准输入读取；文本处理（规范化、默认参数、长度限制等）与消息相同
+func runOnce(ctx context.Context, cfg *Config, speaker Speaker, text string) error {
+	if text == "" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("读取标准输入失败: %w", err)
+		}
+		text = string(data)
+	}
+	reqs, err := newSpeakRequests(cfg, ttsPayload{Text: text})
+	if err != nil {
+		return err
+	}
+	for _, req := range splitRequests(cfg, reqs) {
+		if err := speakOnce(ctx, speaker, req, time.Duration(cfg.TTSTimeoutSeconds)*time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func speakOnce(ctx context.Context, speaker Speaker, req speakRequest, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if req.Opts.OutputDir != "" {
+		path, err := newWavPath(req.Opts.OutputDir, time.Now())
+		if err != nil {
+			return fmt.Errorf("无法创建 .wav 文件: %w", err)
+		}
+		req.Opts.OutputFile = path
+	}
+	start := time.Now()
+	if err := speaker.Speak(ctx, req.Text, req.Opts); err != nil {
+		return err
+	}
+	log.Printf("✅ 已完成朗读（%v）: %q", time.Since(start).Round(time.Millisecond), strings.TrimSpace(req.Text))
+	if req.Opts.OutputFile != "" {
+		fmt.Println(req.Opts.OutputFile)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// playWav 通过默认音频设备播放 .wav 文件，ctx 结束时终止播放：
+// Windows 使用 System.Media.SoundPlayer，macOS 使用 afplay，其余使用 ffplay
+func playWav(ctx context.Context, path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = powerShellCommand(ctx, `(New-Object System.Media.SoundPlayer "`+escapePowerShell(path)+`").PlaySync()`)
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "afplay", path)
+	default:
+		cmd = exec.CommandContext(ctx, "ffplay", "-nodisp", "-autoexit", "-loglevel", "error", path)
+	}
+	return runTTSCommand(ctx, "播放器", cmd)
+}
+
+// PlayerSpeaker 先由 Inner 合成到临时 .wav 文件，再用外部播放器命令播放，
+// 用于把语音输出到指定的音频设备：System.Speech 只能输出到默认设备，无法直接选择设备。
+//
+// Command 按空白拆分为参数，其中的 {file} 替换为 .wav 路径、{device} 替换为 Device，
+// 不含 {file} 时路径追加为最后一个参数，例如：
+//
+//	mpv --no-video --audio-device={device} {file}
+//	ffplay -nodisp -autoexit {file}
+//
+// 请求本身要求保存为文件（OutputFile 非空）时直接交给 Inner，不播放
+type PlayerSpeaker struct {
+	Inner   Speaker
+	Command string
+	Device  string
+}
+
+func (s PlayerSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	if opts.OutputFile != "" {
+		return s.Inner.Speak(ctx, text, opts)
+	}
+
+	f, err := os.CreateTemp("", "tts-play-*.wav")
+	if err != nil {
+		return fmt.Errorf("无法创建临时音频文件: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	opts.OutputFile = f.Name()
+	if err := s.Inner.Speak(ctx, text, opts); err != nil {
+		return err
+	}
+	return s.play(ctx, f.Name())
+}
+
+func (s PlayerSpeaker) play(ctx context.Context, path string) error {
+	fields := strings.Fields(s.Command)
+	if len(fields) == 0 {
+		return playWav(ctx, path)
+	}
+	args := fields[1:]
+	hasFile := false
+	r := strings.NewReplacer("{file}", path, "{device}", s.Device)
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			hasFile = true
+		}
+		args[i] = r.Replace(arg)
+	}
+	if !hasFile {
+		args = append(args, path)
+	}
+	cmd := exec.CommandContext(ctx, fields[0], args...)
+	return runTTSCommand(ctx, "播放器", cmd)
+}
+
+// newPlayerSpeaker 在配置了 PlayerCommand 或 AudioDevice 时用 PlayerSpeaker 包装 inner，
+// 否则原样返回，保持直接输出到默认设备的行为
+func newPlayerSpeaker(cfg *Config, inner Speaker) (Speaker, error) {
+	if cfg.PlayerCommand == "" && cfg.AudioDevice == "" {
+		return inner, nil
+	}
+	if cfg.AudioDevice != "" && !strings.Contains(cfg.PlayerCommand, "{device}") {
+		return nil, fmt.Errorf("audio_device 需要配合含 {device} 占位符的 player_command 使用，如 mpv --audio-device={device} {file}")
+	}
+	log.Printf("🎚️ 通过播放器输出: %q（设备 %q）", cfg.PlayerCommand, cfg.AudioDevice)
+	return PlayerSpeaker{Inner: inner, Command: cfg.PlayerCommand, Device: cfg.AudioDevice}, nil
+}
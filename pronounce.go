@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// pronunciation 是一个词的发音修正：普通文本中替换为 Say；
+// SSML 中有 Phoneme 时输出 <phoneme>，否则输出 <sub alias="Say">
+type pronunciation struct {
+	Say      string
+	Phoneme  string
+	Alphabet string // Phoneme 的音标体系，默认 ipa
+}
+
+// ssmlTagPattern 匹配 SSML 标签，标签内（元素名、属性）不做替换
+var ssmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// applyPronunciations 按词典修正 text 中的词（不区分大小写，优先匹配较长的词）。
+// 以字母或数字开头/结尾的词只在词边界处匹配，避免替换单词的一部分；
+// ssml 为 true 时只处理标签之外的文本，并输出 <phoneme> 或 <sub> 标签
+func applyPronunciations(text string, dict map[string]pronunciation, ssml bool) string {
+	if len(dict) == 0 {
+		return text
+	}
+	words := make([]string, 0, len(dict))
+	byLower := make(map[string]pronunciation, len(dict))
+	for w, p := range dict {
+		if w == "" {
+			continue
+		}
+		words = append(words, w)
+		byLower[strings.ToLower(w)] = p
+	}
+	sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = regexp.QuoteMeta(w)
+	}
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+
+	replace := func(s string) string {
+		var b strings.Builder
+		last := 0
+		for _, m := range re.FindAllStringIndex(s, -1) {
+			word := s[m[0]:m[1]]
+			if !atWordBoundary(s, m[0], m[1]) {
+				continue
+			}
+			p := byLower[strings.ToLower(word)]
+			out, ok := p.render(word, ssml)
+			if !ok {
+				continue
+			}
+			b.WriteString(s[last:m[0]])
+			b.WriteString(out)
+			last = m[1]
+		}
+		b.WriteString(s[last:])
+		return b.String()
+	}
+
+	if !ssml {
+		return replace(text)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range ssmlTagPattern.FindAllStringIndex(text, -1) {
+		b.WriteString(replace(text[last:m[0]]))
+		b.WriteString(text[m[0]:m[1]])
+		last = m[1]
+	}
+	b.WriteString(replace(text[last:]))
+	return b.String()
+}
+
+// atWordBoundary 判断 s[start:end] 两端是否为词边界：边缘为字母或数字时，
+// 相邻的字符不能也是字母或数字；中文等其他字符不检查边界
+func atWordBoundary(s string, start, end int) bool {
+	isWord := func(r rune) bool { return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) }
+	first, _ := utf8.DecodeRuneInString(s[start:end])
+	lastR, _ := utf8.DecodeLastRuneInString(s[start:end])
+	if before, _ := utf8.DecodeLastRuneInString(s[:start]); start > 0 && isWord(first) && isWord(before) {
+		return false
+	}
+	if after, _ := utf8.DecodeRuneInString(s[end:]); end < len(s) && isWord(lastR) && isWord(after) {
+		return false
+	}
+	return true
+}
+
+// render 返回 word 的替换内容，没有可用的修正时返回 false
+func (p pronunciation) render(word string, ssml bool) (string, bool) {
+	if !ssml {
+		return p.Say, p.Say != ""
+	}
+	switch {
+	case p.Phoneme != "":
+		alphabet := p.Alphabet
+		if alphabet == "" {
+			alphabet = "ipa"
+		}
+		return `<phoneme alphabet="` + xmlEscape(alphabet) + `" ph="` + xmlEscape(p.Phoneme) + `">` + xmlEscape(word) + `</phoneme>`, true
+	case p.Say != "":
+		return `<sub alias="` + xmlEscape(p.Say) + `">` + xmlEscape(word) + `</sub>`, true
+	}
+	return "", false
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// jsonPronunciations 读取配置文件中的发音词典，值可以是替换文本，
+// 也可以是 {"say": "...", "phoneme": "...", "alphabet": "ipa"}：
+//
+//	"pronunciations": {"Lytmkai": "利特凯", "GIF": {"say": "吉夫", "phoneme": "dʒɪf"}}
+func jsonPronunciations(raw map[string]interface{}, key string, dst *map[string]pronunciation) {
+	obj, ok := raw[key].(map[string]interface{})
+	if !ok {
+		return
+	}
+	dict := make(map[string]pronunciation, len(obj))
+	for word, v := range obj {
+		var p pronunciation
+		switch v := v.(type) {
+		case string:
+			p.Say = v
+		case map[string]interface{}:
+			jsonString(v, "say", &p.Say)
+			jsonString(v, "phoneme", &p.Phoneme)
+			jsonString(v, "alphabet", &p.Alphabet)
+		}
+		if strings.TrimSpace(word) == "" || (p.Say == "" && p.Phoneme == "") {
+			log.Printf("⚠️ %s 中的 %q 没有有效的发音，已忽略", key, word)
+			continue
+		}
+		dict[word] = p
+	}
+	*dst = dict
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestApplyPronunciations(t *testing.T) {
+	dict := map[string]pronunciation{
+		"GIF":        {Say: "吉夫", Phoneme: "dʒɪf"},
+		"Lytmkai":    {Say: "利特凯"},
+		"SQL":        {Say: "sequel"},
+		"SQL Server": {Say: "sequel server"},
+		"C++":        {Say: "C plus plus"},
+		"重庆":         {Say: "虫庆"},
+		"IPA":        {Phoneme: "aɪ piː eɪ", Alphabet: "x-sampa"},
+	}
+	tests := []struct {
+		name string
+		in   string
+		ssml bool
+		want string
+	}{
+		{"plain", "Lytmkai 上线了", false, "利特凯 上线了"},
+		{"case insensitive", "lytmkai 和 LYTMKAI", false, "利特凯 和 利特凯"},
+		{"longest first", "SQL Server 和 SQL", false, "sequel server 和 sequel"},
+		{"word boundary", "GIFs 和 MySQL 不变，GIF 替换", false, "GIFs 和 MySQL 不变，吉夫 替换"},
+		{"symbol edge", "学习 C++。", false, "学习 C plus plus。"},
+		{"chinese without boundary", "去重庆出差", false, "去虫庆出差"},
+		{"phoneme only skipped in plain text", "IPA 音标", false, "IPA 音标"},
+		{"no match", "没有需要修正的词", false, "没有需要修正的词"},
+		{"ssml phoneme", "<speak>GIF 动图</speak>", true, `<speak><phoneme alphabet="ipa" ph="dʒɪf">GIF</phoneme> 动图</speak>`},
+		{"ssml custom alphabet", "<speak>IPA</speak>", true, `<speak><phoneme alphabet="x-sampa" ph="aɪ piː eɪ">IPA</phoneme></speak>`},
+		{"ssml sub keeps original case", "<speak>lytmkai</speak>", true, `<speak><sub alias="利特凯">lytmkai</sub></speak>`},
+		{"ssml escapes", "<speak>C++ &amp; SQL</speak>", true, `<speak><sub alias="C plus plus">C++</sub> &amp; <sub alias="sequel">SQL</sub></speak>`},
+		{"ssml tags untouched", `<speak><voice name="GIF">GIF</voice></speak>`, true, `<speak><voice name="GIF"><phoneme alphabet="ipa" ph="dʒɪf">GIF</phoneme></voice></speak>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyPronunciations(tt.in, dict, tt.ssml); got != tt.want {
+				t.Errorf("applyPronunciations(%q) = %q，期望 %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPronunciationsEmptyDict(t *testing.T) {
+	for _, dict := range []map[string]pronunciation{nil, {"": {Say: "空"}}} {
+		if got := applyPronunciations("GIF 动图", dict, false); got != "GIF 动图" {
+			t.Errorf("词典 %v 修改了文本：%q", dict, got)
+		}
+	}
+}
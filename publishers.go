@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// publisherAllowed 判断 msg 是否来自 AllowedPublishers 中的发布者，返回识别出的发布者标识。
+// MQTT 5（ProtocolVersion 为 5）且配置了 PublisherProperty 时，发布者取自消息的该 user property，
+// 需要 broker 把发布者的 client ID 写入（并覆盖发布方自带的）该属性，如 EMQX 的规则引擎；
+// 否则（MQTT 3.1.1 的 PUBLISH 不携带发布者信息，或消息没有该属性）取自主题的第
+// PublisherTopicLevel 级，需要 broker 的 ACL 保证发布者只能写入含自己 client ID 的主题
+// （如 mosquitto 的 pattern write tts/%c/#）。未配置 AllowedPublishers 时总是允许
+func publisherAllowed(cfg *Config, msg mqtt.Message) (string, bool) {
+	if len(cfg.AllowedPublishers) == 0 {
+		return "", true
+	}
+	var sender string
+	if cfg.ProtocolVersion == 5 && cfg.PublisherProperty != "" {
+		sender = userProperty(msg, cfg.PublisherProperty)
+	}
+	if sender == "" && cfg.PublisherTopicLevel != 0 {
+		sender = topicLabel(msg.Topic(), cfg.PublisherTopicLevel, false)
+	}
+	for _, p := range cfg.AllowedPublishers {
+		if sender != "" && p == sender {
+			return sender, true
+		}
+	}
+	return sender, false
+}
+
+// validatePublishers 检查 AllowedPublishers 配置了识别发布者的方式
+func validatePublishers(cfg *Config) error {
+	if len(cfg.AllowedPublishers) == 0 {
+		return nil
+	}
+	if cfg.PublisherProperty != "" && cfg.ProtocolVersion != 5 {
+		return errors.New("publisher_property 需要 protocol_version 5：MQTT 3.1.1 的消息没有 user properties")
+	}
+	if cfg.PublisherTopicLevel == 0 && cfg.PublisherProperty == "" {
+		return errors.New("allowed_publishers 需要配合 publisher_property（MQTT 5，由 broker 写入发布者的 client ID）" +
+			"或 publisher_topic_level：MQTT 3.1.1 无法得知发布者的 client ID，" +
+			"请在 broker 的 ACL 中限制发布者只能写入含自己 client ID 的主题，并指定该级的位置")
+	}
+	return nil
+}
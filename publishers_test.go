@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestPublisherAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  int
+		property string
+		level    int
+		msg      mqtt.Message
+		sender   string
+		allowed  bool
+	}{
+		{"topic level", 4, "", 2, &fakeMessage{topic: "tts/doorbell/say"}, "doorbell", true},
+		{"topic level rejected", 4, "", 2, &fakeMessage{topic: "tts/intruder/say"}, "intruder", false},
+		{"topic level from end", 4, "", -2, &fakeMessage{topic: "home/tts/doorbell/say"}, "doorbell", true},
+		{"topic too short", 4, "", 3, &fakeMessage{topic: "tts/say"}, "", false},
+		{"v5 property", 5, "client_id", 0, newV5Message("tts/say", "client_id", "doorbell"), "doorbell", true},
+		{"v5 property rejected", 5, "client_id", 0, newV5Message("tts/say", "client_id", "intruder"), "intruder", false},
+		{"v5 property wins over topic", 5, "client_id", 2, newV5Message("tts/intruder/say", "client_id", "doorbell"), "doorbell", true},
+		{"v5 missing property falls back to topic", 5, "client_id", 2, newV5Message("tts/doorbell/say"), "doorbell", true},
+		{"v5 missing property without topic level", 5, "client_id", 0, newV5Message("tts/say"), "", false},
+		{"v5 message without property configured", 5, "", 2, newV5Message("tts/doorbell/say", "client_id", "intruder"), "doorbell", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				AllowedPublishers:   []string{"doorbell", "alarm"},
+				ProtocolVersion:     tt.version,
+				PublisherProperty:   tt.property,
+				PublisherTopicLevel: tt.level,
+			}
+			sender, ok := publisherAllowed(cfg, tt.msg)
+			if sender != tt.sender || ok != tt.allowed {
+				t.Errorf("publisherAllowed = %q, %v，期望 %q, %v", sender, ok, tt.sender, tt.allowed)
+			}
+		})
+	}
+
+	if _, ok := publisherAllowed(&Config{}, &fakeMessage{topic: "tts/say"}); !ok {
+		t.Error("未配置 allowed_publishers 时应允许所有消息")
+	}
+}
+
+func TestValidatePublishers(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"not configured", Config{}, false},
+		{"topic level", Config{AllowedPublishers: []string{"a"}, PublisherTopicLevel: 2}, false},
+		{"v5 property", Config{AllowedPublishers: []string{"a"}, ProtocolVersion: 5, PublisherProperty: "client_id"}, false},
+		{"no identity", Config{AllowedPublishers: []string{"a"}}, true},
+		{"property without v5", Config{AllowedPublishers: []string{"a"}, ProtocolVersion: 4, PublisherProperty: "client_id", PublisherTopicLevel: 2}, true},
+	}
+	for _, tt := range tests {
+		if err := validatePublishers(&tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: validatePublishers = %v，期望出错 %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBridgeRejectsUnknownV5Publisher(t *testing.T) {
+	broker := startBroker(t)
+	speaker := newRecordingSpeaker()
+	cfg := testConfig(t, broker.tcp)
+	cfg.ProtocolVersion = 5
+	cfg.AllowedPublishers = []string{"doorbell"}
+	cfg.PublisherProperty = "client_id"
+	startBridge(t, broker, cfg, speaker)
+
+	publishV5(t, broker, "test/tts", "伪造的消息", "client_id", "intruder")
+	publishV5(t, broker, "test/tts", "有人按门铃", "client_id", "doorbell")
+	if got := speaker.next(t); got.Text != "有人按门铃" {
+		t.Errorf("朗读 %q，期望只朗读允许的发布者的消息", got.Text)
+	}
+}
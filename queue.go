@@ -0,0 +1,616 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// errPreempted 表示正在进行的朗读被更高优先级的消息打断
+var errPreempted = errors.New("被更高优先级的消息打断")
+
+// errStopped 表示正在进行的朗读被 stop 命令停止
+var errStopped = errors.New("被 stop 命令停止")
+
+// errStale 表示请求在队列中等待超过 maxAge，未朗读即被丢弃
+var errStale = errors.New("排队时间过长，已过期")
+
+// speakRequest 是一条待朗读的请求
+type speakRequest struct {
+	Text     string
+	Opts     speakOptions
+	Priority int    // 越大越优先，默认 0
+	Topic    string // 消息实际到达的主题（订阅通配符时为具体主题），HTTP 请求为空
+
+	ReplyTo       string        // 朗读结束后发布完成消息的主题，空则不回复
+	CorrelationID string        // 原样带回到回复和状态回执中
+	reply         *replyTracker // 同一条消息各段共用，由 trackReply 设置
+
+	// 由 splitRequests 拆分出的段落：group 为同一条文本共用的组号（0 表示未拆分），
+	// chunk 为从 0 开始的段号，chunks 为总段数
+	group         uint64
+	chunk, chunks int
+
+	seq      uint64    // 入队序号，用于同优先级 FIFO
+	enqueued time.Time // 入队时间，用于 maxAge 过期判断
+	attempts int       // 朗读尝试次数（含重试），未朗读时为 0
+}
+
+// speakQueue 是有界优先级朗读队列，默认由单个 worker 依次朗读，
+// 避免多条消息同时调用 TTS 导致 Windows 上语音重叠。
+// workers > 1 时多个 worker 从同一队列取请求并发朗读，只适合输出到文件
+// 或各 worker 使用不同音频设备的场景，默认设备无法干净地播放重叠的语音。
+//
+// 顺序保证：优先级高的先朗读；优先级相同时严格按到达顺序（FIFO）。
+// 队列满时在“队列中优先级最低的请求 + 新请求”中丢弃一条：
+// 优先低者，同为最低时按 dropOldest 丢弃最旧或最新的一条。
+type speakQueue struct {
+	mu     sync.Mutex
+	items  []speakRequest // 按优先级从高到低、同优先级按 seq 从小到大排列
+	size   int
+	seq    uint64
+	notify chan struct{} // 有新请求时唤醒 worker
+
+	dropOldest bool // 队列满时丢弃最旧的请求，否则丢弃新到的请求
+
+	workers int // 并发朗读的 worker 数量
+
+	// preempt 为 true 时，所有 worker 都在朗读且新请求优先级更高时，
+	// 立即打断其中优先级最低的一条
+	preempt bool
+	current map[*speakRequest]context.CancelCauseFunc // 正在朗读的请求
+
+	// timeout 为单条朗读的最长时间，<= 0 表示不限时。
+	// 超时会取消 context，由 exec.CommandContext 终止 powershell 进程
+	timeout time.Duration
+
+	// maxAge 为请求在队列中的最长等待时间，<= 0 表示不限。worker 取出已超过该时间的
+	// 请求时直接丢弃，避免积压消除后朗读早已过时的提醒
+	maxAge time.Duration
+
+	// chunkPause 为拆分出的相邻段之间的停顿；keepChunks 为 true 时 worker 读完一段后
+	// 优先取同组的下一段（hold 为该组号），更高优先级的消息不会插入其间
+	chunkPause time.Duration
+	keepChunks bool
+	hold       uint64
+
+	// gap 为相邻两条消息之间的停顿（拆分出的段之间用 chunkPause），
+	// 停顿期间到达的更高优先级请求不等待
+	gap time.Duration
+
+	// 朗读失败时最多重试 retries 次，等待从 retryDelay 开始每次翻倍（见 speakWithRetry）；
+	// 超时的朗读可能已读出一部分，retryTimeout 为 true 时才重试
+	retries      int
+	retryDelay   time.Duration
+	retryTimeout bool
+
+	speaker Speaker
+
+	// failures 统计连续失败，用于发现挂起的音频子系统
+	failures failureTracker
+
+	// 静音：muteUntil 之前 worker 不取新的请求（正在进行的朗读不受影响），
+	// 零值表示未静音；muteTimer 到期时自动解除静音
+	muteUntil time.Time
+	muteTimer *time.Timer
+
+	// onResult 在每条请求朗读结束后调用（可为 nil），用于发布状态回执
+	onResult func(req speakRequest, err error, elapsed time.Duration)
+	// onDrop 在请求未朗读即被丢弃时调用（可为 nil），不持有锁，reason 为 dropEvent.Reason。
+	// 可能在 MQTT 回调中调用，不能阻塞
+	onDrop func(req speakRequest, reason string)
+	// onSpeaking 在每条请求开始朗读时以 true、结束（含超时和取消）时以 false 调用（可为 nil）
+	onSpeaking func(req speakRequest, speaking bool)
+}
+
+func newSpeakQueue(cfg *Config, speaker Speaker) *speakQueue {
+	size := cfg.QueueSize
+	if size <= 0 {
+		size = 1
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	return &speakQueue{
+		size:       size,
+		workers:    workers,
+		current:    make(map[*speakRequest]context.CancelCauseFunc),
+		notify:     make(chan struct{}, workers),
+		dropOldest: cfg.QueueDropOldest,
+		preempt:    cfg.Preempt,
+		timeout:    time.Duration(cfg.TTSTimeoutSeconds) * time.Second,
+		maxAge:     time.Duration(cfg.MaxAgeSeconds) * time.Second,
+		chunkPause: time.Duration(cfg.ChunkPauseMs) * time.Millisecond,
+		keepChunks: cfg.KeepChunksTogether,
+		gap:        time.Duration(cfg.InterUtteranceGapMs) * time.Millisecond,
+		speaker:    speaker,
+
+		retries:      cfg.SpeakRetries,
+		retryDelay:   time.Duration(cfg.SpeakRetryDelayMs) * time.Millisecond,
+		retryTimeout: cfg.RetryOnTimeout,
+		failures:     failureTracker{threshold: cfg.FailureThreshold},
+	}
+}
+
+// Enqueue 在一次加锁内按顺序放入 reqs，不会阻塞调用方（MQTT 回调），
+// 同优先级的其他请求不会插入其间。返回 false 表示 reqs 中有请求本身被丢弃。
+func (q *speakQueue) Enqueue(reqs ...speakRequest) bool {
+	q.mu.Lock()
+	var dropped []speakRequest
+	defer func() {
+		q.mu.Unlock()
+		q.dropped(dropped, dropQueueFull)
+	}()
+
+	ok := true
+	for _, req := range reqs {
+		if !q.enqueueLocked(req, &dropped) {
+			ok = false
+		}
+	}
+	// 每条请求唤醒一个 worker，最多唤醒全部 worker
+	for i := 0; i < len(reqs) && i < q.workers; i++ {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+	return ok
+}
+
+// enqueueLocked 放入 req，队列已满时被丢弃的请求（req 本身或被挤掉的请求）追加到 dropped
+func (q *speakQueue) enqueueLocked(req speakRequest, dropped *[]speakRequest) bool {
+	q.seq++
+	req.seq = q.seq
+	req.enqueued = time.Now()
+
+	if len(q.items) >= q.size {
+		// 队列末尾是优先级最低的一段，找出其起点（最旧的一条）
+		lowest := q.items[len(q.items)-1].Priority
+		if req.Priority < lowest || (req.Priority == lowest && !q.dropOldest) {
+			log.Printf("🗑️ 朗读队列已满（%d），丢弃新消息: %.50q", q.size, req.Text)
+			*dropped = append(*dropped, req)
+			return false
+		}
+		victim := len(q.items) - 1
+		if q.dropOldest {
+			for victim > 0 && q.items[victim-1].Priority == lowest {
+				victim--
+			}
+		}
+		log.Printf("🗑️ 朗读队列已满（%d），丢弃消息: %.50q", q.size, q.items[victim].Text)
+		*dropped = append(*dropped, q.items[victim])
+		q.items = append(q.items[:victim], q.items[victim+1:]...)
+	}
+
+	// 插入到第一个优先级更低的请求之前，同优先级保持 FIFO
+	i := len(q.items)
+	for j, item := range q.items {
+		if item.Priority < req.Priority {
+			i = j
+			break
+		}
+	}
+	q.items = append(q.items, speakRequest{})
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = req
+
+	if q.preempt && len(q.current) >= q.workers {
+		var lowest *speakRequest
+		for cur := range q.current {
+			if lowest == nil || cur.Priority < lowest.Priority {
+				lowest = cur
+			}
+		}
+		if req.Priority > lowest.Priority {
+			log.Printf("⏭️ 优先级 %d 的消息打断当前朗读（优先级 %d）: %.50q", req.Priority, lowest.Priority, lowest.Text)
+			q.current[lowest](errPreempted)
+		}
+	}
+	return true
+}
+
+// foreverMuted 表示未指定时长、直到手动解除的静音
+var foreverMuted = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Mute 静音 d 时间，到期自动解除；d <= 0 表示直到调用 Unmute。返回静音截止时间
+func (q *speakQueue) Mute(d time.Duration) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.muteTimer != nil {
+		q.muteTimer.Stop()
+		q.muteTimer = nil
+	}
+	q.muteUntil = foreverMuted
+	if d > 0 {
+		q.muteUntil = time.Now().Add(d)
+		var timer *time.Timer
+		timer = time.AfterFunc(d, func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			// 到期前已被重新静音或解除时不处理
+			if q.muteTimer != timer {
+				return
+			}
+			log.Println("🔔 静音已到期，恢复朗读")
+			q.unmuteLocked()
+		})
+		q.muteTimer = timer
+	}
+	return q.muteUntil
+}
+
+// Unmute 解除静音并唤醒 worker，返回之前是否处于静音
+func (q *speakQueue) Unmute() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.muteUntil.IsZero() {
+		return false
+	}
+	if q.muteTimer != nil {
+		q.muteTimer.Stop()
+	}
+	q.unmuteLocked()
+	return true
+}
+
+func (q *speakQueue) unmuteLocked() {
+	q.muteUntil = time.Time{}
+	q.muteTimer = nil
+	for i := 0; i < q.workers; i++ {
+		select {
+		case q.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// MutedUntil 返回静音截止时间，未静音时 ok 为 false
+func (q *speakQueue) MutedUntil() (until time.Time, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.muteUntil, !q.muteUntil.IsZero()
+}
+
+// pop 取出队首请求，静音期间不取。等待超过 maxAge 的请求被丢弃并通过 onResult 回执
+func (q *speakQueue) pop() (speakRequest, bool) {
+	q.mu.Lock()
+	var stale []speakRequest
+	defer func() {
+		q.mu.Unlock()
+		for _, req := range stale {
+			log.Printf("⌛ 消息排队 %v 已过期，不再朗读: %.50q", time.Since(req.enqueued).Round(time.Second), req.Text)
+			if q.onResult != nil {
+				q.onResult(req, errStale, 0)
+			}
+		}
+		q.dropped(stale, dropStale)
+	}()
+	for len(q.items) > 0 && q.muteUntil.IsZero() {
+		i := q.nextIndexLocked()
+		req := q.items[i]
+		q.items = append(q.items[:i], q.items[i+1:]...)
+		if q.maxAge > 0 && time.Since(req.enqueued) > q.maxAge {
+			stale = append(stale, req)
+			continue
+		}
+		q.hold = 0
+		if q.keepChunks && req.group != 0 && req.chunk < req.chunks-1 {
+			q.hold = req.group
+		}
+		return req, true
+	}
+	return speakRequest{}, false
+}
+
+// nextIndexLocked 返回下一条要朗读的请求的下标：连续朗读拆分段落时为同组的下一段，
+// 该组已没有排队的段落（被丢弃或清空）时为队首
+func (q *speakQueue) nextIndexLocked() int {
+	if q.hold != 0 {
+		for i, item := range q.items {
+			if item.group == q.hold {
+				return i
+			}
+		}
+		q.hold = 0
+	}
+	return 0
+}
+
+// Len 返回当前排队等待朗读的请求数
+func (q *speakQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// run 启动 workers 个 worker 朗读队列中的请求，应在单独的 goroutine 中运行。
+// ctx 结束时正在进行的朗读被终止，所有 worker 退出后 run 返回，队列中剩余的请求不再朗读。
+func (q *speakQueue) run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+	if n := q.Len(); n > 0 {
+		log.Printf("🗑️ 退出时放弃 %d 条未朗读的消息", n)
+	}
+}
+
+// work 是单个 worker 的循环：队列为空时等待唤醒，否则取出队首请求朗读
+func (q *speakQueue) work(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		req, ok := q.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+			case <-q.notify:
+			}
+			continue
+		}
+
+		start := time.Now()
+		var err error
+		if req.Opts.OutputDir != "" {
+			if req.Opts.OutputFile, err = newWavPath(req.Opts.OutputDir, start); err != nil {
+				log.Printf("❌ 无法创建 .wav 文件: %v", err)
+			}
+		}
+		if err == nil {
+			req.attempts, err = q.speakWithRetry(ctx, req)
+			q.failures.observe(ctx, err)
+		}
+		if q.onResult != nil {
+			q.onResult(req, err, time.Since(start))
+		}
+		if req.group != 0 && req.chunk < req.chunks-1 {
+			q.afterChunk(ctx, req, err)
+		} else {
+			q.afterUtterance(ctx, req)
+		}
+	}
+}
+
+// maxSpeakRetryDelay 为两次重试之间的最长等待时间
+const maxSpeakRetryDelay = 30 * time.Second
+
+// speakWithRetry 朗读 req，失败时最多重试 retries 次，返回尝试次数和最后一次的结果。
+// 被打断、停止或取消的朗读不重试
+func (q *speakQueue) speakWithRetry(ctx context.Context, req speakRequest) (int, error) {
+	q.mu.Lock()
+	retries, delay, retryTimeout := q.retries, q.retryDelay, q.retryTimeout
+	q.mu.Unlock()
+	wait := &backoff{min: delay, max: maxSpeakRetryDelay}
+	for attempt := 1; ; attempt++ {
+		err := q.speakLive(ctx, req)
+		if attempt > retries || !retryableSpeakError(err, retryTimeout) || ctx.Err() != nil {
+			if err != nil && attempt > 1 {
+				log.Printf("❌ 重试 %d 次后仍朗读失败: %.50q", attempt-1, req.Text)
+			}
+			return attempt, err
+		}
+		var d time.Duration
+		if delay > 0 {
+			d = wait.Next()
+		}
+		log.Printf("🔁 朗读失败，%v 后重试（%d/%d）: %v", d.Round(time.Millisecond), attempt, retries, err)
+		select {
+		case <-ctx.Done():
+			return attempt, ctx.Err()
+		case <-time.After(d):
+		}
+	}
+}
+
+// retryableSpeakError 判断朗读错误是否值得重试：超时的朗读可能已读出一部分，
+// 只在 retryTimeout 为 true 时重试
+func retryableSpeakError(err error, retryTimeout bool) bool {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, errPreempted), errors.Is(err, errStopped):
+		return false
+	case errors.Is(err, ErrSpeakTimeout):
+		return retryTimeout
+	}
+	return true
+}
+
+// speakLive 朗读 req，前后调用 onSpeaking，朗读超时或被取消时同样会通知结束
+func (q *speakQueue) speakLive(ctx context.Context, req speakRequest) error {
+	if q.onSpeaking != nil {
+		q.onSpeaking(req, true)
+		defer q.onSpeaking(req, false)
+	}
+	return q.speak(ctx, req)
+}
+
+// afterUtterance 在一条消息读完后停顿 gap 再取下一条，第一条之前不停顿。
+// 停顿期间队首出现优先级高于 prev 的请求时立即结束停顿
+func (q *speakQueue) afterUtterance(ctx context.Context, prev speakRequest) {
+	q.mu.Lock()
+	gap := q.gap
+	q.mu.Unlock()
+	if gap <= 0 {
+		return
+	}
+	timer := time.NewTimer(gap)
+	defer timer.Stop()
+	for {
+		if q.headPriority() > prev.Priority {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			return
+		case <-q.notify:
+		}
+	}
+}
+
+// headPriority 返回队首请求的优先级，队列为空时返回最小的 int
+func (q *speakQueue) headPriority() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return math.MinInt
+	}
+	return q.items[0].Priority
+}
+
+// afterChunk 在拆分出的一段（不是最后一段）读完后、下一段开始前停顿 chunkPause。
+// 这一段被抢占时放弃连续朗读，让抢占的消息先读
+func (q *speakQueue) afterChunk(ctx context.Context, req speakRequest, err error) {
+	q.mu.Lock()
+	if errors.Is(err, errPreempted) && q.hold == req.group {
+		q.hold = 0
+	}
+	pause := q.chunkPause
+	q.mu.Unlock()
+	if err != nil || pause <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(pause):
+	}
+}
+
+// reportDepth 每隔 interval 记录一次队列积压情况，队列为空时不输出
+func (q *speakQueue) reportDepth(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if n := q.Len(); n > 0 {
+			log.Printf("📋 朗读队列积压: %d/%d", n, q.size)
+		}
+	}
+}
+
+// Stop 立即停止所有正在进行的朗读，clear 为 true 时同时清空队列。
+// 返回被停止的朗读数和被清除的排队请求数
+func (q *speakQueue) Stop(clear bool) (stopped, cleared int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, cancel := range q.current {
+		cancel(errStopped)
+	}
+	if clear {
+		cleared = len(q.items)
+		q.items = nil
+	}
+	return len(q.current), cleared
+}
+
+// SetMaxAge 修改请求在队列中的最长等待时间，对已在排队的请求同样生效
+func (q *speakQueue) SetMaxAge(maxAge time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.maxAge = maxAge
+}
+
+// SetChunking 修改拆分段落之间的停顿和是否连续朗读，从下一段开始生效
+func (q *speakQueue) SetChunking(pause time.Duration, keepTogether bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.chunkPause = pause
+	q.keepChunks = keepTogether
+	if !keepTogether {
+		q.hold = 0
+	}
+}
+
+// SetGap 修改相邻两条消息之间的停顿，从下一条朗读结束后开始生效
+func (q *speakQueue) SetGap(gap time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.gap = gap
+}
+
+// SetRetry 修改朗读失败时的重试次数、初始等待时间和是否重试超时的朗读，从下一条朗读开始生效
+func (q *speakQueue) SetRetry(retries int, delay time.Duration, retryTimeout bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.retries, q.retryDelay, q.retryTimeout = retries, delay, retryTimeout
+}
+
+// SetTimeout 修改单条朗读的超时时间，从下一条朗读开始生效
+func (q *speakQueue) SetTimeout(timeout time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.timeout = timeout
+}
+
+func (q *speakQueue) speak(ctx context.Context, req speakRequest) error {
+	q.mu.Lock()
+	timeout := q.timeout
+	q.mu.Unlock()
+	// 超时、被打断或退出时 speakText 会终止 powershell 进程，避免其继续占用音频设备
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	if timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	q.mu.Lock()
+	q.current[&req] = cancel
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		delete(q.current, &req)
+		q.mu.Unlock()
+	}()
+
+	start := time.Now()
+	err := q.speaker.Speak(ctx, req.Text, req.Opts)
+	if cause := context.Cause(ctx); errors.Is(err, context.Canceled) && (errors.Is(cause, errPreempted) || errors.Is(cause, errStopped)) {
+		err = cause
+	}
+	elapsed := time.Since(start)
+	metrics.observeSpeak(err, elapsed)
+	attrs := []slog.Attr{
+		slog.Int("text_len", utf8.RuneCountInString(req.Text)),
+		slog.Int64("duration_ms", elapsed.Milliseconds()),
+	}
+	if req.Topic != "" {
+		attrs = append(attrs, slog.String("topic", req.Topic))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	switch {
+	case errors.Is(err, ErrSpeakTimeout):
+		logEvent("speak_timeout", attrs, "⏰ TTS 超时（%v），已终止朗读: %.50q", timeout, req.Text)
+	case errors.Is(err, errPreempted):
+		logEvent("speak_preempted", attrs, "⏭️ 朗读被打断: %.50q", req.Text)
+	case errors.Is(err, errStopped):
+		logEvent("speak_stopped", attrs, "🛑 朗读已被 stop 命令停止: %.50q", req.Text)
+	case errors.Is(err, context.Canceled):
+		logEvent("speak_canceled", attrs, "🛑 朗读已取消: %.50q", req.Text)
+	case err != nil:
+		logEvent("speak_failed", attrs, "❌ TTS 错误: %v", err)
+	default:
+		logEvent("speak_done", attrs, "✅ 已完成朗读: %q", req.Text)
+	}
+	return err
+}
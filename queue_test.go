@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errDeviceBusy = errors.New("音频设备被占用")
+
+// flakySpeaker 前 fail 次（< 0 表示每次）朗读返回 err，记录每次朗读的开始时间
+type flakySpeaker struct {
+	mu    sync.Mutex
+	fail  int
+	err   error
+	calls []time.Time
+}
+
+func (s *flakySpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	s.mu.Lock()
+	s.calls = append(s.calls, time.Now())
+	n := len(s.calls)
+	s.mu.Unlock()
+	if s.fail < 0 || n <= s.fail {
+		return s.err
+	}
+	return nil
+}
+
+func (s *flakySpeaker) Calls() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.calls...)
+}
+
+// newRetryQueue 返回使用 speaker、最多重试 retries 次、首次重试等待 delay 的队列
+func newRetryQueue(speaker Speaker, retries int, delay time.Duration) *speakQueue {
+	q := newSpeakQueue(defaultConfig(), speaker)
+	q.SetRetry(retries, delay, false)
+	return q
+}
+
+func TestSpeakWithRetryAttempts(t *testing.T) {
+	tests := []struct {
+		name     string
+		fail     int
+		err      error
+		retries  int
+		attempts int
+		wantErr  error
+	}{
+		{"success", 0, errDeviceBusy, 3, 1, nil},
+		{"recovers", 2, errDeviceBusy, 3, 3, nil},
+		{"exhausted", -1, errDeviceBusy, 2, 3, errDeviceBusy},
+		{"no retries", -1, errDeviceBusy, 0, 1, errDeviceBusy},
+		{"timeout not retried", -1, ErrSpeakTimeout, 3, 1, ErrSpeakTimeout},
+		{"preempted not retried", -1, errPreempted, 3, 1, errPreempted},
+		{"stopped not retried", -1, errStopped, 3, 1, errStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			speaker := &flakySpeaker{fail: tt.fail, err: tt.err}
+			q := newRetryQueue(speaker, tt.retries, time.Millisecond)
+			attempts, err := q.speakWithRetry(context.Background(), speakRequest{Text: "你好"})
+			if attempts != tt.attempts || len(speaker.Calls()) != tt.attempts {
+				t.Errorf("尝试 %d 次（朗读 %d 次），期望 %d 次", attempts, len(speaker.Calls()), tt.attempts)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("返回 %v，期望 %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSpeakWithRetryOnTimeout(t *testing.T) {
+	speaker := &flakySpeaker{fail: 1, err: ErrSpeakTimeout}
+	q := newSpeakQueue(defaultConfig(), speaker)
+	q.SetRetry(2, time.Millisecond, true)
+	attempts, err := q.speakWithRetry(context.Background(), speakRequest{Text: "你好"})
+	if attempts != 2 || err != nil {
+		t.Errorf("retry_on_timeout 时尝试 %d 次、返回 %v，期望重试一次后成功", attempts, err)
+	}
+}
+
+// 重试间隔从 delay 起每次翻倍，抖动后在 [d/2, d] 内
+func TestSpeakWithRetryBackoffGrows(t *testing.T) {
+	const delay = 40 * time.Millisecond
+	speaker := &flakySpeaker{fail: -1, err: errDeviceBusy}
+	q := newRetryQueue(speaker, 3, delay)
+	if attempts, _ := q.speakWithRetry(context.Background(), speakRequest{Text: "你好"}); attempts != 4 {
+		t.Fatalf("尝试 %d 次，期望 4 次", attempts)
+	}
+	calls := speaker.Calls()
+	var gaps []time.Duration
+	for i := 1; i < len(calls); i++ {
+		gaps = append(gaps, calls[i].Sub(calls[i-1]))
+	}
+	for i, gap := range gaps {
+		if min := delay << i / 2; gap < min {
+			t.Errorf("第 %d 次重试前等待 %v，期望至少 %v", i+1, gap, min)
+		}
+	}
+	if gaps[2] <= gaps[0] {
+		t.Errorf("重试间隔没有增长: %v", gaps)
+	}
+}
+
+// 等待重试期间 ctx 结束时立即返回，不再朗读
+func TestSpeakWithRetryStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	speaker := &flakySpeaker{fail: -1, err: errDeviceBusy}
+	q := newRetryQueue(speaker, 5, time.Hour)
+
+	start := time.Now()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	attempts, err := q.speakWithRetry(ctx, speakRequest{Text: "你好"})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("取消后 %v 才返回", elapsed)
+	}
+	if attempts != 1 || len(speaker.Calls()) != 1 {
+		t.Errorf("尝试 %d 次（朗读 %d 次），期望取消后不再重试", attempts, len(speaker.Calls()))
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("返回 %v，期望 context.Canceled", err)
+	}
+}
+
+// 全部重试失败后只记录一次失败，回执带上尝试次数
+func TestQueueRecordsFailureAfterRetries(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.SpeakRetries = 2
+	cfg.SpeakRetryDelayMs = 1
+	cfg.FailureThreshold = 1
+	speaker := &flakySpeaker{fail: -1, err: errDeviceBusy}
+	q := newSpeakQueue(cfg, speaker)
+
+	type result struct {
+		attempts int
+		err      error
+	}
+	results := make(chan result, 4)
+	q.onResult = func(req speakRequest, err error, _ time.Duration) {
+		results <- result{req.attempts, err}
+	}
+	unhealthy := make(chan int, 4)
+	q.failures.onUnhealthy = func(_ context.Context, count int) { unhealthy <- count }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	q.Enqueue(speakRequest{Text: "你好"})
+
+	select {
+	case r := <-results:
+		if r.attempts != 3 || !errors.Is(r.err, errDeviceBusy) {
+			t.Errorf("回执为 %d 次、%v，期望 3 次后失败", r.attempts, r.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待朗读结果超时")
+	}
+	select {
+	case count := <-unhealthy:
+		if count != 1 {
+			t.Errorf("连续失败 %d 次，期望整条消息只记录 1 次", count)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("失败未被记录")
+	}
+	if _, count := q.failures.Status(); count != 1 {
+		t.Errorf("连续失败 %d 次，期望 1 次", count)
+	}
+	if n := len(speaker.Calls()); n != 3 {
+		t.Errorf("朗读 %d 次，期望 3 次", n)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// quietHours 是每天的安静时段，以当天的分钟数表示，start == end 表示全天
+type quietHours struct {
+	start, end int
+}
+
+// parseQuietHours 解析 "22:00-07:00" 形式的时段（本地时间），结束早于开始表示跨越午夜
+func parseQuietHours(s string) (quietHours, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return quietHours{}, fmt.Errorf("无效的 quiet_hours %q，格式应为 HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("无效的 quiet_hours %q: %v", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return quietHours{}, fmt.Errorf("无效的 quiet_hours %q: %v", s, err)
+	}
+	return quietHours{start: start, end: end}, nil
+}
+
+// parseClock 把 "HH:MM" 转为当天的分钟数
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("时间 %q 不是 HH:MM 格式", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuietHours 判断 now（按其所在时区）是否处于安静时段，包含开始时刻、不包含结束时刻
+func (q quietHours) inQuietHours(now time.Time) bool {
+	m := now.Hour()*60 + now.Minute()
+	if q.start <= q.end {
+		return q.start == q.end || (m >= q.start && m < q.end)
+	}
+	// 跨越午夜，如 22:00-07:00
+	return m >= q.start || m < q.end
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// at 返回本地时间今天的 HH:MM
+func at(hour, minute int) time.Time {
+	return time.Date(2024, 3, 15, hour, minute, 30, 0, time.Local)
+}
+
+func TestInQuietHours(t *testing.T) {
+	tests := []struct {
+		hours string
+		now   time.Time
+		want  bool
+	}{
+		// 跨越午夜：包含开始时刻，不包含结束时刻
+		{"22:00-07:00", at(21, 59), false},
+		{"22:00-07:00", at(22, 0), true},
+		{"22:00-07:00", at(23, 59), true},
+		{"22:00-07:00", at(0, 0), true},
+		{"22:00-07:00", at(3, 0), true},
+		{"22:00-07:00", at(6, 59), true},
+		{"22:00-07:00", at(7, 0), false},
+		{"22:00-07:00", at(12, 0), false},
+		// 不跨越午夜
+		{"13:00-14:30", at(12, 59), false},
+		{"13:00-14:30", at(13, 0), true},
+		{"13:00-14:30", at(14, 29), true},
+		{"13:00-14:30", at(14, 30), false},
+		// 开始等于结束表示全天
+		{"00:00-00:00", at(0, 0), true},
+		{"08:00-08:00", at(7, 59), true},
+		{"08:00-08:00", at(8, 0), true},
+		{"08:00-08:00", at(20, 0), true},
+		// 前后的空白
+		{" 22:00 - 07:00 ", at(23, 0), true},
+	}
+	for _, tt := range tests {
+		q, err := parseQuietHours(tt.hours)
+		if err != nil {
+			t.Fatalf("parseQuietHours(%q): %v", tt.hours, err)
+		}
+		if got := q.inQuietHours(tt.now); got != tt.want {
+			t.Errorf("%q 在 %s: inQuietHours = %v，期望 %v", tt.hours, tt.now.Format("15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestParseQuietHoursRejectsBadInput(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"22:00",
+		"22:00_07:00",
+		"24:00-07:00",
+		"22:60-07:00",
+		"22-07",
+		"10pm-7am",
+		"22:00-07:00-08:00",
+		"-07:00",
+		"22:00-",
+	} {
+		if q, err := parseQuietHours(s); err == nil {
+			t.Errorf("parseQuietHours(%q) = %+v，期望报错", s, q)
+		}
+	}
+}
+
+// 安静时段内优先级达到豁免值的消息照常朗读
+func TestBridgeQuietBypassPriority(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.QuietHours = "22:00-07:00"
+	cfg.QuietHoursBypassPriority = 5
+	b := newTestBridge(cfg)
+	tests := []struct {
+		now      time.Time
+		priority int
+		want     bool
+	}{
+		{at(23, 0), 0, true},
+		{at(23, 0), 4, true},
+		{at(23, 0), 5, false},
+		{at(12, 0), 0, false},
+	}
+	for _, tt := range tests {
+		if got := b.quiet([]speakRequest{{Text: "你好", Priority: tt.priority}}, tt.now); got != tt.want {
+			t.Errorf("%s 优先级 %d: quiet = %v，期望 %v", tt.now.Format("15:04"), tt.priority, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// rateLimiter 是令牌桶限流器：每分钟补充 perMinute 个令牌，最多积累 burst 个，
+// 每条消息消耗一个，令牌不足时消息被丢弃。perMinute <= 0 表示不限流
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // 每秒补充的令牌数
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	dropped int // 本轮限流中丢弃的消息数，恢复时输出汇总
+}
+
+// Configure 设置限流参数，burst <= 0 时取 perMinute（至少为 1）
+func (l *rateLimiter) Configure(perMinute, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perMinute <= 0 {
+		l.rate = 0
+		return
+	}
+	if burst <= 0 {
+		burst = perMinute
+	}
+	l.rate = float64(perMinute) / 60
+	l.burst = float64(burst)
+	if l.last.IsZero() || l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// Allow 返回此刻是否允许一条消息通过，限流开始和结束时各记录一次日志
+func (l *rateLimiter) Allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	if l.tokens >= 1 {
+		if l.dropped > 0 {
+			log.Printf("🚦 限流解除，期间共丢弃 %d 条消息", l.dropped)
+			l.dropped = 0
+		}
+		l.tokens--
+		return true
+	}
+	if l.dropped == 0 {
+		log.Printf("🚦 消息过多，开始限流（每分钟 %.0f 条，突发 %.0f 条），超出的消息将被丢弃", l.rate*60, l.burst)
+	}
+	l.dropped++
+	return false
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// backoff 计算重连等待时间：从 min 开始每次翻倍，不超过 max，
+// 并在 [d/2, d) 内随机抖动，避免 broker 重启后所有桥接器同时重连
+type backoff struct {
+	min, max time.Duration
+	attempt  int
+}
+
+// Next 返回下一次重连前的等待时间
+func (b *backoff) Next() time.Duration {
+	d := b.min << b.attempt
+	if d <= 0 || d > b.max {
+		d = b.max
+	} else {
+		b.attempt++
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// Reset 在连接成功后重置退避
+func (b *backoff) Reset() {
+	b.attempt = 0
+}
+
+// subscribeRetries 为 subscribeWithRetry 的最多尝试次数
+const subscribeRetries = 5
+
+// subscribeWithRetry 订阅 topics，失败的主题按指数退避（1s 起，最长 30s）重试，
+// 最多尝试 subscribeRetries 次；连接断开或 ctx 结束时提前停止。返回最终仍失败的主题
+func subscribeWithRetry(ctx context.Context, client mqtt.Client, topics []string, qos byte, handler mqtt.MessageHandler, timeout time.Duration) []string {
+	b := &backoff{min: time.Second, max: 30 * time.Second}
+	failed := subscribeTopics(client, topics, qos, handler, timeout)
+	for n := 1; len(failed) > 0 && n < subscribeRetries; n++ {
+		delay := b.Next()
+		log.Printf("🔁 %d 个主题订阅失败，%v 后第 %d 次重试", len(failed), delay.Round(time.Millisecond), n)
+		select {
+		case <-ctx.Done():
+			return failed
+		case <-time.After(delay):
+		}
+		if !client.IsConnected() {
+			// 重连后 OnConnect 会重新订阅全部主题
+			return failed
+		}
+		failed = subscribeTopics(client, failed, qos, handler, timeout)
+	}
+	return failed
+}
+
+// startupJitter 返回 [0, max) 内的随机等待时间，max <= 0 时为 0；r 为 nil 时使用全局随机数
+func startupJitter(max time.Duration, r *rand.Rand) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+	if r == nil {
+		return time.Duration(rand.Int63n(int64(max)))
+	}
+	return time.Duration(r.Int63n(int64(max)))
+}
+
+// reconnectLoop 按指数退避反复调用 client.Connect，直到连接成功或 ctx 结束。
+// 取代 paho 自带的固定间隔重连，应在连接断开后于单独的 goroutine 中调用；
+// 每次尝试连接前调用 onAttempt（可为 nil），n 从 1 开始
+func reconnectLoop(ctx context.Context, client mqtt.Client, b *backoff, redact func(string) string, onAttempt func(n int)) {
+	for n := 1; ; n++ {
+		delay := b.Next()
+		log.Printf("🔁 第 %d 次重连将在 %v 后进行", n, delay.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if onAttempt != nil {
+			onAttempt(n)
+		}
+		// 单次连接的超时由 paho 的 ConnectTimeout 控制，token 总会完成
+		token := client.Connect()
+		token.Wait()
+		if err := token.Error(); err != nil {
+			log.Printf("⚠️ 第 %d 次重连失败: %s", n, redact(err.Error()))
+			continue
+		}
+		log.Printf("✅ 第 %d 次重连成功", n)
+		b.Reset()
+		return
+	}
+}
+
+// swappableClient 把调用转发给当前的 MQTT 客户端。paho 创建客户端后无法修改 broker 列表，
+// 热加载修改 broker 时用 Swap 换成连接新地址的客户端，持有它发布回执的地方不必更新
+type swappableClient struct {
+	mu     sync.RWMutex
+	client mqtt.Client
+	// ctx 在当前客户端被替换或 run 结束时取消，用于停止旧客户端的重连
+	ctx    context.Context
+	cancel context.CancelFunc
+	parent context.Context
+}
+
+func newSwappableClient(ctx context.Context, client mqtt.Client) *swappableClient {
+	s := &swappableClient{parent: ctx}
+	s.Swap(client)
+	return s
+}
+
+// Current 返回当前的客户端
+func (s *swappableClient) Current() mqtt.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.client
+}
+
+// Swap 换成 client 并返回原来的客户端（首次调用时为 nil），原客户端的 lifetime 随即结束
+func (s *swappableClient) Swap(client mqtt.Client) mqtt.Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	old := s.client
+	s.client = client
+	s.ctx, s.cancel = context.WithCancel(s.parent)
+	return old
+}
+
+// lifetime 返回 client 作为当前客户端的生命周期；client 已被替换时返回已结束的 context
+func (s *swappableClient) lifetime(client mqtt.Client) context.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if client == s.client {
+		return s.ctx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func (s *swappableClient) IsConnected() bool      { return s.Current().IsConnected() }
+func (s *swappableClient) IsConnectionOpen() bool { return s.Current().IsConnectionOpen() }
+func (s *swappableClient) Connect() mqtt.Token    { return s.Current().Connect() }
+func (s *swappableClient) Disconnect(quiesce uint) {
+	s.Current().Disconnect(quiesce)
+}
+
+func (s *swappableClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqtt.Token {
+	return s.Current().Publish(topic, qos, retained, payload)
+}
+
+func (s *swappableClient) Subscribe(topic string, qos byte, callback mqtt.MessageHandler) mqtt.Token {
+	return s.Current().Subscribe(topic, qos, callback)
+}
+
+func (s *swappableClient) SubscribeMultiple(filters map[string]byte, callback mqtt.MessageHandler) mqtt.Token {
+	return s.Current().SubscribeMultiple(filters, callback)
+}
+
+func (s *swappableClient) Unsubscribe(topics ...string) mqtt.Token {
+	return s.Current().Unsubscribe(topics...)
+}
+
+func (s *swappableClient) AddRoute(topic string, callback mqtt.MessageHandler) {
+	s.Current().AddRoute(topic, callback)
+}
+
+func (s *swappableClient) OptionsReader() mqtt.ClientOptionsReader {
+	return s.Current().OptionsReader()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const redactedMark = "******"
+
+// urlPasswordPattern 匹配 URL 中 scheme://user:password@ 的密码部分
+var urlPasswordPattern = regexp.MustCompile(`([a-zA-Z][a-zA-Z0-9+.-]*://[^:/@\s]*:)[^@\s]*@`)
+
+// redactCredentials 将 s 中出现的 secrets（如 MQTT 密码、Azure 密钥）以及
+// URL 中 user:password@ 形式的密码替换为 ******，用于记录连接错误等可能包含凭据的信息
+func redactCredentials(s string, secrets ...string) string {
+	for _, secret := range secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redactedMark)
+		}
+	}
+	return urlPasswordPattern.ReplaceAllString(s, "${1}"+redactedMark+"@")
+}
+
+// redactConfig 使用 cfg 中的凭据对 s 脱敏
+func redactConfig(cfg *Config, s string) string {
+	return redactCredentials(s, cfg.Password, cfg.AzureKey, cfg.ClientPFXPassword)
+}
+
+// warnIfWorldReadable 在配置文件对其他用户可读时输出警告（不影响加载）。
+// Windows 上文件权限位不反映真实 ACL，不做检查
+func warnIfWorldReadable(path string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if info.Mode().Perm()&0o004 != 0 {
+		log.Printf("⚠️ 配置文件 %q 对所有用户可读（权限 %v），其中的密码可能泄露，建议执行 chmod 600 或改用 TTS_PASSWORD 环境变量", path, info.Mode().Perm())
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchConfig 监视配置文件，文件被修改或替换后调用 onChange，ctx 结束时返回。
+// 监视的是所在目录而不是文件本身，编辑器先写临时文件再改名的保存方式也能被发现；
+// 短时间内的多次事件合并为一次（500ms）
+func watchConfig(ctx context.Context, path string, onChange func()) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		log.Printf("❌ 无法监视配置文件，热加载不可用: %v", err)
+		return
+	}
+	defer watcher.Close()
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	if err := watcher.Add(filepath.Dir(abs)); err != nil {
+		log.Printf("❌ 无法监视配置文件 %q，热加载不可用: %v", path, err)
+		return
+	}
+	log.Printf("👀 正在监视配置文件: %s", path)
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(ev.Name) == abs && ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				debounce = time.After(500 * time.Millisecond)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("⚠️ 监视配置文件出错: %v", err)
+		case <-debounce:
+			debounce = nil
+			onChange()
+		}
+	}
+}
+
+// keepRestartOnly 将 next 中只在启动时生效的字段恢复为 old 的值，
+// 返回其中被修改过的配置名，供热加载时提示需要重启。broker 列表由 reloadConfig 单独处理
+func keepRestartOnly(old, next *Config) []string {
+	var changed []string
+	keep(&changed, "username", &next.Username, old.Username)
+	keep(&changed, "password", &next.Password, old.Password)
+	keep(&changed, "client_id", &next.ClientID, old.ClientID)
+	keep(&changed, "manual_ack", &next.ManualAck, old.ManualAck)
+	keep(&changed, "protocol_version", &next.ProtocolVersion, old.ProtocolVersion)
+	keep(&changed, "ca_file", &next.CAFile, old.CAFile)
+	keep(&changed, "client_cert_file", &next.ClientCertFile, old.ClientCertFile)
+	keep(&changed, "client_key_file", &next.ClientKeyFile, old.ClientKeyFile)
+	keep(&changed, "client_pfx_file", &next.ClientPFXFile, old.ClientPFXFile)
+	keep(&changed, "client_pfx_password", &next.ClientPFXPassword, old.ClientPFXPassword)
+	keep(&changed, "insecure_skip_verify", &next.InsecureSkipVerify, old.InsecureSkipVerify)
+	keep(&changed, "reconnect_max_seconds", &next.ReconnectMaxSeconds, old.ReconnectMaxSeconds)
+	keep(&changed, "connect_timeout_seconds", &next.ConnectTimeoutSeconds, old.ConnectTimeoutSeconds)
+	keep(&changed, "status_topic", &next.StatusTopic, old.StatusTopic)
+	keep(&changed, "command_topic", &next.CommandTopic, old.CommandTopic)
+	keep(&changed, "events_topic", &next.EventsTopic, old.EventsTopic)
+	keep(&changed, "drop_topic", &next.DropTopic, old.DropTopic)
+	keep(&changed, "startup_jitter_ms", &next.StartupJitterMs, old.StartupJitterMs)
+	keep(&changed, "now_speaking_topic", &next.NowSpeakingTopic, old.NowSpeakingTopic)
+	keep(&changed, "gate_topic", &next.GateTopic, old.GateTopic)
+	keep(&changed, "will_topic", &next.WillTopic, old.WillTopic)
+	keep(&changed, "will_payload", &next.WillPayload, old.WillPayload)
+	keep(&changed, "will_retain", &next.WillRetain, old.WillRetain)
+	keep(&changed, "availability_topic", &next.AvailabilityTopic, old.AvailabilityTopic)
+	keep(&changed, "online_payload", &next.OnlinePayload, old.OnlinePayload)
+	keep(&changed, "unhealthy_payload", &next.UnhealthyPayload, old.UnhealthyPayload)
+	keep(&changed, "http_addr", &next.HTTPAddr, old.HTTPAddr)
+	keep(&changed, "speaker", &next.Speaker, old.Speaker)
+	keep(&changed, "powershell_path", &next.PowerShellPath, old.PowerShellPath)
+	keep(&changed, "azure_key", &next.AzureKey, old.AzureKey)
+	keep(&changed, "azure_region", &next.AzureRegion, old.AzureRegion)
+	keep(&changed, "azure_voice", &next.AzureVoice, old.AzureVoice)
+	keep(&changed, "player_command", &next.PlayerCommand, old.PlayerCommand)
+	keep(&changed, "audio_device", &next.AudioDevice, old.AudioDevice)
+	keep(&changed, "duck_media_percent", &next.DuckMediaPercent, old.DuckMediaPercent)
+	keep(&changed, "duck_command", &next.DuckCommand, old.DuckCommand)
+	keep(&changed, "duck_restore_command", &next.DuckRestoreCommand, old.DuckRestoreCommand)
+	keep(&changed, "voice_rotation_seed", &next.VoiceRotationSeed, old.VoiceRotationSeed)
+	keep(&changed, "voice_refresh_minutes", &next.VoiceRefreshMinutes, old.VoiceRefreshMinutes)
+	keep(&changed, "audio_topic", &next.AudioTopic, old.AudioTopic)
+	keep(&changed, "audio_chunk_size", &next.AudioChunkSize, old.AudioChunkSize)
+	keep(&changed, "cache_dir", &next.CacheDir, old.CacheDir)
+	keep(&changed, "cache_max_mb", &next.CacheMaxMB, old.CacheMaxMB)
+	keep(&changed, "queue_size", &next.QueueSize, old.QueueSize)
+	keep(&changed, "stats_interval_minutes", &next.StatsIntervalMinutes, old.StatsIntervalMinutes)
+	keep(&changed, "queue_drop_oldest", &next.QueueDropOldest, old.QueueDropOldest)
+	keep(&changed, "preempt", &next.Preempt, old.Preempt)
+	keep(&changed, "workers", &next.Workers, old.Workers)
+	keep(&changed, "log_format", &next.LogFormat, old.LogFormat)
+	keep(&changed, "max_log_size_mb", &next.MaxLogSizeMB, old.MaxLogSizeMB)
+	keep(&changed, "log_backups", &next.LogBackups, old.LogBackups)
+	return changed
+}
+
+func keep[T comparable](changed *[]string, name string, dst *T, old T) {
+	if *dst != old {
+		*changed = append(*changed, name)
+		*dst = old
+	}
+}
+
+// reloadConfig 应用热加载得到的新配置：朗读参数、规范化、超时、去重等立即生效，
+// 订阅主题或 QoS 变化时在线重新订阅，broker 列表变化时断开并重新连接到新的 broker；
+// 认证、TLS、后端等需要重启的配置保持不变并记录警告。
+// 返回已生效的配置名和需要重启才能生效的配置名
+func (b *bridge) reloadConfig(client mqtt.Client, next *Config) (changed, restartOnly []string) {
+	// 文件监视与 reload 命令可能同时触发
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	old := b.config()
+	restartOnly = keepRestartOnly(old, next)
+	switchBrokers := !slices.Equal(next.Brokers, old.Brokers)
+	if switchBrokers && b.switchBrokers == nil {
+		restartOnly = append([]string{"broker"}, restartOnly...)
+		next.Brokers = old.Brokers
+		switchBrokers = false
+	}
+	if len(restartOnly) > 0 {
+		log.Printf("⚠️ 以下配置需要重启才能生效: %s", strings.Join(restartOnly, ", "))
+	}
+	changed = changedFields(old, next)
+
+	b.queue.SetTimeout(time.Duration(next.TTSTimeoutSeconds) * time.Second)
+	b.queue.SetRetry(next.SpeakRetries, time.Duration(next.SpeakRetryDelayMs)*time.Millisecond, next.RetryOnTimeout)
+	b.queue.SetMaxAge(time.Duration(next.MaxAgeSeconds) * time.Second)
+	b.queue.SetChunking(time.Duration(next.ChunkPauseMs)*time.Millisecond, next.KeepChunksTogether)
+	b.queue.SetGap(time.Duration(next.InterUtteranceGapMs) * time.Millisecond)
+	b.queue.failures.SetThreshold(next.FailureThreshold)
+	b.dedup.SetWindow(time.Duration(next.DedupSeconds) * time.Second)
+	b.limit.Configure(next.RateLimitPerMinute, next.RateLimitBurst)
+	debugEnabled.Store(next.Debug)
+	b.cfg.Store(next)
+
+	// 切换 broker 后新客户端尚未连接，不会在这里重新订阅，连接成功后 OnConnect 按新配置订阅
+	if switchBrokers {
+		if err := b.switchBrokers(next.Brokers); err != nil {
+			log.Printf("❌ 无法切换 broker，继续使用原来的 broker: %v", err)
+			kept := *next
+			kept.Brokers = old.Brokers
+			b.cfg.Store(&kept)
+			changed = slices.DeleteFunc(changed, func(name string) bool { return name == "brokers" })
+		}
+	}
+	if (next.QoS != old.QoS || !slices.Equal(next.Topics, old.Topics)) && client.IsConnected() {
+		b.resubscribe(client, old, next)
+	}
+	if len(changed) > 0 {
+		log.Printf("🔄 配置已重新加载，已生效: %s", strings.Join(changed, ", "))
+	} else {
+		log.Println("🔄 配置已重新加载，没有变化")
+	}
+	return changed, restartOnly
+}
+
+// changedFields 返回 old 与 next 中值不同的字段，按配置文件中的键名（如 tts_timeout_seconds）
+func changedFields(old, next *Config) []string {
+	var changed []string
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < ov.NumField(); i++ {
+		if !reflect.DeepEqual(ov.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, configKey(ov.Type().Field(i).Name))
+		}
+	}
+	return changed
+}
+
+// configKey 将字段名转换为配置文件中的键名，缩写按一个词处理：
+// TTSTimeoutSeconds -> tts_timeout_seconds，ClientID -> client_id
+func configKey(field string) string {
+	field = strings.Replace(field, "QoS", "Qos", 1)
+	r := []rune(field)
+	var sb strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) && i > 0 && (unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(unicode.ToLower(c))
+	}
+	return sb.String()
+}
+
+// resubscribe 退订不再需要的主题并订阅新增的主题；QoS 变化时全部重新订阅。
+// 断线期间无需处理，重连后 OnConnect 会按新配置订阅
+func (b *bridge) resubscribe(client mqtt.Client, old, next *Config) {
+	var removed, added []string
+	for _, t := range old.Topics {
+		if next.QoS != old.QoS || !slices.Contains(next.Topics, t) {
+			removed = append(removed, t)
+		}
+	}
+	for _, t := range next.Topics {
+		if next.QoS != old.QoS || !slices.Contains(old.Topics, t) {
+			added = append(added, t)
+		}
+	}
+	if len(removed) > 0 {
+		token := client.Unsubscribe(removed...)
+		if !waitToken(token, next.subscribeTimeout()) || token.Error() != nil {
+			log.Printf("⚠️ 退订主题失败: %v", token.Error())
+		} else {
+			log.Printf("🔕 已退订: %s", strings.Join(removed, ", "))
+		}
+	}
+	if len(added) > 0 {
+		subscribeTopics(client, added, byte(next.QoS), b.onMessage, next.subscribeTimeout())
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newTestBridge 返回使用 cfg、不连接 MQTT 的桥接器
+func newTestBridge(cfg *Config) *bridge {
+	b := &bridge{queue: newSpeakQueue(cfg, NoopSpeaker{})}
+	b.cfg.Store(cfg)
+	return b
+}
+
+// writeConfigFile 把 content 写入临时目录下的 config.json 并返回路径
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReloadConfigReportsOnlyChangedFields(t *testing.T) {
+	path := writeConfigFile(t, `{"broker": "tcp://127.0.0.1:1883", "tts_timeout_seconds": 10}`)
+	load := func() *Config {
+		t.Helper()
+		cfg, err := resolveConfig(defaultConfig(), path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+	b := newTestBridge(load())
+
+	// 未配置 client_id 时每次合并都补全为同一个默认值，保存未修改的文件不应报告任何变化
+	changed, restart := b.reloadConfig(nil, load())
+	if len(changed) != 0 || len(restart) != 0 {
+		t.Errorf("配置未修改，却报告已生效 %v、需要重启 %v", changed, restart)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"broker": "tcp://127.0.0.1:1883", "tts_timeout_seconds": 20, "client_id": "tts-kitchen"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changed, restart = b.reloadConfig(nil, load())
+	if !slices.Equal(changed, []string{"tts_timeout_seconds"}) {
+		t.Errorf("已生效 %v，期望 [tts_timeout_seconds]", changed)
+	}
+	if !slices.Equal(restart, []string{"client_id"}) {
+		t.Errorf("需要重启 %v，期望 [client_id]", restart)
+	}
+	if got := b.config().ClientID; got != defaultClientID() {
+		t.Errorf("client_id 需要重启才能生效，当前为 %q", got)
+	}
+}
+
+func TestResolveConfigDefaultsClientID(t *testing.T) {
+	cfg, err := resolveConfig(defaultConfig(), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ClientID != defaultClientID() {
+		t.Errorf("client_id 为 %q，期望默认值 %q", cfg.ClientID, defaultClientID())
+	}
+}
+
+// 配置文件修改 broker 后，桥接器断开旧连接并连接到新的 broker，在新 broker 上宣告在线并朗读
+func TestReloadSwitchesBroker(t *testing.T) {
+	first, second := startBroker(t), startBroker(t)
+	content := func(broker string) string {
+		return fmt.Sprintf(`{
+			"broker": %q,
+			"topic": "test/tts",
+			"availability_topic": "test/tts/availability",
+			"voice_refresh_minutes": 0
+		}`, broker)
+	}
+	path := writeConfigFile(t, content(first.tcp))
+	load := func() (*Config, error) { return resolveConfig(defaultConfig(), path, nil) }
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	speaker := newRecordingSpeaker()
+	startBridgeWith(t, first, cfg, speaker, runDeps{ConfigPath: path, Reload: load})
+
+	online := second.subscribe(t, cfg.AvailabilityTopic, 1)
+	if err := os.WriteFile(path, []byte(content(second.tcp)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case pk := <-online:
+		if string(pk.Payload) != cfg.OnlinePayload {
+			t.Fatalf("新 broker 上的在线状态为 %q", pk.Payload)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("修改 broker 后未连接到新的 broker")
+	}
+
+	first.publish(t, "test/tts", "旧 broker")
+	second.publish(t, "test/tts", "新 broker")
+	if got := speaker.next(t); got.Text != "新 broker" {
+		t.Errorf("朗读 %q，期望只朗读新 broker 上的消息", got.Text)
+	}
+}
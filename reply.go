@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// replyTracker 汇总同一条消息拆分出的各段朗读结果，最后一段结束时发布一次完成消息到
+// ReplyTo。被更高优先级打断、stop 清空或队列满时丢弃的段落不会结束，此时不发布
+type replyTracker struct {
+	mu        sync.Mutex
+	remaining int
+	texts     []string
+	err       error
+	elapsed   time.Duration
+}
+
+// finish 记录一段的结果，所有段落都结束时返回 true 和汇总后的文本、第一个错误与总耗时
+func (t *replyTracker) finish(req speakRequest, err error, elapsed time.Duration) (done bool, text string, firstErr error, total time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining--
+	t.texts = append(t.texts, req.Text)
+	if t.err == nil {
+		t.err = err
+	}
+	t.elapsed += elapsed
+	return t.remaining == 0, strings.Join(t.texts, ""), t.err, t.elapsed
+}
+
+// validReplyTopic 检查 reply_to 是可以发布的主题：非空且不含通配符
+func validReplyTopic(topic string) bool {
+	return topic != "" && !strings.ContainsAny(topic, "+#")
+}
+
+// trackReply 在请求带 reply_to 时为拆分后的所有段落挂上同一个 replyTracker
+func trackReply(parts []speakRequest) {
+	if len(parts) == 0 || parts[0].ReplyTo == "" {
+		return
+	}
+	t := &replyTracker{remaining: len(parts)}
+	for i := range parts {
+		parts[i].reply = t
+	}
+}
+
+// onReply 在每段朗读结束后调用，消息的最后一段结束时向 ReplyTo 发布完成消息
+func (b *bridge) onReply(req speakRequest, err error, elapsed time.Duration) {
+	if req.reply == nil {
+		return
+	}
+	if done, text, first, total := req.reply.finish(req, err, elapsed); done {
+		req.Text = text
+		b.publishReply(req, first, total)
+	}
+}
+
+// publishReply 向 req.ReplyTo 发布与状态回执格式相同的完成消息，附带 correlation_id
+func (b *bridge) publishReply(req speakRequest, err error, elapsed time.Duration) {
+	if b.client == nil || req.ReplyTo == "" {
+		return
+	}
+	debugf("↩️ 回复到 %s: %.50q", req.ReplyTo, req.Text)
+	publishStatus(b.client, req.ReplyTo, newSpeakStatus(req, err, elapsed))
+}
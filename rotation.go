@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+// weightedVoice 是 VoiceRotation 中的一项，Weight 越大被选中的概率越高
+type weightedVoice struct {
+	Voice  string
+	Weight float64
+}
+
+// voicePicker 按权重随机选择语音；installed 为已安装语音的名称（小写），
+// nil 表示后端无法列出语音，此时不做过滤
+type voicePicker struct {
+	mu        sync.Mutex
+	rng       *rand.Rand
+	installed map[string]bool
+}
+
+// voiceRotation 是进程内唯一的语音轮换选择器，未调用 Seed 时按当前时间播种
+var voiceRotation = &voicePicker{rng: rand.New(rand.NewPCG(uint64(time.Now().UnixNano()), 0))}
+
+// Seed 以固定的种子重置随机数，相同的种子得到相同的选择序列
+func (p *voicePicker) Seed(seed int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rng = rand.New(rand.NewPCG(uint64(seed), 0))
+}
+
+// SetInstalled 记录已安装的语音，之后选择时跳过未安装的语音
+func (p *voicePicker) SetInstalled(voices []voiceInfo) {
+	installed := make(map[string]bool, len(voices))
+	for _, v := range voices {
+		if v.Enabled {
+			installed[strings.ToLower(v.Name)] = true
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.installed = installed
+}
+
+// Installed 判断 voice 是否已安装，无法列出语音时总是返回 true
+func (p *voicePicker) Installed(voice string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.installed == nil || p.installed[strings.ToLower(voice)]
+}
+
+// Pick 从 list 中按权重随机选择一个已安装的语音，没有可选的语音时返回空（使用默认语音）
+func (p *voicePicker) Pick(list []weightedVoice) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total float64
+	for _, v := range list {
+		if p.usable(v) {
+			total += v.Weight
+		}
+	}
+	if total <= 0 {
+		return ""
+	}
+	r := p.rng.Float64() * total
+	for _, v := range list {
+		if !p.usable(v) {
+			continue
+		}
+		if r -= v.Weight; r < 0 {
+			return v.Voice
+		}
+	}
+	// 浮点误差时落在最后一个可用的语音上
+	for i := len(list) - 1; i >= 0; i-- {
+		if p.usable(list[i]) {
+			return list[i].Voice
+		}
+	}
+	return ""
+}
+
+func (p *voicePicker) usable(v weightedVoice) bool {
+	return v.Weight > 0 && (p.installed == nil || p.installed[strings.ToLower(v.Voice)])
+}
+
+// checkVoiceRotation 在后端支持时列出已安装的语音，警告并跳过 list 中未安装的语音
+func checkVoiceRotation(ctx context.Context, speaker Speaker, list []weightedVoice) {
+	if err := installedVoices.Refresh(ctx, speaker); err != nil {
+		log.Printf("⚠️ 无法确认轮换语音是否已安装: %v", err)
+		return
+	}
+	for _, v := range list {
+		if !voiceRotation.Installed(v.Voice) {
+			log.Printf("⚠️ 轮换语音 %q 未安装，不会被选中", v.Voice)
+		}
+	}
+}
+
+// jsonVoiceRotation 读取 raw[key] 中的 [{"voice":"...","weight":2}, ...]，
+// 缺少 voice 的项被忽略，未指定 weight 时为 1
+func jsonVoiceRotation(raw map[string]interface{}, key string, dst *[]weightedVoice) {
+	items, ok := raw[key].([]interface{})
+	if !ok {
+		return
+	}
+	var list []weightedVoice
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			log.Printf("⚠️ %s[%d] 不是对象，已忽略", key, i)
+			continue
+		}
+		v := weightedVoice{Weight: 1}
+		jsonString(m, "voice", &v.Voice)
+		if w, ok := m["weight"].(float64); ok {
+			v.Weight = w
+		}
+		if v.Voice == "" {
+			log.Printf("⚠️ %s[%d] 缺少 voice，已忽略", key, i)
+			continue
+		}
+		list = append(list, v)
+	}
+	*dst = list
+}
+
+// validateVoiceRotation 检查语音名称和权重
+func validateVoiceRotation(list []weightedVoice) error {
+	for i, v := range list {
+		if !isValidVoiceName(v.Voice) {
+			return fmt.Errorf("voice_rotation[%d] 的语音名称 %q 含有非法字符", i, v.Voice)
+		}
+		if v.Weight < 0 {
+			return fmt.Errorf("voice_rotation[%d] 的 weight 不能为负数", i)
+		}
+	}
+	return nil
+}
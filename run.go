@@ -0,0 +1,379 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// runDeps 是 run 在配置和 TTS 后端之外的依赖，零值表示不热加载配置、使用 paho 客户端
+type runDeps struct {
+	// ConfigPath 非空且 Reload 不为 nil 时监视该文件，变化后调用 Reload 重新合并配置
+	ConfigPath string
+	Reload     func() (*Config, error)
+
+	// NewClient 创建 MQTT 客户端，nil 时使用 mqtt.NewClient（protocol_version 为 5 时使用 newV5Client）
+	NewClient func(*mqtt.ClientOptions) mqtt.Client
+
+	// Rand 为首次连接前随机等待（StartupJitterMs）使用的随机数，nil 时使用全局随机数；
+	// 可传入固定种子的 rand.New(rand.NewSource(seed)) 得到确定的等待时间
+	Rand *rand.Rand
+}
+
+// run 创建朗读队列并连接 MQTT，按 cfg 订阅主题、发布在线状态和回执，
+// 阻塞到 ctx 结束后退订、等待正在进行的朗读终止并断开连接。
+// 首次连接失败（如 broker 尚未启动）时与断线后一样按退避重试，不会返回；
+// 只有 TLS 配置错误会返回，运行期间的错误只记录日志
+func run(ctx context.Context, cfg *Config, speaker Speaker, deps runDeps) error {
+	queue := newSpeakQueue(cfg, speaker)
+	go queue.reportDepth(ctx, time.Minute)
+	if cfg.StatsIntervalMinutes > 0 {
+		interval := time.Duration(cfg.StatsIntervalMinutes) * time.Minute
+		go metrics.logStats(ctx, interval)
+		log.Printf("📊 每 %v 记录一次统计摘要", interval)
+	}
+	log.Printf("📋 朗读队列容量: %d", cfg.QueueSize)
+	if queue.workers > 1 {
+		log.Printf("👥 并发朗读 worker 数量: %d", queue.workers)
+		if cfg.OutputDir == "" {
+			log.Println("⚠️ 多个 worker 同时输出到默认音频设备时语音会重叠，建议配合 output_dir 使用")
+		}
+	}
+	if queue.preempt {
+		log.Println("⏭️ 已启用抢占：高优先级消息会打断当前朗读")
+	}
+	if queue.maxAge > 0 {
+		log.Printf("⌛ 排队超过 %v 的消息不再朗读", queue.maxAge)
+	}
+	if queue.timeout > 0 {
+		log.Printf("⏱️ 单条朗读超时: %v", queue.timeout)
+	} else {
+		log.Println("⏱️ 单条朗读不限时")
+	}
+
+	b := &bridge{queue: queue}
+	b.state.started = time.Now()
+	b.cfg.Store(cfg)
+	b.dedup.SetWindow(time.Duration(cfg.DedupSeconds) * time.Second)
+	if cfg.DedupSeconds > 0 {
+		log.Printf("🔁 %ds 内重复的文本只朗读一次", cfg.DedupSeconds)
+	}
+	b.limit.Configure(cfg.RateLimitPerMinute, cfg.RateLimitBurst)
+	if cfg.RateLimitPerMinute > 0 {
+		log.Printf("🚦 限流: 每分钟 %d 条", cfg.RateLimitPerMinute)
+	}
+	if cfg.QuietHours != "" {
+		log.Printf("🌙 安静时段: %s", cfg.QuietHours)
+	}
+	if cfg.GateTopic != "" {
+		log.Printf("🚪 门控: %s 为 %q 时才朗读", cfg.GateTopic, cfg.GateMatchValue)
+	}
+	f := b.onMessage
+
+	// 启动 MQTT 客户端
+	opts := mqtt.NewClientOptions()
+	for _, broker := range cfg.Brokers {
+		opts.AddBroker(broker)
+	}
+	if len(cfg.Brokers) > 1 {
+		log.Printf("🔀 broker 故障转移顺序: %s", redactConfig(cfg, strings.Join(cfg.Brokers, ", ")))
+	}
+	// paho 按顺序尝试各个 broker，记录最近一次尝试的地址，连接成功时即为实际连接的 broker
+	var currentBroker atomic.Value
+	currentBroker.Store(cfg.Brokers[0])
+	opts.SetConnectionAttemptHandler(func(broker *url.URL, tlsCfg *tls.Config) *tls.Config {
+		currentBroker.Store(broker.String())
+		return tlsCfg
+	})
+	// 未配置 client_id 时 resolveConfig 已补全为 defaultClientID()
+	clientIDDefaulted := cfg.ClientID == defaultClientID()
+	opts.SetClientID(cfg.ClientID)
+	if cfg.ProtocolVersion == 5 {
+		log.Println("🆕 使用 MQTT 5，消息的 user properties（voice、rate、priority）优先于消息体中的字段")
+	} else if cfg.ProtocolVersion != 0 {
+		opts.SetProtocolVersion(uint(cfg.ProtocolVersion))
+	}
+	log.Printf("🪪 MQTT 客户端 ID: %s", cfg.ClientID)
+	if cfg.ManualAck {
+		opts.SetAutoAckDisabled(true)
+		opts.SetCleanSession(false)
+		log.Println("🤝 手动确认：消息入队后才确认，未入队的消息由 broker 重发")
+		if clientIDDefaulted {
+			log.Println("⚠️ manual_ack 未设置固定的 client_id，重启后无法恢复会话，未确认的消息将丢失")
+		}
+	}
+	// 不使用 paho 的固定间隔重连，断开后由 reconnectLoop 按指数退避加抖动重连
+	opts.SetAutoReconnect(false)
+	maxInterval := time.Duration(cfg.ReconnectMaxSeconds) * time.Second
+	if maxInterval < time.Second {
+		maxInterval = time.Second
+	}
+	log.Printf("🔁 断线重连间隔: 1s ~ %v（随机抖动）", maxInterval)
+	// paho 的 ConnectTimeout 为 0 时同样表示不限时
+	opts.SetConnectTimeout(cfg.connectTimeout())
+	log.Printf("⏱️ 连接超时: %s，订阅超时: %s", timeoutText(cfg.connectTimeout()), timeoutText(cfg.subscribeTimeout()))
+
+	events := &eventPublisher{topic: cfg.EventsTopic}
+	if cfg.EventsTopic != "" {
+		log.Printf("📰 连接事件主题: %s", cfg.EventsTopic)
+	}
+	// client 转发给当前的 MQTT 客户端，热加载修改 broker 时替换（见 switchBrokers）
+	var client *swappableClient
+	// connectedBefore 区分首次连接与断线后的重新连接
+	var connectedBefore atomic.Bool
+	// 首次连接和自动重连后都会调用，统一在这里（重新）订阅所有主题
+	opts.SetOnConnectHandler(func(c mqtt.Client) {
+		if client.lifetime(c).Err() != nil {
+			// 重连期间热加载换用了新的 broker，旧客户端不再使用
+			c.Disconnect(250)
+			return
+		}
+		b.state.connected.Store(true)
+		reconnected := connectedBefore.Swap(true)
+		broker := redactConfig(cfg, currentBroker.Load().(string))
+		log.Printf("🔌 MQTT 连接成功（%s），正在订阅主题...", broker)
+		events.Publish(c, eventConnected, broker, "", 0)
+		// 热加载可能修改了主题，按当前配置订阅
+		// 订阅失败时按退避重试，不退出进程：broker 重启期间的短暂失败很常见
+		cur := b.config()
+		failed := subscribeWithRetry(ctx, c, cur.Topics, byte(cur.QoS), f, cur.subscribeTimeout())
+		if len(failed) > 0 {
+			log.Printf("❌ 多次重试后仍无法订阅: %s，将在下次重连时再试", strings.Join(failed, ", "))
+		}
+		if cfg.CommandTopic != "" {
+			subscribeWithRetry(ctx, c, []string{cfg.CommandTopic}, byte(cur.QoS), b.onCommand, cur.subscribeTimeout())
+		}
+		if cfg.GateTopic != "" {
+			subscribeWithRetry(ctx, c, []string{cfg.GateTopic}, byte(cur.QoS), b.onGate, cur.subscribeTimeout())
+		}
+		// 订阅全部成功后才宣告在线，避免订阅者在桥接器开始接收前就看到 online
+		if topic := cfg.availabilityTopic(); topic != "" && len(failed) == 0 {
+			payload := cfg.OnlinePayload
+			if unhealthy, _ := queue.failures.Status(); unhealthy {
+				payload = cfg.UnhealthyPayload
+			}
+			publishAvailability(c, topic, payload)
+		}
+		if reconnected && cur.AnnounceReconnect {
+			b.announce(cur.ReconnectPhrase)
+		}
+	})
+
+	redact := func(s string) string { return redactConfig(cfg, s) }
+	// 每个客户端各自退避；客户端被替换后重连随即停止，由新客户端的重连接替
+	reconnect := func(c mqtt.Client) {
+		reconnectLoop(client.lifetime(c), c, &backoff{min: time.Second, max: maxInterval}, redact, func(n int) {
+			events.Publish(c, eventReconnecting, redact(currentBroker.Load().(string)), "", n)
+		})
+	}
+	// 可选：添加连接丢失回调用于调试
+	opts.SetConnectionLostHandler(func(c mqtt.Client, err error) {
+		b.state.connected.Store(false)
+		reason := redact(fmt.Sprint(err))
+		logEvent("mqtt_disconnected", []slog.Attr{slog.String("error", reason)}, "⚠️ MQTT 连接已断开: %s", reason)
+		events.Publish(c, eventDisconnected, redact(currentBroker.Load().(string)), reason, 0)
+		go reconnect(c)
+	})
+
+	if cfg.WillTopic != "" {
+		opts.SetWill(cfg.WillTopic, cfg.WillPayload, 1, cfg.WillRetain)
+		log.Printf("🪦 遗嘱消息: %s -> %q", cfg.WillTopic, cfg.WillPayload)
+	}
+
+	if cfg.Username != "" {
+		opts.SetUsername(cfg.Username)
+	}
+	if cfg.Password != "" {
+		opts.SetPassword(cfg.Password)
+	}
+
+	if slices.ContainsFunc(cfg.Brokers, isTLSBroker) {
+		tlsCfg, err := newTLSConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("TLS 配置错误: %w", err)
+		}
+		opts.SetTLSConfig(tlsCfg)
+		if cfg.InsecureSkipVerify {
+			log.Println("⚠️ 已跳过 TLS 服务端证书校验，请勿在生产环境使用")
+		}
+		log.Println("🔒 已启用 TLS 连接")
+	}
+
+	newClient := deps.NewClient
+	if newClient == nil {
+		newClient = mqtt.NewClient
+		if cfg.ProtocolVersion == 5 {
+			newClient = newV5Client
+		}
+	}
+	client = newSwappableClient(ctx, newClient(opts))
+	if audio, ok := speaker.(*MQTTAudioSpeaker); ok {
+		audio.client = client
+	}
+	b.client = client
+
+	// 热加载修改 broker 列表时，用新地址创建客户端替换当前客户端，断开旧连接后由 reconnectLoop
+	// 连接新的 broker，连接成功后 OnConnect 照常订阅主题并宣告在线
+	b.switchBrokers = func(brokers []string) error {
+		next := *opts
+		next.Servers = nil
+		for _, broker := range brokers {
+			next.AddBroker(broker)
+		}
+		if next.TLSConfig == nil && slices.ContainsFunc(brokers, isTLSBroker) {
+			tlsCfg, err := newTLSConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("TLS 配置错误: %w", err)
+			}
+			next.SetTLSConfig(tlsCfg)
+		}
+		c := newClient(&next)
+		currentBroker.Store(brokers[0])
+		old := client.Swap(c)
+		b.state.connected.Store(false)
+		old.Disconnect(250)
+		log.Printf("🔀 broker 已修改，正在连接: %s", redactConfig(cfg, strings.Join(brokers, ", ")))
+		go reconnect(c)
+		return nil
+	}
+
+	// 每条朗读结束后发布状态回执，消息带 reply_to 时另外回复到该主题
+	queue.onResult = func(req speakRequest, err error, elapsed time.Duration) {
+		publishStatus(client, cfg.StatusTopic, newSpeakStatus(req, err, elapsed))
+		b.onReply(req, err, elapsed)
+	}
+	if cfg.StatusTopic != "" {
+		log.Printf("📣 朗读回执主题: %s", cfg.StatusTopic)
+	}
+	if cfg.NowSpeakingTopic != "" {
+		queue.onSpeaking = nowSpeakingPublisher(client, cfg.NowSpeakingTopic)
+		log.Printf("🎙️ 正在朗读主题: %s", cfg.NowSpeakingTopic)
+	}
+	if cfg.DropTopic != "" {
+		// 丢弃大多发生在 MQTT 回调中，等待发布确认会阻塞 paho 分发后续消息，放到单独的 goroutine
+		queue.onDrop = func(req speakRequest, reason string) {
+			go publishStatus(client, cfg.DropTopic, newDropEvent(req, reason))
+		}
+		log.Printf("🗑️ 丢弃事件主题: %s", cfg.DropTopic)
+	}
+	// 连续失败达到阈值时宣告不健康并尝试补救，恢复后重新宣告在线
+	queue.failures.onUnhealthy = func(ctx context.Context, count int) {
+		if topic := cfg.availabilityTopic(); topic != "" && client.IsConnected() {
+			publishAvailability(client, topic, cfg.UnhealthyPayload)
+		}
+		runRemediation(ctx, b.config().RemediationCommand)
+	}
+	queue.failures.onRecovered = func() {
+		if topic := cfg.availabilityTopic(); topic != "" && client.IsConnected() {
+			publishAvailability(client, topic, cfg.OnlinePayload)
+		}
+	}
+	if cfg.FailureThreshold > 0 {
+		log.Printf("🩺 连续失败 %d 次判定为不健康", cfg.FailureThreshold)
+	}
+	// 列出已安装的语音，用于检查消息指定的语音；PowerShell 枚举较慢，不阻塞启动
+	if cfg.VoiceRefreshMinutes > 0 {
+		go b.refreshVoices(ctx, speaker, time.Duration(cfg.VoiceRefreshMinutes)*time.Minute)
+	} else if _, known := installedVoices.Lookup(""); !known {
+		go b.refreshVoicesOnce(ctx, speaker)
+	}
+	if cfg.RetryMissingVoice {
+		log.Println("🗣️ 指定的语音未安装时等待安装后再朗读")
+	}
+	if cfg.HTTPAddr != "" {
+		go runHTTPServer(ctx, cfg.HTTPAddr, newHTTPHandler(b))
+	}
+
+	// 配置文件变化时重新合并全部来源并校验，失败时保留旧配置
+	if deps.ConfigPath != "" && deps.Reload != nil {
+		b.reload = deps.Reload
+		go watchConfig(ctx, deps.ConfigPath, func() {
+			next, err := deps.Reload()
+			if err != nil {
+				log.Printf("❌ 配置重新加载失败，继续使用旧配置: %v", err)
+				return
+			}
+			b.reloadConfig(client, next)
+		})
+	}
+
+	workerDone := make(chan struct{})
+	go func() {
+		queue.run(ctx)
+		close(workerDone)
+	}()
+
+	if d := startupJitter(time.Duration(cfg.StartupJitterMs)*time.Millisecond, deps.Rand); d > 0 {
+		log.Printf("⏳ 随机等待 %v 后连接 MQTT Broker（startup_jitter_ms=%d），避免大量实例同时连接", d.Round(time.Millisecond), cfg.StartupJitterMs)
+		select {
+		case <-ctx.Done():
+			<-workerDone
+			return nil
+		case <-time.After(d):
+		}
+	}
+
+	// 服务可能先于 broker 启动，首次连接失败时同样按退避加抖动重试，直到连接成功或退出
+	token := client.Connect()
+	if !waitToken(token, cfg.connectTimeout()) {
+		log.Printf("⚠️ 连接 MQTT Broker 超时（%v），稍后重试", cfg.connectTimeout())
+		reconnect(client.Current())
+	} else if err := token.Error(); err != nil {
+		log.Printf("⚠️ 无法连接到 MQTT Broker: %s，稍后重试", redact(err.Error()))
+		reconnect(client.Current())
+	}
+	if ctx.Err() != nil {
+		log.Println("🛑 收到退出信号，连接 MQTT Broker 前退出")
+		<-workerDone
+		return nil
+	}
+
+	connected := redactConfig(cfg, currentBroker.Load().(string))
+	logEvent("mqtt_connected", []slog.Attr{slog.String("broker", connected)}, "✅ 已连接 MQTT Broker: %s", connected)
+	// 只记录用户名，密码不会写入日志
+	if cfg.Username != "" {
+		log.Printf("👤 使用用户名: %s", cfg.Username)
+	}
+	log.Printf("🎧 正在监听主题: %s", strings.Join(cfg.Topics, ", "))
+	log.Println("💡 示例:")
+	log.Println(`   tts-mqtt.exe -b tcp://192.168.1.100:1883 -t my/tts -u user -p pass`)
+	log.Println(`   tts-mqtt.exe -c config.json`)
+
+	<-ctx.Done()
+	log.Println("🛑 收到退出信号，正在关闭...")
+
+	// 先退订，不再接收新消息
+	unsubscribe := b.config().Topics
+	if cfg.CommandTopic != "" {
+		unsubscribe = append(unsubscribe[:len(unsubscribe):len(unsubscribe)], cfg.CommandTopic)
+	}
+	if cfg.GateTopic != "" {
+		unsubscribe = append(unsubscribe[:len(unsubscribe):len(unsubscribe)], cfg.GateTopic)
+	}
+	token = client.Unsubscribe(unsubscribe...)
+	if !token.WaitTimeout(2*time.Second) || token.Error() != nil {
+		log.Printf("⚠️ 退订主题失败: %v", token.Error())
+	}
+	// 等待 worker 终止正在进行的朗读
+	<-workerDone
+	// 正常断开时 broker 不会发布遗嘱，主动发布离线状态
+	if topic := cfg.availabilityTopic(); topic != "" {
+		publishAvailability(client, topic, cfg.WillPayload)
+	}
+	if cfg.WillTopic != "" && cfg.WillTopic != cfg.availabilityTopic() {
+		publishMessage(client, cfg.WillTopic, cfg.WillRetain, []byte(cfg.WillPayload))
+	}
+	client.Disconnect(250)
+	log.Println("👋 已断开 MQTT 连接，程序退出")
+	return nil
+}
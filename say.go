@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+)
+
+// DarwinSpeaker 使用 macOS 自带的 /usr/bin/say 朗读，便于在 Mac 上本地调试
+type DarwinSpeaker struct{}
+
+func (DarwinSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	log.Printf("🔊 尝试朗读文本 (长度=%d): %.50q", len(text), text)
+
+	// say 不支持 SSML，只朗读其中的文本
+	if opts.SSML {
+		text = ssmlToText(text)
+	}
+	// say 没有音量参数，使用内嵌命令 [[volm x]] 设置（0.0..1.0）
+	if opts.Volume != 100 {
+		text = fmt.Sprintf("[[volm %.2f]] %s", float64(opts.Volume)/100, text)
+	}
+
+	args := []string{"-r", fmt.Sprint(wordsPerMinute(opts.Rate))}
+	if opts.Voice != "" {
+		args = append(args, "-v", opts.Voice)
+	}
+	if opts.OutputFile != "" {
+		args = append(args, "-o", opts.OutputFile, "--file-format=WAVE", "--data-format=LEI16@22050")
+	}
+	// 文本通过 stdin 传入，避免以 - 开头的文本被当作参数
+	args = append(args, "-f", "-")
+
+	cmd := exec.CommandContext(ctx, "/usr/bin/say", args...)
+	cmd.Stdin = strings.NewReader(text)
+	return runTTSCommand(ctx, "say", cmd)
+}
+
+// wordsPerMinute 将 System.Speech 的语速 -10..10 映射为每分钟词数（0 对应 175）
+func wordsPerMinute(rate int) int {
+	return 175 + rate*15
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// selfTestText 是自检时合成的短语
+const selfTestText = "语音自检"
+
+// runSelfTest 将一段短语合成到临时 .wav 文件并检查文件非空，
+// 用于在依赖桥接器播报告警之前发现 System.Speech 缺失、默认语音损坏等问题
+func runSelfTest(ctx context.Context, speaker Speaker, timeout time.Duration) error {
+	f, err := os.CreateTemp("", "tts-selftest-*.wav")
+	if err != nil {
+		return fmt.Errorf("无法创建临时音频文件: %w", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	if err := speaker.Speak(ctx, selfTestText, speakOptions{Volume: 100, OutputFile: f.Name()}); err != nil {
+		return fmt.Errorf("TTS 合成失败: %w", err)
+	}
+	info, err := os.Stat(f.Name())
+	if err != nil {
+		return fmt.Errorf("无法读取合成结果: %w", err)
+	}
+	// 只有 44 字节的 WAV 头说明没有合成出任何音频
+	if info.Size() <= 44 {
+		return fmt.Errorf("合成结果为空（%d 字节）", info.Size())
+	}
+	log.Printf("✅ TTS 自检通过：%d 字节，耗时 %v", info.Size(), time.Since(start))
+	return nil
+}
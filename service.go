@@ -0,0 +1,18 @@
+package main
+
+import "strings"
+
+// serviceArgs 从命令行参数中去掉服务安装相关的参数，其余参数原样作为服务的启动参数。
+// 服务以程序所在目录为工作目录，参数中的相对路径按该目录解析
+func serviceArgs(args []string) []string {
+	var out []string
+	for _, arg := range args {
+		name, _, _ := strings.Cut(arg, "=")
+		switch name {
+		case "--install-service", "--uninstall-service":
+			continue
+		}
+		out = append(out, arg)
+	}
+	return out
+}
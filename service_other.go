@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+var errServiceUnsupported = errors.New("只有 Windows 支持以服务方式运行，其他系统请使用 systemd 等工具")
+
+// startService 在非 Windows 系统上总是以普通进程运行
+func startService(name string) (context.Context, func()) {
+	return context.Background(), func() {}
+}
+
+func installService(name string, args []string) error {
+	return errServiceUnsupported
+}
+
+func uninstallService(name string) error {
+	return errServiceUnsupported
+}
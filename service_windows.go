@@ -0,0 +1,122 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// serviceHandler 响应服务控制管理器（SCM）的请求：停止或关机时取消 ctx，
+// 等主流程退出（done 关闭）后再报告已停止
+type serviceHandler struct {
+	cancel context.CancelFunc
+	done   <-chan struct{}
+}
+
+func (h serviceHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+	for {
+		select {
+		case <-h.done:
+			s <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Println("🛑 收到服务停止请求，正在退出...")
+				s <- svc.Status{State: svc.StopPending}
+				h.cancel()
+				<-h.done
+				return false, 0
+			}
+		}
+	}
+}
+
+// startService 在由 SCM 启动时注册服务并返回停止请求时取消的 ctx，
+// 主流程退出前需调用 finish；以控制台方式运行时返回 context.Background()。
+// 服务的工作目录是 System32，因此先切换到程序所在目录，使相对路径的配置和日志照常可用
+func startService(name string) (context.Context, func()) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return context.Background(), func() {}
+	}
+	if exe, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(exe))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := svc.Run(name, serviceHandler{cancel: cancel, done: done}); err != nil {
+			log.Printf("❌ 服务运行失败: %v", err)
+		}
+		cancel()
+	}()
+	return ctx, func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// installService 将当前程序注册为开机自动启动的服务，args 为服务启动时的命令行参数
+func installService(name string, args []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("无法连接服务管理器（需要管理员权限）: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("服务 %s 已存在", name)
+	}
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: name,
+		Description: "MQTT 文字转语音桥接器",
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return fmt.Errorf("创建服务失败: %w", err)
+	}
+	defer s.Close()
+	log.Printf("✅ 已安装服务 %s: %s %s", name, exe, strings.Join(args, " "))
+	return nil
+}
+
+// uninstallService 删除 installService 注册的服务
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("无法连接服务管理器（需要管理员权限）: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("服务 %s 不存在: %w", name, err)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("删除服务失败: %w", err)
+	}
+	log.Printf("✅ 已卸载服务 %s", name)
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// severitySettings 是某个告警级别的朗读参数，消息带相应的 severity 且本身未指定时生效，
+// 发布方不必知道语音名称
+type severitySettings struct {
+	Voice    string
+	Rate     *int
+	Volume   *int
+	Priority *int
+}
+
+// applySeverity 按 p.Severity（不区分大小写）补全 p 中未指定的 voice、rate、volume 和 priority，
+// 未知的级别保持默认
+func applySeverity(m map[string]severitySettings, p ttsPayload) ttsPayload {
+	if p.Severity == "" || len(m) == 0 {
+		return p
+	}
+	s, ok := m[strings.ToLower(strings.TrimSpace(p.Severity))]
+	if !ok {
+		debugf("🚨 未配置告警级别 %q，使用默认朗读参数", p.Severity)
+		return p
+	}
+	if p.Voice == "" {
+		p.Voice = s.Voice
+	}
+	if p.Rate == nil {
+		p.Rate = s.Rate
+	}
+	if p.Volume == nil {
+		p.Volume = s.Volume
+	}
+	if p.Priority == 0 && s.Priority != nil {
+		p.Priority = *s.Priority
+	}
+	return p
+}
+
+// jsonSeverities 读取 raw[key] 中按告警级别的朗读参数，级别名不区分大小写，如
+//
+//	"severities": {"critical": {"voice": "...", "rate": 3, "volume": 100, "priority": 10}}
+func jsonSeverities(raw map[string]interface{}, key string, dst *map[string]severitySettings) {
+	obj, ok := raw[key].(map[string]interface{})
+	if !ok {
+		return
+	}
+	m := make(map[string]severitySettings, len(obj))
+	for name, v := range obj {
+		item, ok := v.(map[string]interface{})
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Printf("⚠️ %s 中的 %q 不是对象，已忽略", key, name)
+			continue
+		}
+		var s severitySettings
+		jsonString(item, "voice", &s.Voice)
+		for field, dst := range map[string]**int{"rate": &s.Rate, "volume": &s.Volume, "priority": &s.Priority} {
+			if _, ok := item[field].(float64); ok {
+				*dst = new(int)
+				jsonInt(item, field, *dst)
+			}
+		}
+		m[strings.ToLower(strings.TrimSpace(name))] = s
+	}
+	*dst = m
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Speaker 将文本转换为语音。实现需在 ctx 结束时尽快返回。
+type Speaker interface {
+	Speak(ctx context.Context, text string, opts speakOptions) error
+}
+
+// PowerShellSpeaker 通过 PowerShell 调用 System.Speech 朗读（Windows）
+type PowerShellSpeaker struct{}
+
+// powerShellPath 为 PowerShell 可执行文件（Config.PowerShellPath），启动时设置一次，
+// 朗读、列出语音和 Windows 上播放 .wav 共用
+var powerShellPath = "powershell"
+
+// powerShellCommand 创建以 -Command 执行 script 的 PowerShell 进程，ctx 结束时终止
+func powerShellCommand(ctx context.Context, script string) *exec.Cmd {
+	return exec.CommandContext(ctx, powerShellPath, "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+func (PowerShellSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	return speakText(ctx, text, opts)
+}
+
+// newSpeaker 按 cfg.Speaker 创建 Speaker；azure 后端失败时回退到本机默认后端
+func newSpeaker(cfg *Config) (Speaker, error) {
+	if !strings.EqualFold(cfg.Speaker, "azure") {
+		return newLocalSpeaker(cfg.Speaker)
+	}
+	if cfg.AzureKey == "" || cfg.AzureRegion == "" {
+		return nil, errors.New("azure 后端需要同时配置 azure_key 和 azure_region")
+	}
+	fallback, err := newLocalSpeaker("")
+	if err != nil {
+		return nil, err
+	}
+	return &AzureSpeaker{
+		Key:          cfg.AzureKey,
+		Region:       cfg.AzureRegion,
+		DefaultVoice: cfg.AzureVoice,
+		Fallback:     fallback,
+	}, nil
+}
+
+// newOutputSpeaker 创建 TTS 后端并按配置包装播放器、缓存和音量闪避，或改为发布音频到 MQTT。
+// DryRun 时在自检和各层包装之前换成 NoopSpeaker，不会调用 PowerShell、播放器或缓存
+func newOutputSpeaker(cfg *Config) (Speaker, error) {
+	speaker, err := newSpeaker(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DryRun {
+		log.Println("🧪 dry-run 模式：不会实际朗读")
+		return NoopSpeaker{}, nil
+	}
+	// 发布音频到 MQTT 时不在本机播放，播放器和缓存设置不生效
+	if cfg.AudioTopic != "" {
+		log.Printf("📡 音频发布到 %s/#，不在本机播放", cfg.AudioTopic)
+		return &MQTTAudioSpeaker{Inner: speaker, Topic: cfg.AudioTopic, ChunkSize: cfg.AudioChunkSize}, nil
+	}
+	if speaker, err = newPlayerSpeaker(cfg, speaker); err != nil {
+		return nil, err
+	}
+	if speaker, err = newCachingSpeaker(cfg, speaker); err != nil {
+		return nil, err
+	}
+	return newDuckingSpeaker(cfg, speaker), nil
+}
+
+// newLocalSpeaker 按名称创建本机 Speaker，名称为空时按当前操作系统选择：
+// Windows 使用 PowerShell，macOS 使用 say，其余使用 espeak-ng
+func newLocalSpeaker(name string) (Speaker, error) {
+	if name == "" {
+		name = runtime.GOOS
+	}
+	switch strings.ToLower(name) {
+	case "powershell", "windows":
+		// 启动时就确认 PowerShell 可用，而不是等到第一条消息才失败
+		if _, err := exec.LookPath(powerShellPath); err != nil {
+			return nil, fmt.Errorf("找不到 PowerShell %q（可用 powershell_path 指定 pwsh.exe 或绝对路径）: %w", powerShellPath, err)
+		}
+		return PowerShellSpeaker{}, nil
+	case "say", "darwin", "macos":
+		return DarwinSpeaker{}, nil
+	case "espeak", "espeak-ng", "linux":
+		return LinuxSpeaker{}, nil
+	case "noop", "none":
+		return NoopSpeaker{}, nil
+	}
+	if name == runtime.GOOS {
+		return LinuxSpeaker{}, nil
+	}
+	return nil, fmt.Errorf("未知的 TTS 后端 %q（可选 powershell、say、espeak、azure、noop）", name)
+}
+
+// NoopSpeaker 只记录将要朗读的内容，不调用 PowerShell，用于 --dry-run 和测试
+type NoopSpeaker struct{}
+
+func (NoopSpeaker) Speak(ctx context.Context, text string, opts speakOptions) error {
+	log.Printf("🧪 [dry-run] 将朗读 (语音=%q 语言=%q 语速=%d 音量=%d 文件=%q): %q", opts.Voice, opts.Lang, opts.Rate, opts.Volume, opts.OutputFile, text)
+	return nil
+}
+
+// isValidVoiceName 检查语音名称不含换行、引号等会破坏 PowerShell 脚本的字符
+func isValidVoiceName(voice string) bool {
+	for _, r := range voice {
+		if r == '"' || r == '\'' || r == '`' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isValidLang 检查 BCP-47 语言标记的基本格式，如 zh、zh-CN、en-US、zh-Hans-CN
+func isValidLang(lang string) bool {
+	for i, part := range strings.Split(lang, "-") {
+		if len(part) == 0 || len(part) > 8 || (i == 0 && len(part) < 2) {
+			return false
+		}
+		for _, r := range part {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// escapePowerShell 转义文本，使其可安全地放入 PowerShell 双引号字符串中按字面朗读：
+//   - 反引号、双引号（含 PowerShell 同样视为引号的 “ ” „）、$ 前加反引号，
+//     防止提前结束字符串或触发 $(...) 子表达式执行
+//   - 换行、回车、制表符转换为 `n、`r、`t，不会打断生成的脚本
+//   - 其余控制字符替换为空格
+func escapePowerShell(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+	for _, r := range s {
+		switch r {
+		case '`', '"', '$', '\u201C', '\u201D', '\u201E':
+			b.WriteRune('`')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString("`n")
+		case '\r':
+			b.WriteString("`r")
+		case '\t':
+			b.WriteString("`t")
+		default:
+			if unicode.IsControl(r) {
+				b.WriteRune(' ')
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// ErrSpeakTimeout 表示朗读超时，对应的 TTS 进程已被终止
+var ErrSpeakTimeout = errors.New("TTS 朗读超时")
+
+// cmdOutputLogger 将 TTS 命令的输出按行实时写入日志
+type cmdOutputLogger struct {
+	name string
+	buf  []byte
+}
+
+func (l *cmdOutputLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		l.logLine(l.buf[:i])
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 输出最后一行不以换行结尾的内容
+func (l *cmdOutputLogger) Flush() {
+	l.logLine(l.buf)
+	l.buf = nil
+}
+
+func (l *cmdOutputLogger) logLine(line []byte) {
+	if msg := strings.TrimSpace(string(line)); msg != "" {
+		log.Printf("🔊 %s TTS 输出: %s", l.name, msg)
+	}
+}
+
+// runTTSCommand 运行 cmd（须由 exec.CommandContext 创建），输出逐行写入日志。
+// ctx 结束时进程被终止：超时返回 ErrSpeakTimeout，取消返回 ctx.Err()
+func runTTSCommand(ctx context.Context, name string, cmd *exec.Cmd) error {
+	start := time.Now()
+
+	// stdout + stderr 合并后逐行写入日志
+	output := &cmdOutputLogger{name: name}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// 进程被终止后，若有残留子进程仍持有输出管道，最多再等 2 秒即强制关闭，避免 Wait 卡死
+	cmd.WaitDelay = 2 * time.Second
+
+	err := cmd.Run()
+	output.Flush()
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		log.Printf("⏰ %s TTS 超时，进程已终止（耗时: %v）", name, time.Since(start))
+		return ErrSpeakTimeout
+	}
+	if ctx.Err() != nil {
+		log.Printf("🛑 %s TTS 已取消，进程已终止（耗时: %v）", name, time.Since(start))
+		return ctx.Err()
+	}
+	if err != nil {
+		log.Printf("❌ %s TTS 执行失败: %v", name, err)
+		return err
+	}
+
+	log.Printf("🔊 朗读结束，耗时: %v", time.Since(start))
+	return nil
+}
+
+// speakText 朗读文本；opts.Voice 为空时使用系统默认语音，
+// 指定的语音未安装时回退到默认语音并输出警告，而不是整体失败。
+// ctx 结束时 powershell 进程会被终止，超时返回 ErrSpeakTimeout，取消返回 ctx.Err()。
+func speakText(ctx context.Context, text string, opts speakOptions) error {
+	log.Printf("🔊 尝试朗读文本 (长度=%d): %.50q", len(text), text) // 最多显示前50字符
+
+	// 转义 PowerShell 特殊字符
+	safeText := escapePowerShell(text)
+	speak := `$synth.Speak("` + safeText + `")`
+	if opts.SSML {
+		// SSML 以 base64 传入脚本再解码，标记原样交给 SpeakSsml，不经过转义
+		encoded := base64.StdEncoding.EncodeToString([]byte(text))
+		speak = `$synth.SpeakSsml([System.Text.Encoding]::UTF8.GetString([System.Convert]::FromBase64String("` + encoded + `")))`
+	}
+
+	selectVoice := ""
+	if opts.Voice != "" {
+		log.Printf("🗣️ 使用语音: %s", opts.Voice)
+		safeVoice := escapePowerShell(opts.Voice)
+		selectVoice = `
+			    try {
+			        $synth.SelectVoice("` + safeVoice + `")
+			    } catch {
+			        Write-Warning "⚠️ 语音未安装，使用默认语音: ` + safeVoice + `"
+			    }`
+	} else if opts.Lang != "" {
+		// SelectVoiceByHints 找不到匹配语言时会任意选择一个语音，因此先检查已安装的语音
+		log.Printf("🌐 按语言选择语音: %s", opts.Lang)
+		selectVoice = `
+			    try {
+			        $culture = [System.Globalization.CultureInfo]::GetCultureInfo("` + opts.Lang + `")
+			        $installed = $synth.GetInstalledVoices() | Where-Object { $_.Enabled } | ForEach-Object { $_.VoiceInfo }
+			        if ($installed | Where-Object { $_.Culture.Name -eq $culture.Name }) {
+			            $synth.SelectVoiceByHints([System.Speech.Synthesis.VoiceGender]::NotSet, [System.Speech.Synthesis.VoiceAge]::NotSet, 0, $culture)
+			        } else {
+			            Write-Warning ("⚠️ 未安装 ` + opts.Lang + ` 语音，使用默认语音。已安装的语言: " + (($installed | ForEach-Object { $_.Culture.Name } | Sort-Object -Unique) -join ", "))
+			        }
+			    } catch {
+			        Write-Warning "⚠️ 无效的语言 ` + opts.Lang + `，使用默认语音"
+			    }`
+	}
+
+	// 默认输出到音频设备；指定 OutputFile 时写入 .wav 文件
+	setOutput := `
+			    $synth.SetOutputToDefaultAudioDevice()`
+	if opts.OutputFile != "" {
+		log.Printf("💾 保存到文件: %s", opts.OutputFile)
+		setOutput = `
+			    $synth.SetOutputToWaveFile("` + escapePowerShell(opts.OutputFile) + `")`
+	}
+
+	// 构建 PowerShell 命令（增加错误捕获和静默模式）
+	psCmd := `
+			try {
+			    Add-Type -AssemblyName System.Speech
+			    $synth = New-Object System.Speech.Synthesis.SpeechSynthesizer` + selectVoice + `
+			    $synth.Rate = ` + strconv.Itoa(opts.Rate) + `
+			    $synth.Volume = ` + strconv.Itoa(opts.Volume) + setOutput + `
+			    ` + speak + `
+			    $synth.Dispose()
+			    Write-Host "✅ TTS 成功: 长度=$(("` + safeText + `").Length)"
+			} catch {
+			    Write-Error "❌ TTS 失败: $($_.Exception.Message)"
+			    exit 1
+			}
+			`
+
+	cmd := powerShellCommand(ctx, psCmd)
+	// 输出包含 Write-Host、Write-Warning 和 Write-Error
+	return runTTSCommand(ctx, "PowerShell", cmd)
+}
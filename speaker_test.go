@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unquotePowerShell 按 PowerShell 双引号字符串的规则还原 escapePowerShell 的结果：
+// 反引号转义下一个字符（`n、`r、`t 为换行、回车、制表符）。遇到未转义、会结束字符串或
+// 展开变量的字符，或以单个反引号结尾时返回 false
+func unquotePowerShell(s string) (string, bool) {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if escaped {
+			switch r {
+			case 'n':
+				b.WriteRune('\n')
+			case 'r':
+				b.WriteRune('\r')
+			case 't':
+				b.WriteRune('\t')
+			default:
+				b.WriteRune(r)
+			}
+			escaped = false
+			continue
+		}
+		switch r {
+		case '`':
+			escaped = true
+		case '"', '$', '“', '”', '„', '\n', '\r':
+			return "", false
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), !escaped
+}
+
+func TestEscapePowerShell(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // escapePowerShell 的结果
+		text string // PowerShell 实际得到的字符串
+	}{
+		{"plain", "你好 world", "你好 world", "你好 world"},
+		{"subexpression", "`n$(Get-Process)", "``n`$(Get-Process)", "`n$(Get-Process)"},
+		{"close string", `"; Remove-Item x; "`, "`\"; Remove-Item x; `\"", `"; Remove-Item x; "`},
+		{"variable", "$env:PATH", "`$env:PATH", "$env:PATH"},
+		{"smart quotes", "“”„", "`“`”`„", "“”„"},
+		{"trailing backtick", "abc`", "abc``", "abc`"},
+		{"newline tab", "a\r\nb\tc", "a`r`nb`tc", "a\r\nb\tc"},
+		{"other control", "a\x00b\x1bc\x7fd", "a b c d", "a b c d"},
+		{"single quote", "it's", "it's", "it's"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := escapePowerShell(tt.in)
+			if got != tt.want {
+				t.Errorf("escapePowerShell(%q) = %q，期望 %q", tt.in, got, tt.want)
+			}
+			text, ok := unquotePowerShell(got)
+			if !ok {
+				t.Fatalf("escapePowerShell(%q) = %q 在双引号字符串中仍有未转义的字符", tt.in, got)
+			}
+			if text != tt.text {
+				t.Errorf("PowerShell 得到 %q，期望按字面朗读 %q", text, tt.text)
+			}
+		})
+	}
+}
+
+func TestIsValidVoiceName(t *testing.T) {
+	tests := []struct {
+		voice string
+		want  bool
+	}{
+		{"Microsoft Huihui Desktop", true},
+		{"Microsoft Zira Desktop - English (United States)", true},
+		{"", true},
+		{`Zira"); Remove-Item x; ("`, false},
+		{"Zira'", false},
+		{"Zira`n", false},
+		{"Zira\nDesktop", false},
+		{"Zira\x00", false},
+	}
+	for _, tt := range tests {
+		if got := isValidVoiceName(tt.voice); got != tt.want {
+			t.Errorf("isValidVoiceName(%q) = %v，期望 %v", tt.voice, got, tt.want)
+		}
+	}
+}
+
+func TestIsValidLang(t *testing.T) {
+	tests := []struct {
+		lang string
+		want bool
+	}{
+		{"zh", true},
+		{"zh-CN", true},
+		{"en-US", true},
+		{"zh-Hans-CN", true},
+		{"es-419", true},
+		{"", false},
+		{"z", false},
+		{"zh-", false},
+		{"-CN", false},
+		{"12-CN", false},
+		{"zh_CN", false},
+		{"zh-CN\"", false},
+		{"toolongtag-CN", false},
+	}
+	for _, tt := range tests {
+		if got := isValidLang(tt.lang); got != tt.want {
+			t.Errorf("isValidLang(%q) = %v，期望 %v", tt.lang, got, tt.want)
+		}
+	}
+}
+
+// dry-run 时不包装播放器、缓存和音量闪避，也不创建缓存目录；
+// 配置 speaker=noop 但不是 dry-run 时照常包装
+func TestNewOutputSpeakerDryRun(t *testing.T) {
+	newCfg := func(dryRun bool) *Config {
+		cfg := defaultConfig()
+		cfg.Speaker = "noop"
+		cfg.PlayerCommand = "mpv {file}"
+		cfg.CacheDir = filepath.Join(t.TempDir(), "cache")
+		cfg.DuckMediaPercent = 30
+		cfg.DryRun = dryRun
+		return cfg
+	}
+
+	cfg := newCfg(true)
+	speaker, err := newOutputSpeaker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := speaker.(NoopSpeaker); !ok {
+		t.Errorf("dry-run 时返回 %T，期望 NoopSpeaker", speaker)
+	}
+	if _, err := os.Stat(cfg.CacheDir); !os.IsNotExist(err) {
+		t.Errorf("dry-run 时创建了缓存目录: %v", err)
+	}
+
+	cfg = newCfg(false)
+	speaker, err = newOutputSpeaker(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	duck, ok := speaker.(*DuckingSpeaker)
+	if !ok {
+		t.Fatalf("返回 %T，期望 *DuckingSpeaker", speaker)
+	}
+	cache, ok := duck.Inner.(CachingSpeaker)
+	if !ok {
+		t.Fatalf("DuckingSpeaker 包装 %T，期望 CachingSpeaker", duck.Inner)
+	}
+	if _, ok := cache.Inner.(PlayerSpeaker); !ok {
+		t.Errorf("CachingSpeaker 包装 %T，期望 PlayerSpeaker", cache.Inner)
+	}
+	if _, err := os.Stat(cfg.CacheDir); err != nil {
+		t.Errorf("未创建缓存目录: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// isSentenceEnd 判断 r 是否为句末标点
+func isSentenceEnd(r rune) bool {
+	switch r {
+	case '。', '！', '？', '.', '!', '?':
+		return true
+	}
+	return false
+}
+
+// splitSentences 在句末标点（。！？.!?）后断句，标点保留在句子末尾。
+// 英文句号只有后跟空白或位于末尾时才断句，避免拆开 3.14 之类的数字
+func splitSentences(text string) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i, r := range runes {
+		if !isSentenceEnd(r) {
+			continue
+		}
+		next := i + 1
+		if r == '.' && next < len(runes) && !unicode.IsSpace(runes[next]) {
+			continue
+		}
+		// 连续的标点（如 ?! 或 ……。）归入同一句
+		if next < len(runes) && isSentenceEnd(runes[next]) {
+			continue
+		}
+		if s := strings.TrimSpace(string(runes[start:next])); s != "" {
+			sentences = append(sentences, s)
+		}
+		start = next
+	}
+	if s := strings.TrimSpace(string(runes[start:])); s != "" {
+		sentences = append(sentences, s)
+	}
+	return sentences
+}
+
+// splitText 将 text 拆分为不超过 max 个字符的片段，依次朗读：
+// 尽量按整句合并；单句超长时在空白处断开，不拆开单词；
+// 没有空白可断的超长片段（如连续的中文）才按字符截断
+func splitText(text string, max int) []string {
+	var chunks []string
+	var cur strings.Builder
+	curLen := 0
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			chunks = append(chunks, s)
+		}
+		cur.Reset()
+		curLen = 0
+	}
+	// add 将 piece 追加到当前片段，放不下时先输出当前片段；sep 为与前文之间的分隔符
+	add := func(piece, sep string) {
+		n := utf8.RuneCountInString(piece)
+		if curLen > 0 && curLen+len(sep)+n > max {
+			flush()
+		}
+		if curLen > 0 {
+			cur.WriteString(sep)
+			curLen += len(sep)
+		}
+		cur.WriteString(piece)
+		curLen += n
+	}
+
+	for _, sentence := range splitSentences(text) {
+		if utf8.RuneCountInString(sentence) <= max {
+			// 中文句末标点后原本没有空白，合并时也不加空格
+			sep := " "
+			switch r, _ := utf8.DecodeLastRuneInString(cur.String()); r {
+			case '。', '！', '？':
+				sep = ""
+			}
+			add(sentence, sep)
+			continue
+		}
+		for _, word := range strings.Fields(sentence) {
+			for utf8.RuneCountInString(word) > max {
+				flush()
+				runes := []rune(word)
+				chunks = append(chunks, string(runes[:max]))
+				word = string(runes[max:])
+			}
+			add(word, " ")
+		}
+	}
+	flush()
+	return chunks
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitSentences(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"今天天气很好。我们去公园吧！好不好？", []string{"今天天气很好。", "我们去公园吧！", "好不好？"}},
+		{"圆周率约为3.14。半径是2.5米", []string{"圆周率约为3.14。", "半径是2.5米"}},
+		{"The value is 3.14 exactly. Next one.", []string{"The value is 3.14 exactly.", "Next one."}},
+		{"Wait?! Really…。OK", []string{"Wait?!", "Really…。", "OK"}},
+		{"  没有句末标点  ", []string{"没有句末标点"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitSentences(tt.in); !slices.Equal(got, tt.want) {
+			t.Errorf("splitSentences(%q) = %q，期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		max  int
+		want []string
+	}{
+		{"fits", "门铃响了。", 10, []string{"门铃响了。"}},
+		{"merge chinese sentences", "短句。短句。短句。", 7, []string{"短句。短句。", "短句。"}},
+		{"merge english sentences", "One. Two. Three.", 10, []string{"One. Two.", "Three."}},
+		{"decimal stays in sentence", "圆周率约为3.14。半径是2.5米。", 10, []string{"圆周率约为3.14。", "半径是2.5米。"}},
+		{"decimal not split in english", "Pi is 3.14 today. Bye.", 20, []string{"Pi is 3.14 today.", "Bye."}},
+		{"chinese run without spaces", "今天天气很好我们去公园散步吧", 5, []string{"今天天气很", "好我们去公", "园散步吧"}},
+		{"long sentence at spaces", "Hello world this is a long sentence", 12, []string{"Hello world", "this is a", "long", "sentence"}},
+		{"long word cut", "supercalifragilistic word", 8, []string{"supercal", "ifragili", "stic", "word"}},
+		{"mixed", "第一句。这是一个很长很长很长的第二句。第三句。", 8, []string{"第一句。", "这是一个很长很长", "很长的第二句。", "第三句。"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitText(tt.in, tt.max)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitText(%q, %d) = %q，期望 %q", tt.in, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitRequests(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.MaxTextLength = 4
+	reqs := splitRequests(cfg, []speakRequest{
+		{Text: "短句。"},
+		{Text: "第一句。第二句。第三句。"},
+		{Text: "<speak>很长很长的 SSML</speak>", Opts: speakOptions{SSML: true}},
+	})
+	var texts []string
+	for _, r := range reqs {
+		texts = append(texts, r.Text)
+	}
+	want := []string{"短句。", "第一句。", "第二句。", "第三句。", "<speak>很长很长的 SSML</speak>"}
+	if !slices.Equal(texts, want) {
+		t.Fatalf("拆分为 %q，期望 %q", texts, want)
+	}
+	if reqs[0].group != 0 || reqs[4].group != 0 {
+		t.Error("未拆分的请求不应分组")
+	}
+	for i, r := range reqs[1:4] {
+		if r.group != reqs[1].group || r.group == 0 || r.chunk != i || r.chunks != 3 {
+			t.Errorf("第 %d 段为 group=%d chunk=%d/%d", i, r.group, r.chunk, r.chunks)
+		}
+	}
+}
+
+// 拆分出的各段按顺序朗读，相邻两段之间停顿 chunk_pause_ms
+func TestQueueSpeaksChunksInOrderWithPause(t *testing.T) {
+	const pause = 100 * time.Millisecond
+	cfg := defaultConfig()
+	cfg.MaxTextLength = 4
+	cfg.ChunkPauseMs = int(pause / time.Millisecond)
+	speaker := &flakySpeaker{}
+	q := newSpeakQueue(cfg, speaker)
+	results := make(chan string, 8)
+	q.onResult = func(req speakRequest, err error, _ time.Duration) { results <- req.Text }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	q.Enqueue(splitRequests(cfg, []speakRequest{{Text: "第一句。第二句。第三句。"}})...)
+
+	var got []string
+	for range 3 {
+		select {
+		case text := <-results:
+			got = append(got, text)
+		case <-time.After(5 * time.Second):
+			t.Fatal("等待朗读超时")
+		}
+	}
+	if want := []string{"第一句。", "第二句。", "第三句。"}; !slices.Equal(got, want) {
+		t.Errorf("朗读顺序 %q，期望 %q", got, want)
+	}
+	calls := speaker.Calls()
+	for i := 1; i < len(calls); i++ {
+		if gap := calls[i].Sub(calls[i-1]); gap < pause {
+			t.Errorf("第 %d、%d 段之间停顿 %v，期望至少 %v", i, i+1, gap, pause)
+		}
+	}
+}
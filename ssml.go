@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"slices"
+	"strings"
+)
+
+// isValidSSML 检查 s 是格式良好的 XML，且唯一的根元素为 <speak>
+func isValidSSML(s string) bool {
+	dec := xml.NewDecoder(strings.NewReader(s))
+	depth := 0
+	seenRoot := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return seenRoot && depth == 0
+		}
+		if err != nil {
+			return false
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 {
+				if seenRoot || t.Name.Local != "speak" {
+					return false
+				}
+				seenRoot = true
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		case xml.CharData:
+			if depth == 0 && strings.TrimSpace(string(t)) != "" {
+				return false
+			}
+		}
+	}
+}
+
+// ssmlToText 提取 SSML 中的文本内容，用于不支持 SSML 的后端
+func ssmlToText(s string) string {
+	dec := xml.NewDecoder(strings.NewReader(s))
+	var b strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			break
+		}
+		if t, ok := tok.(xml.CharData); ok {
+			b.Write(t)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// wrapSpeech 在 text 前后加上 prefix 和 suffix（以空格分隔，空的一侧不加）。
+// ssml 为 true 时转义后放在 <speak> 根元素内部，保持 SSML 格式良好
+func wrapSpeech(text, prefix, suffix string, ssml bool) string {
+	prefix, suffix = strings.TrimSpace(prefix), strings.TrimSpace(suffix)
+	if prefix == "" && suffix == "" {
+		return text
+	}
+	if !ssml {
+		return strings.Join(slices.DeleteFunc([]string{prefix, text, suffix}, func(s string) bool { return s == "" }), " ")
+	}
+	open := strings.Index(text, "<speak")
+	openEnd := strings.Index(text[max(open, 0):], ">")
+	end := strings.LastIndex(text, "</speak>")
+	if open < 0 || openEnd < 0 || end < 0 {
+		return text
+	}
+	openEnd += open + 1
+	if prefix != "" {
+		text = text[:openEnd] + xmlEscape(prefix) + " " + text[openEnd:]
+		end += len(xmlEscape(prefix)) + 1
+	}
+	if suffix != "" {
+		text = text[:end] + " " + xmlEscape(suffix) + text[end:]
+	}
+	return text
+}
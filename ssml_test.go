@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestWrapSpeech(t *testing.T) {
+	tests := []struct {
+		name           string
+		text           string
+		prefix, suffix string
+		ssml           bool
+		want           string
+	}{
+		{"none", "门铃响了", "", "", false, "门铃响了"},
+		{"blank affixes", "门铃响了", "  ", "\t", false, "门铃响了"},
+		{"prefix", "门铃响了", "注意，", "", false, "注意， 门铃响了"},
+		{"suffix", "门铃响了", "", "完毕", false, "门铃响了 完毕"},
+		{"both trimmed", "门铃响了", " 注意 ", " 完毕 ", false, "注意 门铃响了 完毕"},
+		{"empty text", "", "注意", "完毕", false, "注意 完毕"},
+		{"ssml prefix", "<speak>门铃响了</speak>", "注意", "", true, "<speak>注意 门铃响了</speak>"},
+		{"ssml suffix", "<speak>门铃响了</speak>", "", "完毕", true, "<speak>门铃响了 完毕</speak>"},
+		{"ssml root attributes", `<speak version="1.0" xml:lang="zh-CN">门铃</speak>`, "注意", "完毕", true, `<speak version="1.0" xml:lang="zh-CN">注意 门铃 完毕</speak>`},
+		{"ssml escapes affixes", "<speak>门铃</speak>", "A&B", "<完>", true, "<speak>A&amp;B 门铃 &lt;完&gt;</speak>"},
+		{"ssml without root", "门铃响了", "注意", "完毕", true, "门铃响了"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapSpeech(tt.text, tt.prefix, tt.suffix, tt.ssml); got != tt.want {
+				t.Errorf("wrapSpeech(%q, %q, %q) = %q，期望 %q", tt.text, tt.prefix, tt.suffix, got, tt.want)
+			}
+		})
+	}
+}
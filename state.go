@@ -0,0 +1,121 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// bridgeState 记录运行状态，由 MQTT 回调更新，供 /healthz 查询
+type bridgeState struct {
+	connected   atomic.Bool
+	lastMessage atomic.Int64 // 最近一次收到消息的 UnixNano，0 表示尚未收到
+	started     time.Time    // 桥接器启动时间，由 run 在启动时设置
+}
+
+func (s *bridgeState) touchMessage() {
+	s.lastMessage.Store(time.Now().UnixNano())
+	metrics.messagesReceived.Add(1)
+}
+
+// healthStatus 是 /healthz 返回的 JSON
+type healthStatus struct {
+	Connected   bool   `json:"connected"`
+	LastMessage string `json:"last_message,omitempty"` // RFC3339，尚未收到消息时省略
+	QueueDepth  int    `json:"queue_depth"`
+	Muted       bool   `json:"muted"`
+	MutedUntil  string `json:"muted_until,omitempty"` // RFC3339，未指定时长的静音省略
+
+	// Healthy 为 false 表示 TTS 连续失败已达到阈值，音频子系统可能挂起
+	Healthy             bool `json:"healthy"`
+	ConsecutiveFailures int  `json:"consecutive_failures"`
+}
+
+func (b *bridge) health() healthStatus {
+	h := healthStatus{
+		Connected:  b.state.connected.Load(),
+		QueueDepth: b.queue.Len(),
+	}
+	unhealthy, failures := b.queue.failures.Status()
+	h.Healthy, h.ConsecutiveFailures = !unhealthy, failures
+	if until, muted := b.queue.MutedUntil(); muted {
+		h.Muted = true
+		if !until.Equal(foreverMuted) {
+			h.MutedUntil = until.Format(time.RFC3339)
+		}
+	}
+	if ns := b.state.lastMessage.Load(); ns > 0 {
+		h.LastMessage = time.Unix(0, ns).Format(time.RFC3339)
+	}
+	return h
+}
+
+// bridgeStatus 是 status 命令发布的完整状态，在 /healthz 的 healthStatus 之上
+// 加入运行时长、累计计数和（已脱敏的）配置摘要
+type bridgeStatus struct {
+	healthStatus
+	Version       string        `json:"version"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	Counters      statusCounter `json:"counters"`
+	Config        configSummary `json:"config"`
+}
+
+// statusCounter 是启动以来的累计计数，与 /metrics 中的同名计数器一致
+type statusCounter struct {
+	Received uint64 `json:"received"`
+	Spoken   uint64 `json:"spoken"`
+	Failures uint64 `json:"failures"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+// configSummary 是配置中便于排查问题的部分，broker 地址中的密码已脱敏，不含任何密钥
+type configSummary struct {
+	Brokers      []string `json:"brokers"`
+	Topics       []string `json:"topics"`
+	ClientID     string   `json:"client_id"`
+	Username     string   `json:"username,omitempty"`
+	Speaker      string   `json:"speaker,omitempty"`
+	QueueSize    int      `json:"queue_size"`
+	Workers      int      `json:"workers"`
+	StatusTopic  string   `json:"status_topic,omitempty"`
+	CommandTopic string   `json:"command_topic,omitempty"`
+	MuteMode     string   `json:"mute_mode,omitempty"`
+	QuietHours   string   `json:"quiet_hours,omitempty"`
+	HTTPAddr     string   `json:"http_addr,omitempty"`
+}
+
+func (b *bridge) status() bridgeStatus {
+	cfg := b.config()
+	brokers := make([]string, len(cfg.Brokers))
+	for i, broker := range cfg.Brokers {
+		brokers[i] = redactConfig(cfg, broker)
+	}
+	stats := metrics.snapshot()
+	st := bridgeStatus{
+		healthStatus: b.health(),
+		Version:      version,
+		Counters: statusCounter{
+			Received: stats.received,
+			Spoken:   stats.spoken,
+			Failures: stats.failures,
+			Dropped:  stats.dropped,
+		},
+		Config: configSummary{
+			Brokers:      brokers,
+			Topics:       cfg.Topics,
+			ClientID:     cfg.ClientID,
+			Username:     cfg.Username,
+			Speaker:      cfg.Speaker,
+			QueueSize:    cfg.QueueSize,
+			Workers:      cfg.Workers,
+			StatusTopic:  cfg.StatusTopic,
+			CommandTopic: cfg.CommandTopic,
+			MuteMode:     cfg.MuteMode,
+			QuietHours:   cfg.QuietHours,
+			HTTPAddr:     cfg.HTTPAddr,
+		},
+	}
+	if !b.state.started.IsZero() {
+		st.UptimeSeconds = int64(time.Since(b.state.started).Seconds())
+	}
+	return st
+}
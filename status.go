@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// speakStatus 是朗读结束后发布到状态主题的 JSON 回执
+type speakStatus struct {
+	Topic         string `json:"topic,omitempty"`          // 消息实际到达的主题，HTTP 请求为空
+	CorrelationID string `json:"correlation_id,omitempty"` // 消息中的 correlation_id
+	Text          string `json:"text"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`
+	DurationMs    int64  `json:"duration_ms"`
+	Output        string `json:"output,omitempty"`   // 保存的 .wav 路径
+	Attempts      int    `json:"attempts,omitempty"` // 朗读尝试次数，重试过时大于 1
+	Timestamp     string `json:"timestamp"`          // RFC3339，朗读结束时间
+}
+
+func newSpeakStatus(req speakRequest, err error, elapsed time.Duration) speakStatus {
+	st := speakStatus{
+		Topic:         req.Topic,
+		CorrelationID: req.CorrelationID,
+		Text:          req.Text,
+		Success:       err == nil,
+		DurationMs:    elapsed.Milliseconds(),
+		Output:        req.Opts.OutputFile,
+		Attempts:      req.attempts,
+		Timestamp:     time.Now().Format(time.RFC3339),
+	}
+	if err != nil {
+		st.Error = err.Error()
+	}
+	return st
+}
+
+// payloadError 是消息体无法解析时发布到状态主题的诊断信息
+type payloadError struct {
+	Topic     string `json:"topic"`
+	Payload   string `json:"payload"` // 截断到 200 字节
+	Error     string `json:"error"`
+	Timestamp string `json:"timestamp"`
+}
+
+func newPayloadError(topic, payload string, err error) payloadError {
+	if len(payload) > 200 {
+		payload = strings.ToValidUTF8(payload[:200], "")
+	}
+	return payloadError{
+		Topic:     topic,
+		Payload:   payload,
+		Error:     err.Error(),
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+}
+
+// publishStatus 以 QoS 1 发布 v 的 JSON 到 topic；topic 为空时不发布
+func publishStatus(client mqtt.Client, topic string, v interface{}) {
+	if topic == "" {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("❌ 回执序列化失败: %v", err)
+		return
+	}
+	publishMessage(client, topic, false, data)
+}
+
+// publishAvailability 向 topic 发布保留的在线状态，新订阅者立即可以看到最新状态
+func publishAvailability(client mqtt.Client, topic, payload string) {
+	publishMessage(client, topic, true, []byte(payload))
+	log.Printf("📶 在线状态: %s -> %q", topic, payload)
+}
+
+// publishMessage 以 QoS 1 发布 payload 并等待确认，失败只记录日志
+func publishMessage(client mqtt.Client, topic string, retained bool, payload []byte) {
+	token := client.Publish(topic, 1, retained, payload)
+	if !token.WaitTimeout(5 * time.Second) {
+		log.Printf("⚠️ 发布消息超时: %s", topic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		log.Printf("❌ 发布消息失败 %s: %v", topic, err)
+	}
+}
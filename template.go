@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// templateCache 缓存已解析的模板，热加载修改模板后按新的文本重新解析
+var templateCache sync.Map // string -> *template.Template
+
+// parseTemplate 解析朗读模板；引用消息中不存在的字段时执行报错，而不是读出 "<no value>"
+func parseTemplate(text string) (*template.Template, error) {
+	if t, ok := templateCache.Load(text); ok {
+		return t.(*template.Template), nil
+	}
+	t, err := template.New("template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	templateCache.Store(text, t)
+	return t, nil
+}
+
+// renderTemplate 将消息体作为数据执行模板，得到朗读文本。消息体按 JSON 解析
+// （数字保留原文，如 21.50 不会变成 21.5），不是 JSON 时整体作为字符串，可用 {{.}} 引用
+func renderTemplate(text string, body []byte) (string, error) {
+	t, err := parseTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("无效的模板: %w", err)
+	}
+	var data interface{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&data); err != nil || dec.More() {
+		data = strings.TrimSpace(string(body))
+	}
+	var out strings.Builder
+	if err := t.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("模板执行失败: %w", err)
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTextFileSize 为消息 file 字段所指文件的大小上限
+const maxTextFileSize = 1 << 20
+
+// errTextFile 表示消息 file 字段所指的文件不在允许的目录下或无法读取
+var errTextFile = errors.New("无法朗读文件")
+
+// confineTextFile 将消息中的 file 解析为 base（Config.TextFileDir）下的文件路径。
+// 相对路径相对于 base；绝对路径必须位于 base 之内。符号链接解析后再检查一次，
+// 未配置 base 时不接受任何 file，防止发布者借此读取任意文件
+func confineTextFile(base, file string) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("%w %q: 未配置 text_file_dir", errTextFile, file)
+	}
+	base, err := filepath.Abs(base)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errTextFile, file, err)
+	}
+	path := file
+	if !filepath.IsAbs(path) {
+		// 与 confineOutputDir 相同，先统一斜杠，避免 Linux 上放过 ..\..
+		rel := filepath.FromSlash(strings.ReplaceAll(file, `\`, "/"))
+		if !filepath.IsLocal(rel) {
+			return "", fmt.Errorf("%w %q: 只能是 text_file_dir 下的文件", errTextFile, file)
+		}
+		path = filepath.Join(base, rel)
+	}
+	if !within(base, path) {
+		return "", fmt.Errorf("%w %q: 只能是 text_file_dir 下的文件", errTextFile, file)
+	}
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errTextFile, file, err)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errTextFile, file, err)
+	}
+	if !within(realBase, realPath) {
+		return "", fmt.Errorf("%w %q: 链接指向 text_file_dir 之外", errTextFile, file)
+	}
+	return realPath, nil
+}
+
+// within 判断 path 是否位于目录 base 之内（不含 base 本身）
+func within(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	return err == nil && rel != "." && filepath.IsLocal(rel)
+}
+
+// readTextFile 读取 base 下的 UTF-8 文本文件（可带 BOM），超过 maxTextFileSize 时报错
+func readTextFile(base, file string) (string, error) {
+	path, err := confineTextFile(base, file)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errTextFile, file, err)
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w %q: 不是普通文件", errTextFile, file)
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxTextFileSize+1))
+	if err != nil {
+		return "", fmt.Errorf("%w %q: %v", errTextFile, file, err)
+	}
+	if len(data) > maxTextFileSize {
+		return "", fmt.Errorf("%w %q: 超过 %d KB", errTextFile, file, maxTextFileSize>>10)
+	}
+	// 记事本保存的 UTF-8 文件常带 BOM
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("%w %q: 不是 UTF-8 编码", errTextFile, file)
+	}
+	return string(data), nil
+}
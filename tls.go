@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// isTLSBroker 判断 broker 地址是否需要 TLS（ssl:// 、mqtts:// 等）
+func isTLSBroker(broker string) bool {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "ssl", "tls", "mqtts", "mqtt+ssl", "tcps", "wss":
+		return true
+	}
+	return false
+}
+
+// newTLSConfig 根据配置构建 *tls.Config：
+// 指定 CAFile 时使用其中的证书作为根证书，否则使用系统证书；
+// 同时指定 ClientCertFile 和 ClientKeyFile，或指定 ClientPFXFile 时加载客户端证书用于双向认证
+func newTLSConfig(cfg *Config) (*tls.Config, error) {
+	tlsCfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取 CA 证书 %q: %w", cfg.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA 证书 %q 中没有有效的 PEM 证书", cfg.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if (cfg.ClientCertFile == "") != (cfg.ClientKeyFile == "") {
+		return nil, fmt.Errorf("客户端证书和私钥必须同时指定（cert=%q, key=%q）", cfg.ClientCertFile, cfg.ClientKeyFile)
+	}
+	if cfg.ClientPFXFile != "" && cfg.ClientCertFile != "" {
+		return nil, fmt.Errorf("client_pfx_file 与 client_cert_file/client_key_file 只能指定一种")
+	}
+	if cfg.ClientPFXFile != "" {
+		cert, err := loadPFX(cfg.ClientPFXFile, cfg.ClientPFXPassword)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if cfg.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("无法加载客户端证书 %q / %q: %w", cfg.ClientCertFile, cfg.ClientKeyFile, err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// loadPFX 从 PKCS#12 文件加载客户端证书和私钥，文件中的中间证书一并作为证书链发送
+func loadPFX(path, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("无法读取 PKCS#12 文件 %q: %w", path, err)
+	}
+	blocks, err := pkcs12.ToPEM(data, password)
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		return tls.Certificate{}, fmt.Errorf("PKCS#12 文件 %q 的密码错误", path)
+	}
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("无法解析 PKCS#12 文件 %q: %w", path, err)
+	}
+	var certPEM, keyPEM []byte
+	for _, b := range blocks {
+		if b.Type == "CERTIFICATE" {
+			certPEM = append(certPEM, pem.EncodeToMemory(b)...)
+		} else {
+			keyPEM = append(keyPEM, pem.EncodeToMemory(b)...)
+		}
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("PKCS#12 文件 %q 中没有可用的证书和私钥: %w", path, err)
+	}
+	return cert, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// topicSettings 是按主题设置的默认朗读参数，消息本身未指定时生效
+type topicSettings struct {
+	Topic  string // MQTT 主题过滤器，可使用 + 和 # 通配符
+	Voice  string
+	Rate   *int
+	Volume *int
+	// TopicSegment 覆盖 Config.SpeakTopicSegment，0 表示该主题不加前缀
+	TopicSegment *int
+}
+
+// matchTopic 判断 topic 是否匹配 MQTT 主题过滤器 filter：
+// + 匹配一级，末尾的 # 匹配其余任意级（包括父级本身）；
+// 以 $ 开头的系统主题不被首级通配符匹配
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	f := strings.Split(filter, "/")
+	t := strings.Split(topic, "/")
+	for i, part := range f {
+		if part == "#" {
+			return true
+		}
+		if i >= len(t) || (part != "+" && part != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+// validTopicFilter 检查 filter 是合法的订阅过滤器：非空，+ 和 # 独占一级，# 只能在末尾
+func validTopicFilter(filter string) bool {
+	if filter == "" {
+		return false
+	}
+	parts := strings.Split(filter, "/")
+	for i, part := range parts {
+		if strings.ContainsAny(part, "+#") && len(part) > 1 {
+			return false
+		}
+		if part == "#" && i != len(parts)-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// applyTopicSettings 用第一条匹配 topic 的设置补全 p 中未指定的 voice、rate 和 volume
+func applyTopicSettings(list []topicSettings, topic string, p ttsPayload) ttsPayload {
+	for _, s := range list {
+		if !matchTopic(s.Topic, topic) {
+			continue
+		}
+		if p.Voice == "" {
+			p.Voice = s.Voice
+		}
+		if p.Rate == nil {
+			p.Rate = s.Rate
+		}
+		if p.Volume == nil {
+			p.Volume = s.Volume
+		}
+		debugf("🎛️ 主题 %s 使用 %s 的默认朗读参数", topic, s.Topic)
+		break
+	}
+	return p
+}
+
+// topicLabel 返回 topic 的第 segment 级（从 1 开始，负数从末尾数起，-1 为最后一级），
+// 不存在该级或 segment 为 0 时返回空。humanize 为 true 时将 _ 和 - 换成空格并将每个词首字母大写，
+// 如 living_room -> Living Room
+func topicLabel(topic string, segment int, humanize bool) string {
+	parts := strings.Split(topic, "/")
+	i := segment - 1
+	if segment < 0 {
+		i = len(parts) + segment
+	}
+	if segment == 0 || i < 0 || i >= len(parts) {
+		return ""
+	}
+	label := parts[i]
+	if !humanize {
+		return label
+	}
+	words := strings.FieldsFunc(label, func(r rune) bool { return r == '_' || r == '-' || unicode.IsSpace(r) })
+	for j, w := range words {
+		r, n := utf8.DecodeRuneInString(w)
+		words[j] = string(unicode.ToUpper(r)) + w[n:]
+	}
+	return strings.Join(words, " ")
+}
+
+// applyTopicLabel 按 segment（第一条匹配 topic 的设置中的 TopicSegment 优先）把主题中
+// 的一级作为前缀加到朗读文本前，如 home/kitchen/tts -> "Kitchen: ..."；原有前缀保留在最前面
+func applyTopicLabel(cfg *Config, topic string, p ttsPayload) ttsPayload {
+	segment := cfg.SpeakTopicSegment
+	for _, s := range cfg.TopicSettings {
+		if matchTopic(s.Topic, topic) {
+			if s.TopicSegment != nil {
+				segment = *s.TopicSegment
+			}
+			break
+		}
+	}
+	label := topicLabel(topic, segment, cfg.SpeakTopicHumanize)
+	if label == "" {
+		return p
+	}
+	prefix := cfg.SpeakPrefix
+	if p.Prefix != nil {
+		prefix = *p.Prefix
+	}
+	prefix = strings.TrimSpace(prefix + " " + label + ":")
+	p.Prefix = &prefix
+	return p
+}
+
+// jsonTopicSettings 读取 raw[key] 中的主题设置数组，如
+//
+//	"topic_settings": [{"topic": "home/kitchen/#", "voice": "...", "rate": 1, "volume": 80, "topic_segment": 2}]
+//
+// 缺少 topic 的条目被忽略
+func jsonTopicSettings(raw map[string]interface{}, key string, dst *[]topicSettings) {
+	items, ok := raw[key].([]interface{})
+	if !ok {
+		return
+	}
+	var list []topicSettings
+	for i, item := range items {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			log.Printf("⚠️ %s[%d] 不是对象，已忽略", key, i)
+			continue
+		}
+		var s topicSettings
+		jsonString(m, "topic", &s.Topic)
+		jsonString(m, "voice", &s.Voice)
+		if _, ok := m["rate"].(float64); ok {
+			s.Rate = new(int)
+			jsonInt(m, "rate", s.Rate)
+		}
+		if _, ok := m["volume"].(float64); ok {
+			s.Volume = new(int)
+			jsonInt(m, "volume", s.Volume)
+		}
+		if _, ok := m["topic_segment"].(float64); ok {
+			s.TopicSegment = new(int)
+			jsonInt(m, "topic_segment", s.TopicSegment)
+		}
+		if s.Topic == "" {
+			log.Printf("⚠️ %s[%d] 缺少 topic，已忽略", key, i)
+			continue
+		}
+		list = append(list, s)
+	}
+	*dst = list
+}
+
+// validateTopicSettings 检查每条设置的主题过滤器
+func validateTopicSettings(list []topicSettings) error {
+	for _, s := range list {
+		if !validTopicFilter(s.Topic) {
+			return fmt.Errorf("topic_settings 中的主题 %q 不是有效的 MQTT 主题过滤器", s.Topic)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errMissingVoice 表示消息指定的语音未安装，等待安装超时后不再朗读
+var errMissingVoice = errors.New("语音未安装")
+
+// 等待语音安装的消息最多保留的条数和时长
+const (
+	maxMissingVoicePending = 100
+	missingVoiceRetryLimit = time.Hour
+)
+
+// voiceCache 缓存已安装的语音，定期刷新；known 为 false 表示尚未（或无法）列出语音，
+// 此时不判断语音是否已安装
+type voiceCache struct {
+	mu     sync.Mutex
+	voices []voiceInfo
+	known  bool
+}
+
+// installedVoices 是进程内唯一的已安装语音缓存
+var installedVoices = &voiceCache{}
+
+// Set 更新缓存，同时更新语音轮换可选的语音
+func (c *voiceCache) Set(voices []voiceInfo) {
+	c.mu.Lock()
+	c.voices, c.known = voices, true
+	c.mu.Unlock()
+	voiceRotation.SetInstalled(voices)
+}
+
+// Refresh 重新列出 speaker 已安装的语音
+func (c *voiceCache) Refresh(ctx context.Context, speaker Speaker) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	voices, err := listVoices(ctx, speaker)
+	if err != nil {
+		return err
+	}
+	c.Set(voices)
+	return nil
+}
+
+// Lookup 判断 voice 是否已安装（不区分大小写）；known 为 false 时 installed 无意义
+func (c *voiceCache) Lookup(voice string) (installed, known bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.known {
+		return false, false
+	}
+	for _, v := range c.voices {
+		if v.Enabled && strings.EqualFold(v.Name, voice) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// Names 返回已启用的语音名称
+func (c *voiceCache) Names() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var names []string
+	for _, v := range c.voices {
+		if v.Enabled {
+			names = append(names, v.Name)
+		}
+	}
+	return names
+}
+
+// missingVoiceError 是消息指定的语音未安装时发布到状态主题的诊断信息
+type missingVoiceError struct {
+	Topic     string   `json:"topic,omitempty"`
+	Voice     string   `json:"voice"`
+	Text      string   `json:"text"`
+	Error     string   `json:"error"`
+	Installed []string `json:"installed"` // 已安装的语音
+	// Retrying 为 true 表示消息在等待语音安装，否则已改用默认语音朗读
+	Retrying  bool   `json:"retrying"`
+	Timestamp string `json:"timestamp"`
+}
+
+// missingVoices 保存等待语音安装后再朗读的消息
+type missingVoices struct {
+	mu      sync.Mutex
+	pending []heldMessage
+}
+
+// heldMessage 是一条等待语音安装的消息的全部请求，since 为开始等待的时间
+type heldMessage struct {
+	reqs  []speakRequest
+	since time.Time
+}
+
+// missingVoice 处理指定了未安装语音的消息 reqs：发布诊断信息，启用 RetryMissingVoice 时
+// 保留等待重试并返回 true，否则改用默认语音（reqs 被就地修改）并返回 false
+func (b *bridge) missingVoice(reqs []speakRequest) bool {
+	cfg := b.config()
+	voice := reqs[0].Opts.Voice
+	ev := missingVoiceError{
+		Topic:     reqs[0].Topic,
+		Voice:     voice,
+		Text:      reqs[0].Text,
+		Error:     errMissingVoice.Error(),
+		Installed: installedVoices.Names(),
+		Retrying:  cfg.RetryMissingVoice,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	// 从 enqueue 调用时处于 MQTT 回调中，不等待发布确认
+	if b.client != nil {
+		go publishStatus(b.client, cfg.StatusTopic, ev)
+	}
+	if cfg.RetryMissingVoice {
+		b.missing.mu.Lock()
+		defer b.missing.mu.Unlock()
+		if len(b.missing.pending) < maxMissingVoicePending {
+			log.Printf("🗣️ 语音 %q 未安装，消息等待语音安装后朗读（已安装: %s）", voice, strings.Join(ev.Installed, ", "))
+			b.missing.pending = append(b.missing.pending, heldMessage{reqs: reqs, since: time.Now()})
+			return true
+		}
+		log.Printf("⚠️ 等待语音安装的消息已达 %d 条，改用默认语音", maxMissingVoicePending)
+	} else {
+		log.Printf("🗣️ 语音 %q 未安装，改用默认语音（已安装: %s）", voice, strings.Join(ev.Installed, ", "))
+	}
+	for i := range reqs {
+		reqs[i].Opts.Voice = ""
+	}
+	return false
+}
+
+// retryMissingVoices 在刷新语音列表后重新检查等待中的消息：语音已安装的放入队列，
+// 等待超过 missingVoiceRetryLimit 的不再朗读
+func (b *bridge) retryMissingVoices() {
+	b.missing.mu.Lock()
+	pending := b.missing.pending
+	b.missing.pending = nil
+	var ready, expired [][]speakRequest
+	for _, m := range pending {
+		switch installed, _ := installedVoices.Lookup(m.reqs[0].Opts.Voice); {
+		case installed:
+			ready = append(ready, m.reqs)
+		case time.Since(m.since) > missingVoiceRetryLimit:
+			expired = append(expired, m.reqs)
+		default:
+			b.missing.pending = append(b.missing.pending, m)
+		}
+	}
+	b.missing.mu.Unlock()
+
+	for _, reqs := range ready {
+		log.Printf("🗣️ 语音 %q 已安装，朗读等待中的消息: %.50q", reqs[0].Opts.Voice, reqs[0].Text)
+		if err := b.push(reqs); err != nil {
+			b.publishReply(reqs[0], err, 0)
+		}
+	}
+	for _, reqs := range expired {
+		log.Printf("⌛ 语音 %q 等待 %v 仍未安装，不再朗读: %.50q", reqs[0].Opts.Voice, missingVoiceRetryLimit, reqs[0].Text)
+		if b.queue.onResult != nil {
+			for _, req := range reqs {
+				b.queue.onResult(req, errMissingVoice, 0)
+			}
+		}
+		b.queue.dropped(reqs, dropMissingVoice)
+	}
+}
+
+// refreshVoices 每 interval 刷新一次已安装的语音（尚未列出过时先立即刷新），
+// 并重试等待语音安装的消息；后端不支持列出语音时直接返回
+func (b *bridge) refreshVoices(ctx context.Context, speaker Speaker, interval time.Duration) {
+	if _, known := installedVoices.Lookup(""); !known && !b.refreshVoicesOnce(ctx, speaker) {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !b.refreshVoicesOnce(ctx, speaker) {
+			return
+		}
+	}
+}
+
+// refreshVoicesOnce 刷新一次语音列表，返回 false 表示后端不支持列出语音
+func (b *bridge) refreshVoicesOnce(ctx context.Context, speaker Speaker) bool {
+	if err := installedVoices.Refresh(ctx, speaker); err != nil {
+		if errors.Is(err, errVoicesUnsupported) {
+			return false
+		}
+		log.Printf("⚠️ 刷新已安装的语音失败: %v", err)
+		return true
+	}
+	debugf("🗣️ 已刷新语音列表: %d 个语音", len(installedVoices.Names()))
+	b.retryMissingVoices()
+	return true
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// voiceInfo 描述一个已安装的语音，name 即消息中 voice 字段可用的值
+type voiceInfo struct {
+	Name    string `json:"name"`
+	Culture string `json:"culture"`
+	Gender  string `json:"gender"`
+	Age     string `json:"age"`
+	Enabled bool   `json:"enabled"`
+}
+
+// voiceLister 由能列出已安装语音的 Speaker 实现
+type voiceLister interface {
+	Voices(ctx context.Context) ([]voiceInfo, error)
+}
+
+// Voices 通过 $synth.GetInstalledVoices() 列出 System.Speech 已安装的语音
+func (PowerShellSpeaker) Voices(ctx context.Context) ([]voiceInfo, error) {
+	psCmd := `
+		[Console]::OutputEncoding = [System.Text.Encoding]::UTF8
+		Add-Type -AssemblyName System.Speech
+		$synth = New-Object System.Speech.Synthesis.SpeechSynthesizer
+		$voices = @($synth.GetInstalledVoices() | ForEach-Object {
+		    [PSCustomObject]@{
+		        name    = $_.VoiceInfo.Name
+		        culture = $_.VoiceInfo.Culture.Name
+		        gender  = $_.VoiceInfo.Gender.ToString()
+		        age     = $_.VoiceInfo.Age.ToString()
+		        enabled = $_.Enabled
+		    }
+		})
+		$synth.Dispose()
+		ConvertTo-Json -InputObject $voices -Compress
+		`
+	out, err := powerShellCommand(ctx, psCmd).Output()
+	if err != nil {
+		return nil, fmt.Errorf("无法枚举已安装的语音: %w", err)
+	}
+	var voices []voiceInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(string(out))), &voices); err != nil {
+		return nil, fmt.Errorf("无法解析语音列表: %w", err)
+	}
+	return voices, nil
+}
+
+// Voices 列出被包装的 Speaker 的语音
+func (s PlayerSpeaker) Voices(ctx context.Context) ([]voiceInfo, error) {
+	if l, ok := s.Inner.(voiceLister); ok {
+		return l.Voices(ctx)
+	}
+	return nil, errVoicesUnsupported
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// newWavPath 在 dir 下分配一个以时间戳命名的 .wav 文件路径（如 20240105-083000.wav），
+// 目录不存在时自动创建；同名文件已存在时追加计数（20240105-083000-1.wav）。
+// 返回绝对路径，并预先创建空文件占位，避免并发朗读得到相同的文件名。
+func newWavPath(dir string, t time.Time) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("无效的输出目录 %q: %w", dir, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("无法创建输出目录 %q: %w", dir, err)
+	}
+
+	base := t.Format("20060102-150405")
+	for i := 0; ; i++ {
+		name := base + ".wav"
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d.wav", base, i)
+		}
+		path := filepath.Join(dir, name)
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("无法创建文件 %q: %w", path, err)
+		}
+		f.Close()
+		return path, nil
+	}
+}
+
+// errUnsafeOutput 表示消息中的 output 可能写到 OutputDir 之外
+var errUnsafeOutput = errors.New("不安全的输出路径")
+
+// confineOutputDir 将消息中的 output 解析为 base（Config.OutputDir）下的子目录。
+// 绝对路径、盘符、含 .. 越出 base 的路径以及 Windows 保留设备名都会被拒绝，
+// 未配置 base 时不接受任何 output，防止发布者借此写入任意位置
+func confineOutputDir(base, output string) (string, error) {
+	if base == "" {
+		return "", fmt.Errorf("%w %q: 未配置 output_dir", errUnsafeOutput, output)
+	}
+	// filepath.IsLocal 只把本系统的分隔符当作分隔符，先统一斜杠，避免 Linux 上放过 ..\..
+	rel := filepath.FromSlash(strings.ReplaceAll(output, `\`, "/"))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("%w %q: 只能是 output_dir 下的相对路径", errUnsafeOutput, output)
+	}
+	return filepath.Join(base, rel), nil
+}